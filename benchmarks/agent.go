@@ -0,0 +1,405 @@
+package main
+
+import (
+	"benchmarks/live"
+	"benchmarks/util"
+	"benchmarks/worker"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+
+	zlog "github.com/rs/zerolog/log"
+)
+
+// Remote agent that runs a share of the workers (see 'benchmarks agent')
+type AgentArgs struct {
+	Address string // host:port the agent listens on
+	Sites   []int  // indexes (in 'connection') of the sites used by the agent's workers; empty = all
+}
+
+// Benchmarks that can run on agents. the others share state between Setup/Populate and the workers
+// in the same process (e.g., delay measures the counters selected in Populate)
+var agentBenchmarks = []string{"micro", "timestampEncoding", "replay"}
+
+// Messages exchanged between the coordinator and an agent, one JSON object each. In each run, the
+// coordinator sends a job, the agent connects to its sites and replies ready, the coordinator
+// sends start (once all agents are ready), the agent streams a result per worker followed by done,
+// and the coordinator sends finish (once all agents are done), so the agent closes its connections.
+// stop (e.g., on Ctrl-C) makes the agent's workers end early, at their next operation boundary.
+// the coordinator keeps Setup, Populate, Finalize, and the configs/metrics, as it has connections
+// to all sites
+const (
+	msgJob    = "job"
+	msgReady  = "ready"
+	msgStart  = "start"
+	msgResult = "result"
+	msgDone   = "done"
+	msgFinish = "finish"
+	msgStop   = "stop"
+	msgError  = "error"
+)
+
+type AgentMessage struct {
+	Type string
+
+	// job
+	Args         *BenchmarkArgs `json:",omitempty"`
+	Sites        []int          `json:",omitempty"`
+	FirstWorker  int            // id of the agent's first worker, so ids are unique across agents
+	Workers      int            // number of workers of the agent
+	TotalWorkers int            // number of workers of all agents (e.g., to split the rate)
+	Run          int            // index of the run (e.g., to name the traces recorded)
+
+	// ready
+	Client map[string]string `json:",omitempty"` // environment of the agent's host
+
+	// start
+	Origin float64 // (epoch seconds) start of the time series intervals
+
+	// result
+	Result *worker.BenchmarkResults `json:",omitempty"`
+
+	// error
+	Error string `json:",omitempty"`
+}
+
+// Connection between the coordinator and an agent
+type agentConn struct {
+	address string
+	conn    net.Conn
+	encoder *json.Encoder
+	decoder *json.Decoder
+	lock    sync.Mutex // messages may be sent concurrently (e.g., stop)
+}
+
+func newAgentConn(conn net.Conn) *agentConn {
+	return &agentConn{address: conn.RemoteAddr().String(), conn: conn, encoder: json.NewEncoder(conn), decoder: json.NewDecoder(conn)}
+}
+
+func (a *agentConn) send(msg AgentMessage) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.encoder.Encode(msg)
+}
+
+// Receives the next message, failing on errors reported by the other side or unexpected types
+func (a *agentConn) receive(types ...string) (AgentMessage, error) {
+	msg := AgentMessage{}
+	if err := a.decoder.Decode(&msg); err != nil {
+		return msg, fmt.Errorf("agent %s: %w", a.address, err)
+	}
+	if msg.Type == msgError {
+		return msg, fmt.Errorf("agent %s: %s", a.address, msg.Error)
+	}
+	for _, t := range types {
+		if msg.Type == t {
+			return msg, nil
+		}
+	}
+	return msg, fmt.Errorf("agent %s: unexpected message '%s' (expected one of %v)", a.address, msg.Type, types)
+}
+
+// Splits nWorkers among the agents (the first ones get the remainder)
+func splitWorkers(nWorkers int, nAgents int) []int {
+	shares := []int{}
+	for i := 0; i < nAgents; i++ {
+		share := nWorkers / nAgents
+		if i < nWorkers%nAgents {
+			share++
+		}
+		shares = append(shares, share)
+	}
+	return shares
+}
+
+// Message received from an agent, or the error receiving it
+type agentReply struct {
+	msg AgentMessage
+	err error
+}
+
+// Runs nWorkers on the remote agents, stopping them early if ctx is done. Returns the results of
+// each worker, the origin of the time series intervals, and the environment of each agent. If an
+// agent fails, the others are stopped and the error is returned once their connections are closed,
+// so the caller can still tear down the run
+func runAgents(ctx context.Context, args *BenchmarkArgs, nWorkers int,
+	run int,
+) ([]*worker.BenchmarkResults, float64, []map[string]string, error) {
+	agents := []*agentConn{}
+	// closing the connections makes the agents stop their workers and close their own connections
+	quit := make(chan bool)
+	defer func() {
+		close(quit)
+		for _, a := range agents {
+			a.conn.Close()
+		}
+	}()
+
+	firstWorker := 0
+	for i, share := range splitWorkers(nWorkers, len(args.Agents)) {
+		conn, err := net.Dial("tcp", args.Agents[i].Address)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		a := newAgentConn(conn)
+		a.address = args.Agents[i].Address
+		agents = append(agents, a)
+
+		sites := args.Agents[i].Sites
+		if len(sites) == 0 {
+			for j := range args.Connection {
+				sites = append(sites, j)
+			}
+		}
+		err = a.send(AgentMessage{Type: msgJob, Args: args, Sites: sites, FirstWorker: firstWorker,
+			Workers: share, TotalWorkers: nWorkers, Run: run})
+		if err != nil {
+			return nil, 0, nil, fmt.Errorf("agent %s: %w", a.address, err)
+		}
+		firstWorker += share
+	}
+
+	clients := []map[string]string{}
+	for _, a := range agents {
+		ready, err := a.receive(msgReady)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		client := map[string]string{"agent": a.address}
+		for k, v := range ready.Client {
+			client[k] = v
+		}
+		clients = append(clients, client)
+	}
+
+	// clocks of the agents are assumed to be synchronized (e.g., ntp)
+	origin := util.EpochSeconds()
+	for _, a := range agents {
+		if err := a.send(AgentMessage{Type: msgStart, Origin: origin}); err != nil {
+			return nil, 0, nil, fmt.Errorf("agent %s: %w", a.address, err)
+		}
+	}
+	stopAgents := func() {
+		for _, a := range agents {
+			a.send(AgentMessage{Type: msgStop})
+		}
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			stopAgents()
+		case <-quit:
+		}
+	}()
+
+	replies := make(chan agentReply)
+	for _, a := range agents {
+		go func(a *agentConn) {
+			for {
+				msg, err := a.receive(msgResult, msgDone)
+				select {
+				case replies <- agentReply{msg, err}:
+				case <-quit:
+					return
+				}
+				if err != nil || msg.Type == msgDone {
+					return
+				}
+			}
+		}(a)
+	}
+
+	runResults := []*worker.BenchmarkResults{}
+	agentsDone := 0
+	for len(runResults) < nWorkers || agentsDone < len(agents) {
+		reply := <-replies
+		if reply.err != nil {
+			stopAgents()
+			return nil, 0, nil, reply.err
+		}
+		if reply.msg.Type == msgDone {
+			agentsDone++
+		} else {
+			runResults = append(runResults, reply.msg.Result)
+		}
+	}
+
+	for _, a := range agents {
+		if err := a.send(AgentMessage{Type: msgFinish}); err != nil {
+			return nil, 0, nil, fmt.Errorf("agent %s: %w", a.address, err)
+		}
+	}
+
+	return runResults, origin, clients, nil
+}
+
+// Runs the agent's share of the workers of a job
+func serveJob(a *agentConn, job AgentMessage) (err error) {
+	// errors (e.g., a failed connection) are reported to the coordinator instead of stopping the agent
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	args := job.Args
+	registration := engineRegistration(args)
+	benchmarkFactory := getBenchmarkFactory(args.Benchmark, args.FileData)
+
+	if len(job.Sites) == 0 {
+		return fmt.Errorf("job without sites")
+	}
+	sites := []string{}
+	for _, site := range job.Sites {
+		if site < 0 || site >= len(args.Connection) {
+			return fmt.Errorf("site %d out of range (%d connections)", site, len(args.Connection))
+		}
+		sites = append(sites, args.Connection[site])
+	}
+	pools := []any{}
+	defer func() {
+		closeConnections(pools, registration)
+	}()
+	for _, site := range sites {
+		pools = append(pools, registration.Connect(site, args.Isolation, args.Pool))
+	}
+	// the workers are spread among the agent's sites (placement is not supported with agents)
+	local := []int{}
+	for i := 0; i < job.Workers; i++ {
+		local = append(local, i%len(sites))
+	}
+	connections, release := workerConnections(args, registration, sites, pools, local)
+	defer release()
+
+	workers := []*worker.Worker{}
+	for i := 0; i < job.Workers; i++ {
+		workers = append(workers, newWorker(job.FirstWorker+i, job.TotalWorkers, job.Run, args, connections[i], job.Sites[local[i]], benchmarkFactory))
+	}
+	// the agent is ready once its workers are prepared, so the coordinator takes the origin after them
+	if err := prepareWorkers(workers); err != nil {
+		return err
+	}
+	if err := a.send(AgentMessage{Type: msgReady, Client: clientEnvironment()}); err != nil {
+		return err
+	}
+
+	start, err := a.receive(msgStart)
+	if err != nil {
+		return err
+	}
+	zlog.Info().Int("workers", job.Workers).Int("firstWorker", job.FirstWorker).Msg("Running")
+
+	// the workers are stopped early if the coordinator sends stop or goes away
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	finished := make(chan error, 1)
+	go func() {
+		for {
+			msg, err := a.receive(msgStop, msgFinish)
+			if err != nil || msg.Type == msgFinish {
+				cancel()
+				finished <- err
+				return
+			}
+			cancel()
+		}
+	}()
+
+	// a failed worker (e.g., a trace that cannot be written) stops the others, and the job fails once
+	// they have all ended, so the connections are not closed under them
+	c := make(chan *worker.BenchmarkResults)
+	failed := make(chan error)
+	for _, w := range workers {
+		if args.Interval > 0 {
+			w.SetInterval(args.Interval, start.Origin)
+		}
+		go func(w *worker.Worker) {
+			defer func() {
+				if r := recover(); r != nil {
+					failed <- fmt.Errorf("worker: %v", r)
+				}
+			}()
+			w.Run(ctx, c)
+		}(w)
+	}
+	var jobErr error
+	for range workers {
+		select {
+		case r := <-c:
+			if jobErr == nil {
+				jobErr = a.send(AgentMessage{Type: msgResult, Result: r})
+			}
+		case err := <-failed:
+			jobErr = err
+		}
+		if jobErr != nil {
+			cancel()
+		}
+	}
+	if jobErr != nil {
+		return jobErr
+	}
+
+	if registration.Release != nil {
+		registration.Release()
+	}
+	if err := a.send(AgentMessage{Type: msgDone}); err != nil {
+		return err
+	}
+
+	return <-finished
+}
+
+// Serves the jobs sent by coordinators, one connection (run) at a time
+func agent(arguments []string) int {
+	flags := flag.NewFlagSet("agent", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: benchmarks agent [options]")
+		fmt.Fprintln(flags.Output(), "Runs the workers assigned by a coordinator (a config with 'agents').")
+		flags.PrintDefaults()
+	}
+	listen := flags.String("listen", ":7000", "Address to listen on")
+	disableLog := flags.Bool("no-log", false, "Disables the log")
+	logLevel := flags.String("level", "debug", "Log level (info|debug)")
+	metricsAddress := flags.String("metrics", "", "Address to serve live metrics of the agent's workers at /metrics (e.g., :9100)")
+	flags.Parse(arguments)
+
+	setupLogging(*disableLog, *logLevel)
+	if *metricsAddress != "" {
+		util.CheckErr(live.Serve(*metricsAddress))
+	}
+	listener, err := net.Listen("tcp", *listen)
+	if err != nil {
+		log.Fatal(err)
+	}
+	zlog.Info().Str("address", listener.Addr().String()).Msg("Agent listening")
+	log.Fatal(serveAgent(listener))
+	return 0
+}
+
+// Serves the jobs of the connections accepted by the listener, until it fails (e.g., is closed)
+func serveAgent(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		a := newAgentConn(conn)
+		job, err := a.receive(msgJob)
+		if err == nil && job.Args == nil {
+			err = fmt.Errorf("job without arguments")
+		}
+		if err == nil {
+			err = serveJob(a, job)
+		}
+		if err != nil {
+			zlog.Error().Err(err).Str("coordinator", a.address).Msg("Job failed")
+			a.send(AgentMessage{Type: msgError, Error: err.Error()})
+		}
+		conn.Close()
+	}
+}
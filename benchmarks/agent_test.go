@@ -0,0 +1,121 @@
+package main
+
+import (
+	"benchmarks/testutil"
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSplitWorkers(t *testing.T) {
+	tests := []struct {
+		workers, agents int
+		expected        []int
+	}{
+		{4, 2, []int{2, 2}},
+		{5, 2, []int{3, 2}},
+		{2, 3, []int{1, 1, 0}},
+	}
+	for _, test := range tests {
+		if shares := splitWorkers(test.workers, test.agents); !slices.Equal(shares, test.expected) {
+			t.Errorf("splitWorkers(%d, %d) = %v, expected %v", test.workers, test.agents, shares, test.expected)
+		}
+	}
+}
+
+// Starts an agent on 127.0.0.1, serving until the test ends
+func startAgent(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go serveAgent(listener)
+	return listener.Addr().String()
+}
+
+func agentArgs(sites string, agents ...string) *BenchmarkArgs {
+	config := fmt.Sprintf(`
+benchmark: micro
+engine: test
+connection: [%s]
+time: 1
+rate: 200
+workers: [4]
+itemsPerStructure: 10
+initialOpsPerStructure: 10
+operations:
+- {name: counterInc, weight: 1}
+agents:
+- {address: "%s", sites: [0]}
+- {address: "%s", sites: [1]}
+`, sites, agents[0], agents[1])
+	return buildArgs([]byte(config))
+}
+
+// Waits until the agents have closed all their connections
+func waitClosed(t *testing.T) {
+	for i := 0; i < 100 && testutil.Open.Load() != 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := testutil.Open.Load(); n != 0 {
+		t.Errorf("%d connections left open", n)
+	}
+}
+
+func TestRunAgents(t *testing.T) {
+	first, second := startAgent(t), startAgent(t)
+
+	results, _, clients, err := runAgents(context.Background(), agentArgs("site0, site1", first, second), 4, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 4 || len(clients) != 2 {
+		t.Fatalf("expected the results of 4 workers from 2 agents, got %d from %d", len(results), len(clients))
+	}
+	workers := map[int]bool{}
+	for _, r := range results {
+		workers[r.Worker] = true
+		if r.Operations["counterInc"].CompleteCount == 0 {
+			t.Errorf("worker %d completed no operations", r.Worker)
+		}
+	}
+	if len(workers) != 4 {
+		t.Errorf("expected unique worker ids, got %v", workers)
+	}
+	waitClosed(t)
+}
+
+func TestRunAgentsFailure(t *testing.T) {
+	first, second := startAgent(t), startAgent(t)
+
+	// the workers of the second agent fail, so the first one is stopped
+	start := time.Now()
+	_, _, _, err := runAgents(context.Background(), agentArgs("site0, broken", first, second), 4, 0)
+	if err == nil || !strings.Contains(err.Error(), "cannot prepare the connection") {
+		t.Fatalf("expected the error of the failed worker, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 900*time.Millisecond {
+		t.Errorf("expected the run to end early, it took %v", elapsed)
+	}
+	waitClosed(t)
+
+	// both agents still serve the next runs
+	if _, _, _, err := runAgents(context.Background(), agentArgs("site0, site1", first, second), 4, 1); err != nil {
+		t.Fatal(err)
+	}
+	waitClosed(t)
+
+	// an agent that is not listening
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	unreachable := listener.Addr().String()
+	listener.Close()
+	if _, _, _, err := runAgents(context.Background(), agentArgs("site0, site1", first, unreachable), 4, 2); err == nil {
+		t.Fatal("expected an error with an unreachable agent")
+	}
+	waitClosed(t)
+}
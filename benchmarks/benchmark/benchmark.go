@@ -1,12 +1,26 @@
 package benchmark
 
+import (
+	"benchmarks/trace"
+	"context"
+	"fmt"
+	"slices"
+)
+
 type Benchmark interface {
+	// Checks whether the benchmark (and its engine) supports the given operations, and the ranges
+	// of its config keys; called before any connection is opened
+	Validate(operations []string) error
+	// Returns the values unmarshalled from the config file (e.g., the benchmark and its engine),
+	// whose fields are the config keys the benchmark reads
+	ConfigStructs() []any
 	// Called once at the start of the run, to setup any resources required
 	Setup(connections []any)
 	// Populates (and cleans if needed) the databases (receives the list of different connections)
 	Populate(connections []any)
-	// Prepares the statements and returns the list of operations (called for each worker)
-	Prepare(connection any) map[string]func() error
+	// Prepares the statements and returns the list of operations (called for each worker); the
+	// operations must return once the context is done
+	Prepare(connection any) map[string]func(context.Context) error
 	// Returns the benchmark-specific configurations
 	GetConfigs() map[string]string
 	// Returns the benchmark-specific metrics
@@ -14,3 +28,35 @@ type Benchmark interface {
 	// Called once at the end of the run, to close any resources required
 	Finalize(connections []any)
 }
+
+// Benchmarks whose operations can be recorded in traces
+type Traceable interface {
+	// Returns the arguments of the last operation executed (by the worker that prepared it)
+	LastArgs() trace.Args
+}
+
+// Benchmarks whose operations take random arguments, drawn once per operation instead of on each
+// attempt, so its retries repeat the same operation
+type Randomized interface {
+	// Draws the arguments of the next execution of an operation (by the worker that prepared it)
+	Draw(operation string)
+}
+
+// Benchmarks that dictate the sequence of operations (e.g., replaying a trace), instead of the
+// weighted random choice of the worker
+type Scripted interface {
+	// Returns the next operation and its intended start (seconds since the worker started; < 0 to
+	// issue it right away), or false when there are no more operations
+	NextOperation() (string, float64, bool)
+}
+
+// Returns an error if some operation is not among the ones supported by a benchmark
+func CheckOperations(benchmark string, operations []string, supported []string) error {
+	for _, op := range operations {
+		if !slices.Contains(supported, op) {
+			return fmt.Errorf("operation '%s' is not supported by the %s benchmark (expected one of %v)",
+				op, benchmark, supported)
+		}
+	}
+	return nil
+}
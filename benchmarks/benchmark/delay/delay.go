@@ -1,12 +1,11 @@
 package delay
 
 import (
+	"benchmarks/benchmark"
 	engine "benchmarks/benchmark/engines/abstract"
-	"benchmarks/benchmark/engines/crdv"
-	"benchmarks/benchmark/engines/native"
-	"benchmarks/benchmark/engines/pg_crdt"
-	riak_engine "benchmarks/benchmark/engines/riak"
 	"benchmarks/util"
+	"context"
+	"fmt"
 	"math/rand"
 	"strconv"
 	"sync"
@@ -21,10 +20,15 @@ type Delay struct {
 	Counters          int
 	EngineName        string `yaml:"engine"`
 	engine            engine.Engine
+	registration      *engine.Registration
 	LogDelta          int `yaml:"logDelta"`
 	Workers           []int
 	PostEndWait       int `yaml:"postEndWait"`
 	MeasurementSample int `yaml:"measurementSample"`
+
+	Seed    int64 `yaml:"seed"`
+	rand    *rand.Rand
+	counter string // counter incremented by the current write (see Draw)
 }
 
 var currWorkerIndex = -1
@@ -37,24 +41,41 @@ func New(id int, configData []byte) *Delay {
 	delay := Delay{}
 	util.CheckErr(yaml.Unmarshal(configData, &delay))
 	delay.id = id
+	delay.rand = util.NewRand(delay.Seed, fmt.Sprintf("benchmark-%d", id))
 
-	if delay.EngineName == "crdv" {
-		delay.engine = crdv.New(id, configData)
-	} else if delay.EngineName == "native" {
-		delay.engine = native.New(id, configData)
-	} else if delay.EngineName == "electric" {
-		panic("Engine 'electric' does not support counters")
-	} else if delay.EngineName == "pg_crdt" {
-		delay.engine = pg_crdt.New(id, configData)
-	} else if delay.EngineName == "riak" {
-		delay.engine = riak_engine.New(id, configData)
-	} else {
-		panic("Unknown engine: " + delay.EngineName)
+	delay.registration = util.Try(engine.LookupEngine(delay.EngineName))
+	if delay.registration.New == nil {
+		panic("Engine '" + delay.EngineName + "' does not implement the data types")
 	}
+	delay.engine = delay.registration.New(id, configData)
 
 	return &delay
 }
 
+// The writes increment counters, whose values are then periodically read
+func (d *Delay) Validate(operations []string) error {
+	if !d.registration.Supports("counter", "Inc") || !d.registration.Supports("counter", "Get") {
+		return fmt.Errorf("engine '%s' does not support counters", d.EngineName)
+	}
+	// with no counters measured, the benchmark measures nothing
+	if d.Counters < 1 || d.MeasurementSample < 1 {
+		return fmt.Errorf("counters and measurementSample must be at least 1")
+	}
+	if d.LogDelta < 1 || d.PostEndWait < 0 {
+		return fmt.Errorf("logDelta must be at least 1 (ms), and postEndWait non-negative")
+	}
+	if v, ok := d.engine.(engine.Validator); ok {
+		if err := v.Validate(); err != nil {
+			return fmt.Errorf("engine '%s': %w", d.EngineName, err)
+		}
+	}
+	return benchmark.CheckOperations("delay", operations, []string{"write"})
+}
+
+func (d *Delay) ConfigStructs() []any {
+	return []any{d, d.engine}
+}
+
 func (d *Delay) log(msg string) {
 	zlog.Info().Str("benchmark", "delay").Int("worker", d.id).Msg(msg)
 }
@@ -67,7 +88,7 @@ func (d *Delay) LogCounterValues() {
 
 	for !done {
 		log := zlog.Info().Str("benchmark", "delay").Int("worker", d.id).Int("totalCounters", totalCounters)
-		for k, v := range util.Try(counter.GetMultiple(countersToMeasure)) {
+		for k, v := range util.Try(counter.GetMultiple(context.Background(), countersToMeasure)) {
 			log.Int64("_"+k, v)
 		}
 		log.Msg("read")
@@ -77,7 +98,8 @@ func (d *Delay) LogCounterValues() {
 
 func (d *Delay) Setup(connections []any) {
 	d.engine.Setup(connections)
-	currWorkerIndex++
+	// wraps around, as the same process may execute multiple sweep points
+	currWorkerIndex = (currWorkerIndex + 1) % len(d.Workers)
 	done = false
 	wg = sync.WaitGroup{}
 }
@@ -99,19 +121,19 @@ func (d *Delay) Populate(connections []any) {
 }
 
 func (d *Delay) randomCounter() string {
-	return "c-" + strconv.Itoa(d.id) + "-" + strconv.Itoa(rand.Intn(d.Counters))
+	return "c-" + strconv.Itoa(d.id) + "-" + strconv.Itoa(d.rand.Intn(d.Counters))
 }
 
-func (d *Delay) Prepare(connection any) map[string]func() error {
+func (d *Delay) Prepare(connection any) map[string]func(context.Context) error {
 	d.engine.Prepare(connection)
 
 	counter := d.engine.GetCounter()
-	operations := map[string]func() error{}
-	operations["write"] = func() error { return counter.Inc(d.randomCounter(), 1) }
+	operations := map[string]func(context.Context) error{}
+	operations["write"] = func(ctx context.Context) error { return counter.Inc(ctx, d.counter, 1) }
 
 	// create counters
 	for i := 0; i < d.Counters; i++ {
-		counter.Inc("c-"+strconv.Itoa(d.id)+"-"+strconv.Itoa(i), 0)
+		counter.Inc(context.Background(), "c-"+strconv.Itoa(d.id)+"-"+strconv.Itoa(i), 0)
 	}
 
 	go d.LogCounterValues()
@@ -119,6 +141,12 @@ func (d *Delay) Prepare(connection any) map[string]func() error {
 	return operations
 }
 
+// Draws the counter of the next write, so its retries increment the same counter (see
+// benchmark.Randomized)
+func (d *Delay) Draw(op string) {
+	d.counter = d.randomCounter()
+}
+
 func (d *Delay) GetConfigs() map[string]string {
 	configs := d.engine.GetConfigs()
 	configs["counters"] = strconv.Itoa(d.Counters)
@@ -1,9 +1,11 @@
 package engine
 
+import "context"
+
 type Counter interface {
-	Get(id string) (int64, error)
-	Inc(id string, delta int) error
-	Dec(id string, delta int) error
-	GetAll() (map[string]int64, error)
-	GetMultiple(ids []string) (map[string]int64, error)
+	Get(ctx context.Context, id string) (int64, error)
+	Inc(ctx context.Context, id string, delta int) error
+	Dec(ctx context.Context, id string, delta int) error
+	GetAll(ctx context.Context) (map[string]int64, error)
+	GetMultiple(ctx context.Context, ids []string) (map[string]int64, error)
 }
@@ -26,3 +26,8 @@ type Engine interface {
 	// Cleanup any resources
 	Finalize(connections []any)
 }
+
+// Engines with config keys to check before connecting (e.g., modes and ranges)
+type Validator interface {
+	Validate() error
+}
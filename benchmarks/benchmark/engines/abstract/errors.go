@@ -0,0 +1,10 @@
+package engine
+
+import "errors"
+
+// Returned by operations an engine does not support
+var ErrNotImplemented = errors.New("not implemented")
+
+// Wrapped by engines whose clients do not expose connection errors through the standard library
+// (e.g., riak), so the worker can classify them
+var ErrConnection = errors.New("connection lost")
@@ -1,11 +1,13 @@
 package engine
 
+import "context"
+
 type List interface {
-	Get(id string) ([]string, error)
-	GetAt(id string, index int) (string, error)
-	Add(id string, index int, value string) error
-	Append(id string, value string) error
-	Prepend(id string, value string) error
-	Rmv(id string, index int) error
-	Clear(id string) error
+	Get(ctx context.Context, id string) ([]string, error)
+	GetAt(ctx context.Context, id string, index int) (string, error)
+	Add(ctx context.Context, id string, index int, value string) error
+	Append(ctx context.Context, id string, value string) error
+	Prepend(ctx context.Context, id string, value string) error
+	Rmv(ctx context.Context, id string, index int) error
+	Clear(ctx context.Context, id string) error
 }
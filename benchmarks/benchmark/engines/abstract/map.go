@@ -1,10 +1,12 @@
 package engine
 
+import "context"
+
 type Map interface {
-	Get(id string) (map[string]string, error)
-	Value(id string, key string) (string, error)
-	Contains(id string, key string) (bool, error)
-	Add(id string, key string, value string) error
-	Rmv(id string, key string) error
-	Clear(id string) error
+	Get(ctx context.Context, id string) (map[string]string, error)
+	Value(ctx context.Context, id string, key string) (string, error)
+	Contains(ctx context.Context, id string, key string) (bool, error)
+	Add(ctx context.Context, id string, key string, value string) error
+	Rmv(ctx context.Context, id string, key string) error
+	Clear(ctx context.Context, id string) error
 }
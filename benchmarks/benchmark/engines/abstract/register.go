@@ -1,6 +1,8 @@
 package engine
 
+import "context"
+
 type Register interface {
-	Get(id string) (string, error)
-	Set(id string, value string) error
+	Get(ctx context.Context, id string) (string, error)
+	Set(ctx context.Context, id string, value string) error
 }
@@ -0,0 +1,200 @@
+package engine
+
+import (
+	dbutils "benchmarks/dbUtils"
+	"benchmarks/util"
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"time"
+
+	zlog "github.com/rs/zerolog/log"
+)
+
+// Methods of each data type, as named in the respective interfaces
+var Methods = map[string][]string{
+	"counter":  {"Get", "Inc", "Dec"},
+	"register": {"Get", "Set"},
+	"set":      {"Get", "Contains", "Add", "Rmv", "Clear"},
+	"map":      {"Get", "Value", "Contains", "Add", "Rmv", "Clear"},
+	"list":     {"Get", "GetAt", "Add", "Append", "Prepend", "Rmv", "Clear"},
+}
+
+// Connection pool of each site
+type Pool struct {
+	// 'shared' (default), where the workers of a site share its pool, or 'worker', where each
+	// worker takes a connection of its site's pool for the whole run (see Registration.Dedicate),
+	// so its prepared statements are never prepared again on another connection
+	Mode        string
+	MaxOpen     int     `yaml:"maxOpen"`     // maximum open connections (0 = the engine's default; unlimited in mode 'worker')
+	MaxIdle     int     `yaml:"maxIdle"`     // maximum idle connections (0 = maxOpen; unlimited in mode 'worker')
+	MaxLifetime float64 `yaml:"maxLifetime"` // (seconds) connections are closed after this (0 = never)
+	MaxIdleTime float64 `yaml:"maxIdleTime"` // (seconds) idle connections are closed after this (0 = never)
+}
+
+// Pool of a dedicated connection, for engines that cannot dedicate a connection of a pool (see
+// Registration.Dedicate)
+var Dedicated = Pool{Mode: "worker", MaxOpen: 1, MaxIdle: 1}
+
+// Usage of a connection pool
+type PoolStats struct {
+	Waits    int64         // number of times a connection was waited for
+	WaitTime time.Duration // total time waiting for connections
+	Opened   int64         // connections opened
+	Closed   int64         // connections closed (e.g., for exceeding the idle time or lifetime)
+}
+
+// Changes since 'before'
+func (s PoolStats) Sub(before PoolStats) PoolStats {
+	return PoolStats{s.Waits - before.Waits, s.WaitTime - before.WaitTime, s.Opened - before.Opened, s.Closed - before.Closed}
+}
+
+type Registration struct {
+	Name string
+	// Opens a connection to a site (e.g., *sql.DB, *riak.Client), using the given isolation and
+	// pool settings
+	Connect func(address string, isolation string, pool Pool) any
+	// Closes a connection returned by Connect
+	Close func(connection any)
+	// Returns a connection of a site's pool (returned by Connect) dedicated to a worker, in pool
+	// mode 'worker', and its release (nil if the workers Connect a pool of one instead)
+	Dedicate func(connection any) (any, func())
+	// Creates the engine used by a worker (nil if the engine only provides connections)
+	New func(id int, configData []byte) Engine
+	// Data type -> supported methods
+	Types map[string][]string
+	// Default size of the pool of engines that fail instead of waiting when all connections are in
+	// use, so the workers of each site are limited to it (or the configured maxOpen) (0 = unlimited)
+	MaxConnections int
+	// Returns the current size (bytes) of a site's database, for live metrics (nil if unknown)
+	Size func(connection any) (int64, error)
+	// Releases the resources held by the workers of this process (e.g., local caches), for remote
+	// agents, where Finalize runs on the coordinator instead (nil if there are none)
+	Release func()
+	// Describes the server of a site (e.g., version and settings), stored with the results (nil if
+	// unknown)
+	Environment func(connection any) (map[string]string, error)
+	// Returns the usage of the pool of a connection, reported with the metrics (nil if unknown)
+	PoolStats func(connection any) PoolStats
+}
+
+var registry = map[string]*Registration{}
+
+// Registers an engine; meant to be called from the engine's package init
+func RegisterEngine(r *Registration) {
+	if _, ok := registry[r.Name]; ok {
+		panic("Engine '" + r.Name + "' registered twice")
+	}
+	registry[r.Name] = r
+}
+
+// Returns the engine registered with some name
+func LookupEngine(name string) (*Registration, error) {
+	r, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown engine '%s' (expected one of %v)", name, Names())
+	}
+	return r, nil
+}
+
+// Returns the names of the registered engines, sorted
+func Names() []string {
+	names := []string{}
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Whether the engine supports some method of a data type
+func (r *Registration) Supports(dataType string, method string) bool {
+	return slices.Contains(r.Types[dataType], method)
+}
+
+// Returns the methods of each data type, excluding some methods of every type (e.g., "Clear")
+func MethodsExcept(excluded ...string) map[string][]string {
+	types := map[string][]string{}
+	for dataType, methods := range Methods {
+		types[dataType] = slices.DeleteFunc(slices.Clone(methods), func(m string) bool {
+			return slices.Contains(excluded, m)
+		})
+	}
+	return types
+}
+
+// Connection factory of the postgres-based engines
+func ConnectPostgres(address string, isolation string, pool Pool) any {
+	db := dbutils.Connect(address, isolation)
+	if pool.Mode == "worker" {
+		// each worker holds a connection for the whole run (see DedicatePostgres). the connections
+		// released before (e.g., by Setup) are all kept idle, so the workers take them instead of
+		// opening new ones
+		pool.MaxOpen = 0
+		if pool.MaxIdle == 0 {
+			pool.MaxIdle = math.MaxInt32
+		}
+	} else if pool.MaxOpen == 0 {
+		pool.MaxOpen = 100
+	}
+	// the number of idle connections should be the same as the number of actual connections.
+	// otherwise, if the number of workers is smaller than the number of open connections,
+	// the system will enter in a trashing state where connections are constantly being
+	// created and destroyed, because at least one connection will end up being considered
+	// idle. this causes a significant performance decrease, as the majority of the time
+	// will be spent at the "database/sql.(*Stmt).connStmt" function.
+	if pool.MaxIdle == 0 {
+		pool.MaxIdle = pool.MaxOpen
+	}
+	db.SetMaxOpenConns(pool.MaxOpen)
+	if pool.MaxIdle > 0 {
+		db.SetMaxIdleConns(pool.MaxIdle)
+	}
+	db.SetConnMaxLifetime(time.Duration(pool.MaxLifetime * float64(time.Second)))
+	db.SetConnMaxIdleTime(time.Duration(pool.MaxIdleTime * float64(time.Second)))
+	return db
+}
+
+// Takes a connection of the pool for a worker, so the server settings (e.g., the isolation) are
+// set and restored once per site instead of once per worker
+func DedicatePostgres(connection any) (any, func()) {
+	conn := util.Try(connection.(*sql.DB).Conn(context.Background()))
+	return dbutils.Dedicated{Conn: conn}, func() { conn.Close() }
+}
+
+func ClosePostgres(connection any) {
+	if err := dbutils.Disconnect(connection.(*sql.DB)); err != nil {
+		zlog.Error().Err(err).Msg("Disconnecting")
+	}
+}
+
+func PoolStatsPostgres(connection any) PoolStats {
+	db := connection.(*sql.DB)
+	s := db.Stats()
+	closed := s.MaxIdleClosed + s.MaxIdleTimeClosed + s.MaxLifetimeClosed
+	return PoolStats{Waits: s.WaitCount, WaitTime: s.WaitDuration, Opened: dbutils.Opened(db), Closed: closed}
+}
+
+func SizePostgres(connection any) (int64, error) {
+	return dbutils.DatabaseSize(connection.(*sql.DB))
+}
+
+func EnvironmentPostgres(connection any) (map[string]string, error) {
+	return dbutils.Environment(connection.(*sql.DB))
+}
+
+func init() {
+	// plain postgres, used by the benchmarks that issue their own queries
+	RegisterEngine(&Registration{
+		Name:        "postgres",
+		Connect:     ConnectPostgres,
+		Close:       ClosePostgres,
+		Dedicate:    DedicatePostgres,
+		Size:        SizePostgres,
+		Environment: EnvironmentPostgres,
+		PoolStats:   PoolStatsPostgres,
+	})
+}
@@ -1,9 +1,11 @@
 package engine
 
+import "context"
+
 type Set interface {
-	Get(id string) ([]string, error)
-	Contains(id string, value string) (bool, error)
-	Add(id string, value string) error
-	Rmv(id string, value string) error
-	Clear(id string) error
+	Get(ctx context.Context, id string) ([]string, error)
+	Contains(ctx context.Context, id string, value string) (bool, error)
+	Add(ctx context.Context, id string, value string) error
+	Rmv(ctx context.Context, id string, value string) error
+	Clear(ctx context.Context, id string) error
 }
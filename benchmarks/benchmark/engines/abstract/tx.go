@@ -0,0 +1,48 @@
+package engine
+
+import (
+	dbutils "benchmarks/dbUtils"
+	"context"
+	"database/sql"
+)
+
+// Engines whose operations can run together in a single transaction
+type Transactional interface {
+	// Runs f in a transaction, with the configured isolation; the operations called with the
+	// context given to f are part of it. commits if f succeeds, otherwise rolls back
+	InTx(ctx context.Context, f func(ctx context.Context) error) error
+}
+
+type txKey struct{}
+
+// Transaction of InTxPostgres, and the connection where it runs
+type txValue struct {
+	tx   *sql.Tx
+	conn dbutils.Conn
+}
+
+// Runs f in a transaction of db (see Transactional), for postgres-based engines, whose operations
+// must execute their statements with Stmt
+func InTxPostgres(ctx context.Context, db dbutils.Conn, f func(ctx context.Context) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := f(context.WithValue(ctx, txKey{}, txValue{tx, db})); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Returns the statement bound to the transaction of ctx, if any (see InTxPostgres), otherwise the
+// statement itself. this is intended, and cheap: the bound statement of a pool reuses the one
+// already prepared on the transaction's connection, and the statement of a dedicated connection is
+// used as is (see dbutils.TxStmt), so each statement is still prepared once per connection (only
+// the operations of transaction templates run in a transaction)
+func Stmt(ctx context.Context, stmt *sql.Stmt) *sql.Stmt {
+	if t, ok := ctx.Value(txKey{}).(txValue); ok {
+		return dbutils.TxStmt(ctx, t.tx, t.conn, stmt)
+	}
+	return stmt
+}
@@ -3,8 +3,11 @@ package crdv
 import (
 	engine "benchmarks/benchmark/engines/abstract"
 	dbutils "benchmarks/dbUtils"
+	"benchmarks/live"
 	"benchmarks/util"
+	"context"
 	"database/sql"
+	"fmt"
 	"slices"
 	"strconv"
 	"sync"
@@ -28,6 +31,11 @@ type Crdv struct {
 	set                         *Set
 	map_                        *Map
 	list                        *List
+
+	Seed int64 `yaml:"seed"`
+
+	db       dbutils.Conn       // connection of the worker, for transactions
+	settings []dbutils.Settings // of each site before the benchmark (see Finalize)
 }
 
 var initialDbSize int64
@@ -41,8 +49,79 @@ func New(id int, configData []byte) *Crdv {
 	return &crdv
 }
 
+func init() {
+	engine.RegisterEngine(&engine.Registration{
+		Name:        "crdv",
+		Connect:     engine.ConnectPostgres,
+		Close:       engine.ClosePostgres,
+		Dedicate:    engine.DedicatePostgres,
+		Size:        engine.SizePostgres,
+		Environment: environment,
+		PoolStats:   engine.PoolStatsPostgres,
+		New:         func(id int, configData []byte) engine.Engine { return New(id, configData) },
+		Types:       engine.Methods,
+	})
+}
+
+// Describes a site as postgres does, plus the cluster topology and a fingerprint of the schema
+func environment(connection any) (map[string]string, error) {
+	db := connection.(*sql.DB)
+	env, err := engine.EnvironmentPostgres(db)
+	if err != nil {
+		return nil, err
+	}
+
+	// each site subscribes to every other site (mesh, the default of createCluster.py) or only to
+	// the previous one (ring)
+	var sites, subscriptions int
+	err = db.QueryRow(`
+		select (select count(*) from ClusterInfo),
+			(select count(*) from pg_subscription where subdbid = (select oid from pg_database where datname = current_database()))
+	`).Scan(&sites, &subscriptions)
+	if err != nil {
+		return nil, err
+	}
+	env["sites"] = strconv.Itoa(sites)
+	switch {
+	case sites <= 1:
+		env["topology"] = "single"
+	case subscriptions == sites-1:
+		env["topology"] = "mesh"
+	case subscriptions == 1:
+		env["topology"] = "ring"
+	default:
+		env["topology"] = fmt.Sprintf("%d of %d sites", subscriptions, sites-1)
+	}
+
+	// the schema has no version, so its revision is identified by a hash of the definitions of
+	// its functions and views (excluding those of the extensions)
+	var schema string
+	err = db.QueryRow(`
+		select left(md5(string_agg(def, '' order by def)), 12)
+		from (
+			select pg_get_functiondef(p.oid) as def
+			from pg_proc p
+			join pg_namespace n on n.oid = p.pronamespace
+			where n.nspname = 'public' and p.prokind in ('f', 'p')
+				and not exists (select 1 from pg_depend d where d.objid = p.oid and d.deptype = 'e')
+			union all
+			select pg_get_viewdef(c.oid)
+			from pg_class c
+			join pg_namespace n on n.oid = c.relnamespace
+			where n.nspname = 'public' and c.relkind in ('v', 'm')
+		) defs
+	`).Scan(&schema)
+	if err != nil {
+		return nil, err
+	}
+	env["schema"] = schema
+	return env, nil
+}
+
 func (c *Crdv) logUnmergedRows(connId int, count int64) {
 	zlog.Info().Str("benchmark", "micro").Int("connId", connId).Int64("count", count).Msg("Unmerged rows")
+	live.SetGauge("benchmark_crdv_unmerged_rows", "Rows not yet merged at each site (crdv, with trackUnmergedRows)",
+		live.Labels{"site": strconv.Itoa(connId)}, float64(count))
 }
 
 // Periodically logs the number of unmerged rows in each database
@@ -72,6 +151,7 @@ func (c *Crdv) trackUnmergedRows(dbs []*sql.DB) {
 
 func (c *Crdv) Setup(connections []any) {
 	dbs := util.CastArray[any, *sql.DB](connections)
+	c.settings = dbutils.SnapshotSettings(dbs)
 	if c.TrackUnmergedRows {
 		trackUnmergedRowsSignal = make(chan bool)
 		go c.trackUnmergedRows(dbs)
@@ -99,16 +179,16 @@ func (c *Crdv) Populate(connections []any, typesToPopulate []string, itemsPerStr
 		go populateCounters(&wg, dbs, itemsPerStructure)
 	}
 	if slices.Contains(typesToPopulate, "register") {
-		go populateRegisters(&wg, dbs, itemsPerStructure, valueLength)
+		go populateRegisters(&wg, dbs, itemsPerStructure, valueLength, util.NewRand(c.Seed, "populate-register"))
 	}
 	if slices.Contains(typesToPopulate, "set") {
 		go populateSets(&wg, dbs, itemsPerStructure, opsPerItem)
 	}
 	if slices.Contains(typesToPopulate, "map") {
-		go populateMaps(&wg, dbs, itemsPerStructure, opsPerItem, valueLength)
+		go populateMaps(&wg, dbs, itemsPerStructure, opsPerItem, valueLength, util.NewRand(c.Seed, "populate-map"))
 	}
 	if slices.Contains(typesToPopulate, "list") {
-		go populateLists(&wg, dbs, itemsPerStructure, opsPerItem, valueLength)
+		go populateLists(&wg, dbs, itemsPerStructure, opsPerItem, valueLength, util.NewRand(c.Seed, "populate-list"))
 	}
 
 	wg.Wait()
@@ -119,8 +199,18 @@ func (c *Crdv) Populate(connections []any, typesToPopulate []string, itemsPerStr
 	initialDbSize = dbutils.DbSize(dbs[0], c.VacuumFull)
 }
 
+func (c *Crdv) Validate() error {
+	if err := dbutils.CheckModes(c.Modes); err != nil {
+		return err
+	}
+	if c.MergeParallelism < 1 || c.MergeDelta <= 0 || c.MergeBatchSize < 1 {
+		return fmt.Errorf("mergeParallelism and mergeBatchSize must be at least 1, and mergeDelta positive")
+	}
+	return nil
+}
+
 func (c *Crdv) Prepare(connection any) {
-	db := connection.(*sql.DB)
+	db := connection.(dbutils.Conn)
 
 	// switch to the correct modes
 	dbutils.SetReadMode(db, c.Modes["readMode"])
@@ -132,6 +222,13 @@ func (c *Crdv) Prepare(connection any) {
 	c.set = newSet(db)
 	c.map_ = newMap(db)
 	c.list = newList(db)
+	c.db = db
+}
+
+// Runs f in a transaction; the crdv functions run in the caller's transaction, so the updates of
+// all the operations are committed (and later merged) together
+func (c *Crdv) InTx(ctx context.Context, f func(ctx context.Context) error) error {
+	return engine.InTxPostgres(ctx, c.db, f)
 }
 
 func (c *Crdv) GetRegister() engine.Register {
@@ -166,7 +263,7 @@ func (c *Crdv) GetConfigs() map[string]string {
 }
 
 func (c *Crdv) GetMetrics(connection any) map[string]string {
-	db := connection.(*sql.DB)
+	db := connection.(dbutils.Conn)
 	return map[string]string{
 		"startSize": strconv.FormatInt(initialDbSize, 10),
 		"endSize":   strconv.FormatInt(dbutils.DbSize(db, c.VacuumFull), 10),
@@ -187,4 +284,6 @@ func (c *Crdv) Finalize(connections []any) {
 		trackUnmergedRowsSignal <- true
 		<-trackUnmergedRowsSignal
 	}
+
+	dbutils.RestoreSettings(dbs, c.settings)
 }
@@ -1,9 +1,12 @@
 package crdv
 
 import (
+	engine "benchmarks/benchmark/engines/abstract"
 	dbutils "benchmarks/dbUtils"
 	"benchmarks/util"
+	"context"
 	"database/sql"
+	"math/rand"
 	"sync"
 )
 
@@ -16,7 +19,7 @@ type Map struct {
 	clearStmt     *sql.Stmt
 }
 
-func populateMaps(wg *sync.WaitGroup, dbs []*sql.DB, nMaps int, size int, valueLength int) {
+func populateMaps(wg *sync.WaitGroup, dbs []*sql.DB, nMaps int, size int, valueLength int, rng *rand.Rand) {
 	defer wg.Done()
 
 	// populate the first structure using the regular API and one site
@@ -25,14 +28,14 @@ func populateMaps(wg *sync.WaitGroup, dbs []*sql.DB, nMaps int, size int, valueL
 		from (
 			select generate_series(0, $1 - 1) as i
 		) T
-	`, size, util.RandomString(valueLength)))
+	`, size, util.RandomString(rng, valueLength)))
 
 	// populate the remaining structures by copying first one in each site the (only the id is
 	// different); this is faster than populating each one separately and replicating the data
 	dbutils.CopyStructure(dbs, "m-0", "m-", nMaps-1)
 }
 
-func newMap(db *sql.DB) *Map {
+func newMap(db dbutils.Conn) *Map {
 	m := &Map{}
 	m.getStmts = map[mode]*sql.Stmt{
 		AwMvr: util.Try(db.Prepare("select (data).key, (data).value from MapAwMvr where id = $1")),
@@ -58,8 +61,11 @@ func newMap(db *sql.DB) *Map {
 	return m
 }
 
-func (m *Map) Get(id string) (map[string]string, error) {
-	rs := util.Try(m.getStmts[Lww].Query(id))
+func (m *Map) Get(ctx context.Context, id string) (map[string]string, error) {
+	rs, err := engine.Stmt(ctx, m.getStmts[Lww]).QueryContext(ctx, id)
+	if err != nil {
+		return nil, err
+	}
 	defer rs.Close()
 
 	result := map[string]string{}
@@ -69,42 +75,48 @@ func (m *Map) Get(id string) (map[string]string, error) {
 		result[key] = value
 	}
 
-	return result, nil
+	return result, rs.Err()
 }
 
-func (m *Map) Value(id string, key string) (string, error) {
-	rs := util.Try(m.valueStmts[Lww].Query(id, key))
+func (m *Map) Value(ctx context.Context, id string, key string) (string, error) {
+	rs, err := engine.Stmt(ctx, m.valueStmts[Lww]).QueryContext(ctx, id, key)
+	if err != nil {
+		return "", err
+	}
 	rs.Next()
 	defer rs.Close()
 
 	var value string
 	rs.Scan(&value)
 
-	return value, nil
+	return value, rs.Err()
 }
 
-func (m *Map) Contains(id string, key string) (bool, error) {
-	rs := util.Try(m.containsStmts[Lww].Query(id, key))
+func (m *Map) Contains(ctx context.Context, id string, key string) (bool, error) {
+	rs, err := engine.Stmt(ctx, m.containsStmts[Lww]).QueryContext(ctx, id, key)
+	if err != nil {
+		return false, err
+	}
 	rs.Next()
 	defer rs.Close()
 
 	var value bool
 	rs.Scan(&value)
 
-	return value, nil
+	return value, rs.Err()
 }
 
-func (m *Map) Add(id string, key string, value string) error {
-	_, err := m.addStmt.Exec(id, key, value)
+func (m *Map) Add(ctx context.Context, id string, key string, value string) error {
+	_, err := engine.Stmt(ctx, m.addStmt).ExecContext(ctx, id, key, value)
 	return err
 }
 
-func (m *Map) Rmv(id string, key string) error {
-	_, err := m.rmvStmt.Exec(id, key)
+func (m *Map) Rmv(ctx context.Context, id string, key string) error {
+	_, err := engine.Stmt(ctx, m.rmvStmt).ExecContext(ctx, id, key)
 	return err
 }
 
-func (m *Map) Clear(id string) error {
-	_, err := m.clearStmt.Exec(id)
+func (m *Map) Clear(ctx context.Context, id string) error {
+	_, err := engine.Stmt(ctx, m.clearStmt).ExecContext(ctx, id)
 	return err
 }
@@ -1,9 +1,12 @@
 package crdv
 
 import (
+	engine "benchmarks/benchmark/engines/abstract"
 	dbutils "benchmarks/dbUtils"
 	"benchmarks/util"
+	"context"
 	"database/sql"
+	"math/rand"
 	"sync"
 )
 
@@ -12,18 +15,18 @@ type Register struct {
 	setStmt  *sql.Stmt
 }
 
-func populateRegisters(wg *sync.WaitGroup, dbs []*sql.DB, nRegisters int, valueLength int) {
+func populateRegisters(wg *sync.WaitGroup, dbs []*sql.DB, nRegisters int, valueLength int, rng *rand.Rand) {
 	defer wg.Done()
 
 	// populate the first structure using the regular API and one site
-	util.Try(dbs[0].Exec("select registerSet('r-0', $1)", util.RandomString(valueLength)))
+	util.Try(dbs[0].Exec("select registerSet('r-0', $1)", util.RandomString(rng, valueLength)))
 
 	// populate the remaining structures by copying first one in each site the (only the id is
 	// different); this is faster than populating each one separately and replicating the data
 	dbutils.CopyStructure(dbs, "r-0", "r-", nRegisters-1)
 }
 
-func newRegister(db *sql.DB) *Register {
+func newRegister(db dbutils.Conn) *Register {
 	r := &Register{}
 	r.getStmts = map[mode]*sql.Stmt{
 		Mvr: util.Try(db.Prepare("select registerMvrGet($1)")),
@@ -33,18 +36,21 @@ func newRegister(db *sql.DB) *Register {
 	return r
 }
 
-func (r *Register) Get(id string) (string, error) {
-	rs := util.Try(r.getStmts[Lww].Query(id))
+func (r *Register) Get(ctx context.Context, id string) (string, error) {
+	rs, err := engine.Stmt(ctx, r.getStmts[Lww]).QueryContext(ctx, id)
+	if err != nil {
+		return "", err
+	}
 	rs.Next()
 	defer rs.Close()
 
 	var value string
 	rs.Scan(&value)
 
-	return value, nil
+	return value, rs.Err()
 }
 
-func (r *Register) Set(id string, value string) error {
-	_, err := r.setStmt.Exec(id, value)
+func (r *Register) Set(ctx context.Context, id string, value string) error {
+	_, err := engine.Stmt(ctx, r.setStmt).ExecContext(ctx, id, value)
 	return err
 }
@@ -1,8 +1,10 @@
 package crdv
 
 import (
+	engine "benchmarks/benchmark/engines/abstract"
 	dbutils "benchmarks/dbUtils"
 	"benchmarks/util"
+	"context"
 	"database/sql"
 	"sync"
 
@@ -33,7 +35,7 @@ func populateSets(wg *sync.WaitGroup, dbs []*sql.DB, nSets int, size int) {
 	dbutils.CopyStructure(dbs, "s-0", "s-", nSets-1)
 }
 
-func newSet(db *sql.DB) *Set {
+func newSet(db dbutils.Conn) *Set {
 	s := &Set{}
 	s.getStmts = map[mode]*sql.Stmt{
 		Aw:  util.Try(db.Prepare("select setAwGet($1)")),
@@ -51,39 +53,45 @@ func newSet(db *sql.DB) *Set {
 	return s
 }
 
-func (s *Set) Get(id string) ([]string, error) {
-	rs := util.Try(s.getStmts[Lww].Query(id))
+func (s *Set) Get(ctx context.Context, id string) ([]string, error) {
+	rs, err := engine.Stmt(ctx, s.getStmts[Lww]).QueryContext(ctx, id)
+	if err != nil {
+		return nil, err
+	}
 	rs.Next()
 	defer rs.Close()
 
 	values := []string{}
 	rs.Scan(pq.Array(&values))
 
-	return values, nil
+	return values, rs.Err()
 }
 
-func (s *Set) Contains(id string, value string) (bool, error) {
-	rs := util.Try(s.containsStmts[Lww].Query(id, value))
+func (s *Set) Contains(ctx context.Context, id string, value string) (bool, error) {
+	rs, err := engine.Stmt(ctx, s.containsStmts[Lww]).QueryContext(ctx, id, value)
+	if err != nil {
+		return false, err
+	}
 	rs.Next()
 	defer rs.Close()
 
 	var contains bool
 	rs.Scan(&contains)
 
-	return contains, nil
+	return contains, rs.Err()
 }
 
-func (s *Set) Add(id string, value string) error {
-	_, err := s.addStmt.Exec(id, value)
+func (s *Set) Add(ctx context.Context, id string, value string) error {
+	_, err := engine.Stmt(ctx, s.addStmt).ExecContext(ctx, id, value)
 	return err
 }
 
-func (s *Set) Rmv(id string, value string) error {
-	_, err := s.rmvStmt.Exec(id, value)
+func (s *Set) Rmv(ctx context.Context, id string, value string) error {
+	_, err := engine.Stmt(ctx, s.rmvStmt).ExecContext(ctx, id, value)
 	return err
 }
 
-func (s *Set) Clear(id string) error {
-	_, err := s.clearStmt.Exec(id)
+func (s *Set) Clear(ctx context.Context, id string) error {
+	_, err := engine.Stmt(ctx, s.clearStmt).ExecContext(ctx, id)
 	return err
 }
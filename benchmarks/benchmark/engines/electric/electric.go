@@ -4,6 +4,7 @@ import (
 	engine "benchmarks/benchmark/engines/abstract"
 	dbutils "benchmarks/dbUtils"
 	"benchmarks/util"
+	"context"
 	"database/sql"
 	"net/http"
 	"regexp"
@@ -26,6 +27,10 @@ type Electric struct {
 	ResetServerPort        int `yaml:"resetServerPort"`
 	Connection             []string
 	Reset                  bool `yaml:"reset"`
+
+	Seed int64 `yaml:"seed"`
+
+	db dbutils.Conn
 }
 
 var initialDbSize int64
@@ -37,6 +42,24 @@ func New(id int, configData []byte) *Electric {
 	return &electric
 }
 
+func init() {
+	engine.RegisterEngine(&engine.Registration{
+		Name:        "electric",
+		Connect:     engine.ConnectPostgres,
+		Close:       engine.ClosePostgres,
+		Dedicate:    engine.DedicatePostgres,
+		Size:        engine.SizePostgres,
+		Environment: engine.EnvironmentPostgres,
+		PoolStats:   engine.PoolStatsPostgres,
+		New:         func(id int, configData []byte) engine.Engine { return New(id, configData) },
+		Types: map[string][]string{
+			"register": engine.Methods["register"],
+			"set":      engine.Methods["set"],
+			"map":      engine.Methods["map"],
+		},
+	})
+}
+
 func (e *Electric) Setup(connections []any) {}
 
 func (e *Electric) Cleanup(connections []any) {
@@ -76,13 +99,13 @@ func (e *Electric) Populate(connections []any, typesToPopulate []string, itemsPe
 	wg.Add(len(typesToPopulate))
 
 	if slices.Contains(e.TypesToPopulate, "register") {
-		go populateRegisters(&wg, db, e.ItemsPerStructure, valueLength)
+		go populateRegisters(&wg, db, e.ItemsPerStructure, valueLength, util.NewRand(e.Seed, "populate-register"))
 	}
 	if slices.Contains(e.TypesToPopulate, "set") {
 		go populateSets(&wg, db, e.ItemsPerStructure, e.InitialOpsPerStructure)
 	}
 	if slices.Contains(e.TypesToPopulate, "map") {
-		go populateMaps(&wg, db, e.ItemsPerStructure, e.InitialOpsPerStructure, valueLength)
+		go populateMaps(&wg, db, e.ItemsPerStructure, e.InitialOpsPerStructure, valueLength, util.NewRand(e.Seed, "populate-map"))
 	}
 
 	wg.Wait()
@@ -94,10 +117,15 @@ func (e *Electric) Populate(connections []any, typesToPopulate []string, itemsPe
 }
 
 func (e *Electric) Prepare(connection any) {
-	db := connection.(*sql.DB)
+	db := connection.(dbutils.Conn)
 	e.register = newRegister(db)
 	e.set = newSet(db)
 	e.map_ = newMap(db)
+	e.db = db
+}
+
+func (e *Electric) InTx(ctx context.Context, f func(ctx context.Context) error) error {
+	return engine.InTxPostgres(ctx, e.db, f)
 }
 
 func (e *Electric) GetRegister() engine.Register {
@@ -128,7 +156,7 @@ func (e *Electric) GetConfigs() map[string]string {
 	}
 }
 
-func (e *Electric) dbSize(db *sql.DB, vacuumFull bool) int64 {
+func (e *Electric) dbSize(db dbutils.Conn, vacuumFull bool) int64 {
 	if vacuumFull {
 		util.Try(db.Exec("vacuum full analyze"))
 	} else {
@@ -151,7 +179,7 @@ func (e *Electric) dbSize(db *sql.DB, vacuumFull bool) int64 {
 }
 
 func (e *Electric) GetMetrics(connection any) map[string]string {
-	db := connection.(*sql.DB)
+	db := connection.(dbutils.Conn)
 	return map[string]string{
 		"startSize": strconv.FormatInt(initialDbSize, 10),
 		"endSize":   strconv.FormatInt(e.dbSize(db, e.VacuumFull), 10),
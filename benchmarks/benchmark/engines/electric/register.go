@@ -1,8 +1,12 @@
 package electric
 
 import (
+	engine "benchmarks/benchmark/engines/abstract"
+	dbutils "benchmarks/dbUtils"
 	"benchmarks/util"
+	"context"
 	"database/sql"
+	"math/rand"
 	"sync"
 )
 
@@ -11,7 +15,7 @@ type Register struct {
 	setStmt *sql.Stmt
 }
 
-func populateRegisters(wg *sync.WaitGroup, db *sql.DB, size int, valueLength int) {
+func populateRegisters(wg *sync.WaitGroup, db *sql.DB, size int, valueLength int, rng *rand.Rand) {
 	defer wg.Done()
 
 	util.Try(db.Exec(`
@@ -20,29 +24,32 @@ func populateRegisters(wg *sync.WaitGroup, db *sql.DB, size int, valueLength int
 		from (
 			select generate_series(0, $1 - 1) as i
 		) T
-	`, size, util.RandomString(valueLength)))
+	`, size, util.RandomString(rng, valueLength)))
 }
 
-func newRegister(db *sql.DB) *Register {
+func newRegister(db dbutils.Conn) *Register {
 	r := &Register{}
 	r.getStmt = util.Try(db.Prepare("select value from electric_register where id = $1"))
 	r.setStmt = util.Try(db.Prepare("update electric_register set value = $2 where id = $1"))
 	return r
 }
 
-func (r *Register) Get(id string) (string, error) {
-	rs := util.Try(r.getStmt.Query(id))
+func (r *Register) Get(ctx context.Context, id string) (string, error) {
+	rs, err := engine.Stmt(ctx, r.getStmt).QueryContext(ctx, id)
+	if err != nil {
+		return "", err
+	}
 	rs.Next()
 	defer rs.Close()
 
 	var value string
 	rs.Scan(&value)
 
-	return value, nil
+	return value, rs.Err()
 }
 
-func (r *Register) Set(id string, value string) error {
+func (r *Register) Set(ctx context.Context, id string, value string) error {
 	// updates with the same value cause a syntax error on 'electric.shadow__public__electric_register'
-	_, err := r.setStmt.Exec(id, value)
+	_, err := engine.Stmt(ctx, r.setStmt).ExecContext(ctx, id, value)
 	return err
 }
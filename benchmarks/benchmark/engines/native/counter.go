@@ -1,7 +1,10 @@
 package native
 
 import (
+	engine "benchmarks/benchmark/engines/abstract"
+	dbutils "benchmarks/dbUtils"
 	"benchmarks/util"
+	"context"
 	"database/sql"
 	"sync"
 
@@ -29,7 +32,7 @@ func populateCounters(wg *sync.WaitGroup, db *sql.DB, size int) {
 	`, size))
 }
 
-func newCounter(db *sql.DB) *Counter {
+func newCounter(db dbutils.Conn) *Counter {
 	c := &Counter{}
 	c.getStmt = util.Try(db.Prepare("select value from native_counter where id = $1"))
 	c.incStmt = util.Try(db.Prepare("update native_counter set value = value + $2 where id = $1"))
@@ -40,29 +43,35 @@ func newCounter(db *sql.DB) *Counter {
 	return c
 }
 
-func (c *Counter) Get(id string) (int64, error) {
-	rs := util.Try(c.getStmt.Query(id))
+func (c *Counter) Get(ctx context.Context, id string) (int64, error) {
+	rs, err := engine.Stmt(ctx, c.getStmt).QueryContext(ctx, id)
+	if err != nil {
+		return 0, err
+	}
 	rs.Next()
 	defer rs.Close()
 
 	var value int64
 	rs.Scan(&value)
 
-	return value, nil
+	return value, rs.Err()
 }
 
-func (c *Counter) Inc(id string, delta int) error {
-	_, err := c.incStmt.Exec(id, delta)
+func (c *Counter) Inc(ctx context.Context, id string, delta int) error {
+	_, err := engine.Stmt(ctx, c.incStmt).ExecContext(ctx, id, delta)
 	return err
 }
 
-func (c *Counter) Dec(id string, delta int) error {
-	_, err := c.decStmt.Exec(id, delta)
+func (c *Counter) Dec(ctx context.Context, id string, delta int) error {
+	_, err := engine.Stmt(ctx, c.decStmt).ExecContext(ctx, id, delta)
 	return err
 }
 
-func (c *Counter) GetAll() (map[string]int64, error) {
-	rs := util.Try(c.getAllStmt.Query())
+func (c *Counter) GetAll(ctx context.Context) (map[string]int64, error) {
+	rs, err := engine.Stmt(ctx, c.getAllStmt).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
 
 	result := map[string]int64{}
 	for rs.Next() {
@@ -72,11 +81,14 @@ func (c *Counter) GetAll() (map[string]int64, error) {
 		result[id] = value
 	}
 
-	return result, nil
+	return result, rs.Err()
 }
 
-func (c *Counter) GetMultiple(ids []string) (map[string]int64, error) {
-	rs := util.Try(c.getAllStmt.Query(pq.Array(ids)))
+func (c *Counter) GetMultiple(ctx context.Context, ids []string) (map[string]int64, error) {
+	rs, err := engine.Stmt(ctx, c.getAllStmt).QueryContext(ctx, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
 
 	result := map[string]int64{}
 	for rs.Next() {
@@ -86,5 +98,5 @@ func (c *Counter) GetMultiple(ids []string) (map[string]int64, error) {
 		result[id] = value
 	}
 
-	return result, nil
+	return result, rs.Err()
 }
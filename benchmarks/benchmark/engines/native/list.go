@@ -1,8 +1,12 @@
 package native
 
 import (
+	engine "benchmarks/benchmark/engines/abstract"
+	dbutils "benchmarks/dbUtils"
 	"benchmarks/util"
+	"context"
 	"database/sql"
+	"math/rand"
 	"sync"
 
 	"github.com/lib/pq"
@@ -18,7 +22,7 @@ type List struct {
 	clearStmt   *sql.Stmt
 }
 
-func populateLists(wg *sync.WaitGroup, db *sql.DB, nLists int, size int, valueLength int) {
+func populateLists(wg *sync.WaitGroup, db *sql.DB, nLists int, size int, valueLength int, rng *rand.Rand) {
 	defer wg.Done()
 
 	// switch the list id generation mode to improve the populate
@@ -36,13 +40,13 @@ func populateLists(wg *sync.WaitGroup, db *sql.DB, nLists int, size int, valueLe
 		from (
 			select generate_series(0, $1 - 1) as i
 		) T, (select * from t limit $2) T2
-	`, nLists, size, util.RandomString(valueLength)))
+	`, nLists, size, util.RandomString(rng, valueLength)))
 
 	// switch the list generation mode to the default
 	util.Try(db.Exec("select switch_list_id_generation('regular')"))
 }
 
-func newList(db *sql.DB) *List {
+func newList(db dbutils.Conn) *List {
 	l := &List{}
 	l.getStmt = util.Try(db.Prepare("select array_agg(value) from native_list where id = $1"))
 	l.getAtStmt = util.Try(db.Prepare("select value from native_list where id = $1 offset $2 limit 1"))
@@ -75,49 +79,55 @@ func newList(db *sql.DB) *List {
 	return l
 }
 
-func (l *List) Get(id string) ([]string, error) {
-	rs := util.Try(l.getStmt.Query(id))
+func (l *List) Get(ctx context.Context, id string) ([]string, error) {
+	rs, err := engine.Stmt(ctx, l.getStmt).QueryContext(ctx, id)
+	if err != nil {
+		return nil, err
+	}
 	rs.Next()
 	defer rs.Close()
 
 	values := []string{}
 	rs.Scan(pq.Array(&values))
 
-	return values, nil
+	return values, rs.Err()
 }
 
-func (l *List) GetAt(id string, index int) (string, error) {
-	rs := util.Try(l.getAtStmt.Query(id, index))
+func (l *List) GetAt(ctx context.Context, id string, index int) (string, error) {
+	rs, err := engine.Stmt(ctx, l.getAtStmt).QueryContext(ctx, id, index)
+	if err != nil {
+		return "", err
+	}
 	rs.Next()
 	defer rs.Close()
 
 	var value string
 	rs.Scan(&value)
 
-	return value, nil
+	return value, rs.Err()
 }
 
-func (l *List) Add(id string, index int, value string) error {
-	_, err := l.addStmt.Exec(id, index, value)
+func (l *List) Add(ctx context.Context, id string, index int, value string) error {
+	_, err := engine.Stmt(ctx, l.addStmt).ExecContext(ctx, id, index, value)
 	return err
 }
 
-func (l *List) Append(id string, value string) error {
-	_, err := l.appendStmt.Exec(id, value)
+func (l *List) Append(ctx context.Context, id string, value string) error {
+	_, err := engine.Stmt(ctx, l.appendStmt).ExecContext(ctx, id, value)
 	return err
 }
 
-func (l *List) Prepend(id string, value string) error {
-	_, err := l.prependStmt.Exec(id, value)
+func (l *List) Prepend(ctx context.Context, id string, value string) error {
+	_, err := engine.Stmt(ctx, l.prependStmt).ExecContext(ctx, id, value)
 	return err
 }
 
-func (l *List) Rmv(id string, index int) error {
-	_, err := l.rmvStmt.Exec(id, index)
+func (l *List) Rmv(ctx context.Context, id string, index int) error {
+	_, err := engine.Stmt(ctx, l.rmvStmt).ExecContext(ctx, id, index)
 	return err
 }
 
-func (l *List) Clear(id string) error {
-	_, err := l.clearStmt.Exec(id)
+func (l *List) Clear(ctx context.Context, id string) error {
+	_, err := engine.Stmt(ctx, l.clearStmt).ExecContext(ctx, id)
 	return err
 }
@@ -1,8 +1,12 @@
 package native
 
 import (
+	engine "benchmarks/benchmark/engines/abstract"
+	dbutils "benchmarks/dbUtils"
 	"benchmarks/util"
+	"context"
 	"database/sql"
+	"math/rand"
 	"sync"
 )
 
@@ -15,7 +19,7 @@ type Map struct {
 	clearStmt    *sql.Stmt
 }
 
-func populateMaps(wg *sync.WaitGroup, db *sql.DB, nMaps int, size int, valueLength int) {
+func populateMaps(wg *sync.WaitGroup, db *sql.DB, nMaps int, size int, valueLength int, rng *rand.Rand) {
 	defer wg.Done()
 
 	util.Try(db.Exec(`
@@ -24,10 +28,10 @@ func populateMaps(wg *sync.WaitGroup, db *sql.DB, nMaps int, size int, valueLeng
 		from (
 			select generate_series(0, $3 - 1) as i
 		) T
-	`, size, size, nMaps*size, util.RandomString(valueLength)))
+	`, size, size, nMaps*size, util.RandomString(rng, valueLength)))
 }
 
-func newMap(db *sql.DB) *Map {
+func newMap(db dbutils.Conn) *Map {
 	m := &Map{}
 	m.getStmt = util.Try(db.Prepare("select key, value from native_map where id = $1"))
 	m.valueStmt = util.Try(db.Prepare("select value from native_map where id = $1 and key = $2"))
@@ -38,8 +42,11 @@ func newMap(db *sql.DB) *Map {
 	return m
 }
 
-func (m *Map) Get(id string) (map[string]string, error) {
-	rs := util.Try(m.getStmt.Query(id))
+func (m *Map) Get(ctx context.Context, id string) (map[string]string, error) {
+	rs, err := engine.Stmt(ctx, m.getStmt).QueryContext(ctx, id)
+	if err != nil {
+		return nil, err
+	}
 	defer rs.Close()
 
 	result := map[string]string{}
@@ -49,42 +56,48 @@ func (m *Map) Get(id string) (map[string]string, error) {
 		result[key] = value
 	}
 
-	return result, nil
+	return result, rs.Err()
 }
 
-func (m *Map) Value(id string, key string) (string, error) {
-	rs := util.Try(m.valueStmt.Query(id, key))
+func (m *Map) Value(ctx context.Context, id string, key string) (string, error) {
+	rs, err := engine.Stmt(ctx, m.valueStmt).QueryContext(ctx, id, key)
+	if err != nil {
+		return "", err
+	}
 	rs.Next()
 	defer rs.Close()
 
 	var value string
 	rs.Scan(&value)
 
-	return value, nil
+	return value, rs.Err()
 }
 
-func (m *Map) Contains(id string, key string) (bool, error) {
-	rs := util.Try(m.containsStmt.Query(id, key))
+func (m *Map) Contains(ctx context.Context, id string, key string) (bool, error) {
+	rs, err := engine.Stmt(ctx, m.containsStmt).QueryContext(ctx, id, key)
+	if err != nil {
+		return false, err
+	}
 	rs.Next()
 	defer rs.Close()
 
 	var value bool
 	rs.Scan(&value)
 
-	return value, nil
+	return value, rs.Err()
 }
 
-func (m *Map) Add(id string, key string, value string) error {
-	_, err := m.addStmt.Exec(id, key, value)
+func (m *Map) Add(ctx context.Context, id string, key string, value string) error {
+	_, err := engine.Stmt(ctx, m.addStmt).ExecContext(ctx, id, key, value)
 	return err
 }
 
-func (m *Map) Rmv(id string, key string) error {
-	_, err := m.rmvStmt.Exec(id, key)
+func (m *Map) Rmv(ctx context.Context, id string, key string) error {
+	_, err := engine.Stmt(ctx, m.rmvStmt).ExecContext(ctx, id, key)
 	return err
 }
 
-func (m *Map) Clear(id string) error {
-	_, err := m.clearStmt.Exec(id)
+func (m *Map) Clear(ctx context.Context, id string) error {
+	_, err := engine.Stmt(ctx, m.clearStmt).ExecContext(ctx, id)
 	return err
 }
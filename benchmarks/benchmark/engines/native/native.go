@@ -4,6 +4,7 @@ import (
 	engine "benchmarks/benchmark/engines/abstract"
 	dbutils "benchmarks/dbUtils"
 	"benchmarks/util"
+	"context"
 	"database/sql"
 	"slices"
 	"strconv"
@@ -23,6 +24,10 @@ type Native struct {
 	set                    *Set
 	map_                   *Map
 	list                   *List
+
+	Seed int64 `yaml:"seed"`
+
+	db dbutils.Conn
 }
 
 var initialDbSize int64
@@ -34,6 +39,20 @@ func New(id int, configData []byte) *Native {
 	return &native
 }
 
+func init() {
+	engine.RegisterEngine(&engine.Registration{
+		Name:        "native",
+		Connect:     engine.ConnectPostgres,
+		Close:       engine.ClosePostgres,
+		Dedicate:    engine.DedicatePostgres,
+		Size:        engine.SizePostgres,
+		Environment: engine.EnvironmentPostgres,
+		PoolStats:   engine.PoolStatsPostgres,
+		New:         func(id int, configData []byte) engine.Engine { return New(id, configData) },
+		Types:       engine.Methods,
+	})
+}
+
 func (n *Native) Setup(connections []any) {
 	dbs := util.CastArray[any, *sql.DB](connections)
 	db := dbs[0]
@@ -77,16 +96,16 @@ func (n *Native) Populate(connections []any, typesToPopulate []string, itemsPerS
 		go populateCounters(&wg, db, n.ItemsPerStructure)
 	}
 	if slices.Contains(n.TypesToPopulate, "register") {
-		go populateRegisters(&wg, db, n.ItemsPerStructure, valueLength)
+		go populateRegisters(&wg, db, n.ItemsPerStructure, valueLength, util.NewRand(n.Seed, "populate-register"))
 	}
 	if slices.Contains(n.TypesToPopulate, "set") {
 		go populateSets(&wg, db, n.ItemsPerStructure, n.InitialOpsPerStructure)
 	}
 	if slices.Contains(n.TypesToPopulate, "map") {
-		go populateMaps(&wg, db, n.ItemsPerStructure, n.InitialOpsPerStructure, valueLength)
+		go populateMaps(&wg, db, n.ItemsPerStructure, n.InitialOpsPerStructure, valueLength, util.NewRand(n.Seed, "populate-map"))
 	}
 	if slices.Contains(n.TypesToPopulate, "list") {
-		go populateLists(&wg, db, n.ItemsPerStructure, n.InitialOpsPerStructure, valueLength)
+		go populateLists(&wg, db, n.ItemsPerStructure, n.InitialOpsPerStructure, valueLength, util.NewRand(n.Seed, "populate-list"))
 	}
 	wg.Wait()
 
@@ -97,12 +116,17 @@ func (n *Native) Populate(connections []any, typesToPopulate []string, itemsPerS
 }
 
 func (n *Native) Prepare(connection any) {
-	db := connection.(*sql.DB)
+	db := connection.(dbutils.Conn)
 	n.counter = newCounter(db)
 	n.register = newRegister(db)
 	n.set = newSet(db)
 	n.map_ = newMap(db)
 	n.list = newList(db)
+	n.db = db
+}
+
+func (n *Native) InTx(ctx context.Context, f func(ctx context.Context) error) error {
+	return engine.InTxPostgres(ctx, n.db, f)
 }
 
 func (n *Native) GetRegister() engine.Register {
@@ -133,7 +157,7 @@ func (n *Native) GetConfigs() map[string]string {
 	}
 }
 
-func (n *Native) dbSize(db *sql.DB, vacuumFull bool) int64 {
+func (n *Native) dbSize(db dbutils.Conn, vacuumFull bool) int64 {
 	if vacuumFull {
 		util.Try(db.Exec("vacuum full analyze"))
 	} else {
@@ -152,7 +176,7 @@ func (n *Native) dbSize(db *sql.DB, vacuumFull bool) int64 {
 }
 
 func (n *Native) GetMetrics(connection any) map[string]string {
-	db := connection.(*sql.DB)
+	db := connection.(dbutils.Conn)
 	return map[string]string{
 		"startSize": strconv.FormatInt(initialDbSize, 10),
 		"endSize":   strconv.FormatInt(n.dbSize(db, n.VacuumFull), 10),
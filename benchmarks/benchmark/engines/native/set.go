@@ -1,7 +1,10 @@
 package native
 
 import (
+	engine "benchmarks/benchmark/engines/abstract"
+	dbutils "benchmarks/dbUtils"
 	"benchmarks/util"
+	"context"
 	"database/sql"
 	"sync"
 )
@@ -26,7 +29,7 @@ func populateSets(wg *sync.WaitGroup, db *sql.DB, nSets int, size int) {
 	`, size, size, nSets*size))
 }
 
-func newSet(db *sql.DB) *Set {
+func newSet(db dbutils.Conn) *Set {
 	s := &Set{}
 	s.getStmt = util.Try(db.Prepare("select elem from native_set where id = $1"))
 	s.containsStmt = util.Try(db.Prepare("select exists(select 1 from native_set where id = $1 and elem = $2)"))
@@ -36,8 +39,11 @@ func newSet(db *sql.DB) *Set {
 	return s
 }
 
-func (s *Set) Get(id string) ([]string, error) {
-	rs := util.Try(s.getStmt.Query(id))
+func (s *Set) Get(ctx context.Context, id string) ([]string, error) {
+	rs, err := engine.Stmt(ctx, s.getStmt).QueryContext(ctx, id)
+	if err != nil {
+		return nil, err
+	}
 	defer rs.Close()
 
 	result := []string{}
@@ -47,31 +53,34 @@ func (s *Set) Get(id string) ([]string, error) {
 		result = append(result, value)
 	}
 
-	return result, nil
+	return result, rs.Err()
 }
 
-func (s *Set) Contains(id string, value string) (bool, error) {
-	rs := util.Try(s.containsStmt.Query(id, value))
+func (s *Set) Contains(ctx context.Context, id string, value string) (bool, error) {
+	rs, err := engine.Stmt(ctx, s.containsStmt).QueryContext(ctx, id, value)
+	if err != nil {
+		return false, err
+	}
 	rs.Next()
 	defer rs.Close()
 
 	var contains bool
 	rs.Scan(&contains)
 
-	return contains, nil
+	return contains, rs.Err()
 }
 
-func (s *Set) Add(id string, value string) error {
-	_, err := s.addStmt.Exec(id, value)
+func (s *Set) Add(ctx context.Context, id string, value string) error {
+	_, err := engine.Stmt(ctx, s.addStmt).ExecContext(ctx, id, value)
 	return err
 }
 
-func (s *Set) Rmv(id string, value string) error {
-	_, err := s.rmvStmt.Exec(id, value)
+func (s *Set) Rmv(ctx context.Context, id string, value string) error {
+	_, err := engine.Stmt(ctx, s.rmvStmt).ExecContext(ctx, id, value)
 	return err
 }
 
-func (s *Set) Clear(id string) error {
-	_, err := s.clearStmt.Exec(id)
+func (s *Set) Clear(ctx context.Context, id string) error {
+	_, err := engine.Stmt(ctx, s.clearStmt).ExecContext(ctx, id)
 	return err
 }
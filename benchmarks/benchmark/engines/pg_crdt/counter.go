@@ -2,6 +2,7 @@ package pg_crdt
 
 import (
 	"benchmarks/util"
+	"context"
 	"database/sql"
 	"sync"
 
@@ -32,34 +33,53 @@ func newCounter(dm *DataManager) *Counter {
 	return &Counter{dm: dm}
 }
 
-func (c *Counter) Get(id string) (int64, error) {
-	doc := c.dm.getDoc(id)
+func (c *Counter) Get(ctx context.Context, id string) (int64, error) {
+	doc, err := c.dm.getDoc(ctx, id)
+	if err != nil {
+		return 0, err
+	}
 	return doc.Path("c").Counter().Get()
 }
 
-func (c *Counter) Inc(id string, delta int) error {
-	doc := c.dm.getDoc(id)
+func (c *Counter) Inc(ctx context.Context, id string, delta int) error {
+	doc, err := c.dm.getDoc(ctx, id)
+	if err != nil {
+		return err
+	}
 	doc.Path("c").Counter().Inc(int64(delta))
-	return c.dm.applyChange(id, doc)
+	return c.dm.applyChange(ctx, id, doc)
 }
 
-func (c *Counter) Dec(id string, delta int) error {
-	doc := c.dm.getDoc(id)
+func (c *Counter) Dec(ctx context.Context, id string, delta int) error {
+	doc, err := c.dm.getDoc(ctx, id)
+	if err != nil {
+		return err
+	}
 	doc.Path("c").Counter().Inc(int64(-delta))
-	return c.dm.applyChange(id, doc)
+	return c.dm.applyChange(ctx, id, doc)
 }
 
-func (c *Counter) GetAll() (map[string]int64, error) {
+func (c *Counter) GetAll(ctx context.Context) (map[string]int64, error) {
+	docs, err := c.dm.getAllByPrefix(ctx, "c-")
+	if err != nil {
+		return nil, err
+	}
+
 	result := map[string]int64{}
-	for id, doc := range c.dm.getAllByPrefix("c-") {
+	for id, doc := range docs {
 		result[id], _ = doc.Path("c").Counter().Get()
 	}
 	return result, nil
 }
 
-func (c *Counter) GetMultiple(ids []string) (map[string]int64, error) {
+func (c *Counter) GetMultiple(ctx context.Context, ids []string) (map[string]int64, error) {
+	docs, err := c.dm.getMultiple(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
 	result := map[string]int64{}
-	for id, doc := range c.dm.getMultiple(ids) {
+	for id, doc := range docs {
 		result[id], _ = doc.Path("c").Counter().Get()
 	}
 	return result, nil
@@ -1,7 +1,9 @@
 package pg_crdt
 
 import (
+	dbutils "benchmarks/dbUtils"
 	"benchmarks/util"
+	"context"
 	"database/sql"
 	"encoding/base64"
 	"os"
@@ -40,7 +42,7 @@ type DataManager struct {
 	doneWg                *sync.WaitGroup
 }
 
-func newDataManager(db *sql.DB, mode string, replication string, listenConnectionStr string) *DataManager {
+func newDataManager(db dbutils.Conn, mode string, replication string, listenConnectionStr string) *DataManager {
 	dm := DataManager{}
 	dm.uuid = uuid.New().String()
 	dm.remoteGetStmt = util.Try(db.Prepare("select crdt.to_bytea(object) from data where id = $1"))
@@ -192,22 +194,24 @@ func (dm *DataManager) processInsertNotification(objectId string, bytes []byte)
 }
 
 // Gets the automerge doc from the bytes returned by the getStmt query, with id as the query filter
-func (dm *DataManager) getDoc(id string) *automerge.Doc {
+func (dm *DataManager) getDoc(ctx context.Context, id string) (*automerge.Doc, error) {
 	var row *sql.Row
 
 	if dm.localMode {
-		row = dm.localGetStmt.QueryRow(id)
+		row = dm.localGetStmt.QueryRowContext(ctx, id)
 	} else {
-		row = dm.remoteGetStmt.QueryRow(id)
+		row = dm.remoteGetStmt.QueryRowContext(ctx, id)
 	}
 
 	var bytes []byte
-	row.Scan(&bytes)
-	return util.Try(automerge.Load(bytes))
+	if err := row.Scan(&bytes); err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	return automerge.Load(bytes)
 }
 
 // Update the object locally
-func (dm *DataManager) applyLocally(id string, newDoc *automerge.Doc) error {
+func (dm *DataManager) applyLocally(ctx context.Context, id string, newDoc *automerge.Doc) error {
 	dm.lock.Lock()
 	defer dm.lock.Unlock()
 	localDoc := dm.allDocuments[id]
@@ -217,18 +221,18 @@ func (dm *DataManager) applyLocally(id string, newDoc *automerge.Doc) error {
 	}
 	localDoc.Merge(newDoc)
 	bytes := localDoc.Save()
-	_, err := dm.localUpdStmt.Exec(id, bytes)
+	_, err := dm.localUpdStmt.ExecContext(ctx, id, bytes)
 	return err
 }
 
 // Update the object remotely
-func (dm *DataManager) applyRemotely(id string, doc *automerge.Doc) error {
+func (dm *DataManager) applyRemotely(ctx context.Context, id string, doc *automerge.Doc) error {
 	heads := doc.Heads()
 	if len(heads) > 0 {
 		head := heads[0]
 		change := util.Try(doc.Change(head))
 		bytes := change.Save()
-		_, err := dm.remoteUpdStmt.Exec(id, bytes, dm.uuid)
+		_, err := dm.remoteUpdStmt.ExecContext(ctx, id, bytes, dm.uuid)
 		return err
 	} else {
 		return nil
@@ -236,11 +240,11 @@ func (dm *DataManager) applyRemotely(id string, doc *automerge.Doc) error {
 }
 
 // Applies the change to the database(s)
-func (dm *DataManager) applyChange(id string, doc *automerge.Doc) error {
+func (dm *DataManager) applyChange(ctx context.Context, id string, doc *automerge.Doc) error {
 	var err error
 
 	if dm.localMode {
-		err = dm.applyLocally(id, doc)
+		err = dm.applyLocally(ctx, id, doc)
 		if err == nil {
 			// asynchronously add to the remote server
 			heads := doc.Heads()
@@ -248,18 +252,23 @@ func (dm *DataManager) applyChange(id string, doc *automerge.Doc) error {
 				head := heads[0]
 				lastChange := util.Try(doc.Change(head))
 				change := Change{id: id, bytes: lastChange.Save()}
-				dm.changesQueue <- &change
+				// the queue only blocks when full, i.e., when the remote server is not keeping up
+				select {
+				case dm.changesQueue <- &change:
+				case <-ctx.Done():
+					err = ctx.Err()
+				}
 			}
 		}
 	} else {
-		err = dm.applyRemotely(id, doc)
+		err = dm.applyRemotely(ctx, id, doc)
 	}
 
 	return err
 }
 
 // Returns a map with all docs whose id match some prefix
-func (dm *DataManager) getAllByPrefix(prefix string) map[string]*automerge.Doc {
+func (dm *DataManager) getAllByPrefix(ctx context.Context, prefix string) (map[string]*automerge.Doc, error) {
 	result := map[string]*automerge.Doc{}
 
 	if dm.localMode {
@@ -272,20 +281,26 @@ func (dm *DataManager) getAllByPrefix(prefix string) map[string]*automerge.Doc {
 		}
 		dm.lock.Unlock()
 	} else {
-		rs := util.Try(dm.remoteGetAllStmt.Query(prefix + "%"))
+		rs, err := dm.remoteGetAllStmt.QueryContext(ctx, prefix+"%")
+		if err != nil {
+			return nil, err
+		}
 		for rs.Next() {
 			var id string
 			var bytes []byte
 			rs.Scan(&id, &bytes)
 			result[id] = util.Try(automerge.Load(bytes))
 		}
+		if err := rs.Err(); err != nil {
+			return nil, err
+		}
 	}
 
-	return result
+	return result, nil
 }
 
 // Returns a map with all docs pertaining to the ids passed as argument
-func (dm *DataManager) getMultiple(ids []string) map[string]*automerge.Doc {
+func (dm *DataManager) getMultiple(ctx context.Context, ids []string) (map[string]*automerge.Doc, error) {
 	result := map[string]*automerge.Doc{}
 
 	if dm.localMode {
@@ -298,16 +313,22 @@ func (dm *DataManager) getMultiple(ids []string) map[string]*automerge.Doc {
 		}
 		dm.lock.Unlock()
 	} else {
-		rs := util.Try(dm.remoteGetMultipleStmt.Query(pq.Array(ids)))
+		rs, err := dm.remoteGetMultipleStmt.QueryContext(ctx, pq.Array(ids))
+		if err != nil {
+			return nil, err
+		}
 		for rs.Next() {
 			var id string
 			var bytes []byte
 			rs.Scan(&id, &bytes)
 			result[id] = util.Try(automerge.Load(bytes))
 		}
+		if err := rs.Err(); err != nil {
+			return nil, err
+		}
 	}
 
-	return result
+	return result, nil
 }
 
 func (dm *DataManager) finalize() {
@@ -317,5 +338,6 @@ func (dm *DataManager) finalize() {
 		dm.doneWg.Wait()
 		util.CheckErr(dm.notificationsListener.Close())
 		util.CheckErr(dm.localDb.Close())
+		os.Remove("./sqlite/" + dm.uuid + ".db")
 	}
 }
@@ -1,9 +1,11 @@
 package pg_crdt
 
 import (
+	engine "benchmarks/benchmark/engines/abstract"
 	"benchmarks/util"
+	"context"
 	"database/sql"
-	"errors"
+	"math/rand"
 	"sync"
 
 	"github.com/automerge/automerge-go"
@@ -13,7 +15,7 @@ type List struct {
 	dm *DataManager
 }
 
-func populateLists(wg *sync.WaitGroup, db *sql.DB, nLists int, size int, valueLength int) {
+func populateLists(wg *sync.WaitGroup, db *sql.DB, nLists int, size int, valueLength int, rng *rand.Rand) {
 	defer wg.Done()
 
 	doc := automerge.New()
@@ -21,7 +23,7 @@ func populateLists(wg *sync.WaitGroup, db *sql.DB, nLists int, size int, valueLe
 	doc.Path("l").Set(list)
 
 	for i := 0; i < size; i++ {
-		list.Append(util.RandomString(valueLength))
+		list.Append(util.RandomString(rng, valueLength))
 	}
 
 	util.Try(db.Exec(`
@@ -37,8 +39,11 @@ func newList(dm *DataManager) *List {
 	return &List{dm: dm}
 }
 
-func (l *List) Get(id string) ([]string, error) {
-	doc := l.dm.getDoc(id)
+func (l *List) Get(ctx context.Context, id string) ([]string, error) {
+	doc, err := l.dm.getDoc(ctx, id)
+	if err != nil {
+		return nil, err
+	}
 	values := util.Try(doc.Path("l").List().Values())
 	result := []string{}
 
@@ -49,37 +54,52 @@ func (l *List) Get(id string) ([]string, error) {
 	return result, nil
 }
 
-func (l *List) GetAt(id string, index int) (string, error) {
-	doc := l.dm.getDoc(id)
+func (l *List) GetAt(ctx context.Context, id string, index int) (string, error) {
+	doc, err := l.dm.getDoc(ctx, id)
+	if err != nil {
+		return "", err
+	}
 	value := util.Try(doc.Path("l").List().Get(index))
 	return value.GoString(), nil
 }
 
-func (l *List) Add(id string, index int, value string) error {
-	doc := l.dm.getDoc(id)
+func (l *List) Add(ctx context.Context, id string, index int, value string) error {
+	doc, err := l.dm.getDoc(ctx, id)
+	if err != nil {
+		return err
+	}
 	doc.Path("l").List().Insert(index, value)
-	return l.dm.applyChange(id, doc)
+	return l.dm.applyChange(ctx, id, doc)
 }
 
-func (l *List) Append(id string, value string) error {
-	doc := l.dm.getDoc(id)
+func (l *List) Append(ctx context.Context, id string, value string) error {
+	doc, err := l.dm.getDoc(ctx, id)
+	if err != nil {
+		return err
+	}
 	doc.Path("l").List().Append(value)
-	return l.dm.applyChange(id, doc)
+	return l.dm.applyChange(ctx, id, doc)
 }
 
-func (l *List) Prepend(id string, value string) error {
-	doc := l.dm.getDoc(id)
+func (l *List) Prepend(ctx context.Context, id string, value string) error {
+	doc, err := l.dm.getDoc(ctx, id)
+	if err != nil {
+		return err
+	}
 	doc.Path("l").List().Insert(0, value)
-	return l.dm.applyChange(id, doc)
+	return l.dm.applyChange(ctx, id, doc)
 }
 
-func (l *List) Rmv(id string, index int) error {
-	doc := l.dm.getDoc(id)
+func (l *List) Rmv(ctx context.Context, id string, index int) error {
+	doc, err := l.dm.getDoc(ctx, id)
+	if err != nil {
+		return err
+	}
 	// the delete results in signal SIGSEGV: segmentation violation, even without prior deletes
 	doc.Path("l").List().Delete(index)
-	return l.dm.applyChange(id, doc)
+	return l.dm.applyChange(ctx, id, doc)
 }
 
-func (l *List) Clear(id string) error {
-	return errors.New("not implemented")
+func (l *List) Clear(ctx context.Context, id string) error {
+	return engine.ErrNotImplemented
 }
@@ -1,10 +1,12 @@
 package pg_crdt
 
 import (
+	engine "benchmarks/benchmark/engines/abstract"
 	"benchmarks/util"
+	"context"
 	"database/sql"
-	"errors"
 	"fmt"
+	"math/rand"
 	"sync"
 
 	"github.com/automerge/automerge-go"
@@ -14,13 +16,13 @@ type Map struct {
 	dm *DataManager
 }
 
-func populateMaps(wg *sync.WaitGroup, db *sql.DB, nMaps int, size int, valueLength int) {
+func populateMaps(wg *sync.WaitGroup, db *sql.DB, nMaps int, size int, valueLength int, rng *rand.Rand) {
 	defer wg.Done()
 
 	doc := automerge.New()
 	m := doc.RootMap()
 	for i := 0; i < size; i++ {
-		m.Set(fmt.Sprintf("%d", i), util.RandomString(valueLength))
+		m.Set(fmt.Sprintf("%d", i), util.RandomString(rng, valueLength))
 	}
 
 	util.Try(db.Exec(`
@@ -36,8 +38,11 @@ func newMap(dm *DataManager) *Map {
 	return &Map{dm: dm}
 }
 
-func (m *Map) Get(id string) (map[string]string, error) {
-	doc := m.dm.getDoc(id)
+func (m *Map) Get(ctx context.Context, id string) (map[string]string, error) {
+	doc, err := m.dm.getDoc(ctx, id)
+	if err != nil {
+		return nil, err
+	}
 	rootMap := doc.RootMap()
 	keys, _ := rootMap.Keys()
 	result := map[string]string{}
@@ -50,14 +55,20 @@ func (m *Map) Get(id string) (map[string]string, error) {
 	return result, nil
 }
 
-func (m *Map) Value(id string, key string) (string, error) {
-	doc := m.dm.getDoc(id)
+func (m *Map) Value(ctx context.Context, id string, key string) (string, error) {
+	doc, err := m.dm.getDoc(ctx, id)
+	if err != nil {
+		return "", err
+	}
 	value := util.Try(doc.RootMap().Get(key))
 	return value.GoString(), nil
 }
 
-func (m *Map) Contains(id string, key string) (bool, error) {
-	doc := m.dm.getDoc(id)
+func (m *Map) Contains(ctx context.Context, id string, key string) (bool, error) {
+	doc, err := m.dm.getDoc(ctx, id)
+	if err != nil {
+		return false, err
+	}
 	v, _ := doc.RootMap().Get(key)
 
 	contains := new(bool)
@@ -70,18 +81,24 @@ func (m *Map) Contains(id string, key string) (bool, error) {
 	return *contains, nil
 }
 
-func (m *Map) Add(id string, key string, value string) error {
-	doc := m.dm.getDoc(id)
+func (m *Map) Add(ctx context.Context, id string, key string, value string) error {
+	doc, err := m.dm.getDoc(ctx, id)
+	if err != nil {
+		return err
+	}
 	doc.RootMap().Set(key, value)
-	return m.dm.applyChange(id, doc)
+	return m.dm.applyChange(ctx, id, doc)
 }
 
-func (m *Map) Rmv(id string, key string) error {
-	doc := m.dm.getDoc(id)
+func (m *Map) Rmv(ctx context.Context, id string, key string) error {
+	doc, err := m.dm.getDoc(ctx, id)
+	if err != nil {
+		return err
+	}
 	doc.RootMap().Delete(key)
-	return m.dm.applyChange(id, doc)
+	return m.dm.applyChange(ctx, id, doc)
 }
 
-func (m *Map) Clear(id string) error {
-	return errors.New("not implemented")
+func (m *Map) Clear(ctx context.Context, id string) error {
+	return engine.ErrNotImplemented
 }
@@ -3,8 +3,10 @@ package pg_crdt
 import (
 	engine "benchmarks/benchmark/engines/abstract"
 	dbutils "benchmarks/dbUtils"
+	"benchmarks/live"
 	"benchmarks/util"
 	"database/sql"
+	"fmt"
 	"os"
 	"slices"
 	"strconv"
@@ -28,6 +30,8 @@ type PgCrdt struct {
 	dataManager            *DataManager
 	Connection             []string
 	Replication            string `yaml:"replication"`
+
+	Seed int64 `yaml:"seed"`
 }
 
 var initialDbSize int64
@@ -43,6 +47,21 @@ func New(id int, configData []byte) *PgCrdt {
 	return &pgCrdt
 }
 
+func init() {
+	engine.RegisterEngine(&engine.Registration{
+		Name:        "pg_crdt",
+		Connect:     engine.ConnectPostgres,
+		Close:       engine.ClosePostgres,
+		Dedicate:    engine.DedicatePostgres,
+		Size:        engine.SizePostgres,
+		Environment: engine.EnvironmentPostgres,
+		PoolStats:   engine.PoolStatsPostgres,
+		New:         func(id int, configData []byte) engine.Engine { return New(id, configData) },
+		Types:       engine.MethodsExcept("Clear"),
+		Release:     finalizeDataManagers,
+	})
+}
+
 func (p *PgCrdt) Setup(connections []any) {}
 
 func (p *PgCrdt) createSchema(db *sql.DB) {
@@ -151,16 +170,16 @@ func (p *PgCrdt) Populate(connections []any, typesToPopulate []string, itemsPerS
 		go populateCounters(&wg, db, p.ItemsPerStructure)
 	}
 	if slices.Contains(p.TypesToPopulate, "register") {
-		go populateRegisters(&wg, db, p.ItemsPerStructure, valueLength)
+		go populateRegisters(&wg, db, p.ItemsPerStructure, valueLength, util.NewRand(p.Seed, "populate-register"))
 	}
 	if slices.Contains(p.TypesToPopulate, "set") {
 		go populateSets(&wg, db, p.ItemsPerStructure, p.InitialOpsPerStructure)
 	}
 	if slices.Contains(p.TypesToPopulate, "map") {
-		go populateMaps(&wg, db, p.ItemsPerStructure, p.InitialOpsPerStructure, valueLength)
+		go populateMaps(&wg, db, p.ItemsPerStructure, p.InitialOpsPerStructure, valueLength, util.NewRand(p.Seed, "populate-map"))
 	}
 	if slices.Contains(p.TypesToPopulate, "list") {
-		go populateLists(&wg, db, p.ItemsPerStructure, p.InitialOpsPerStructure, valueLength)
+		go populateLists(&wg, db, p.ItemsPerStructure, p.InitialOpsPerStructure, valueLength, util.NewRand(p.Seed, "populate-list"))
 	}
 
 	wg.Wait()
@@ -171,12 +190,24 @@ func (p *PgCrdt) Populate(connections []any, typesToPopulate []string, itemsPerS
 	initialDbSize = p.dbSize(db, p.VacuumFull)
 }
 
+func (p *PgCrdt) Validate() error {
+	if p.Mode != "local" && p.Mode != "remote" {
+		return fmt.Errorf("invalid mode '%s' (expected local or remote)", p.Mode)
+	}
+	if p.Mode == "local" && p.Replication != "state" && p.Replication != "operation" {
+		return fmt.Errorf("invalid replication '%s' (expected state or operation)", p.Replication)
+	}
+	return nil
+}
+
 func (p *PgCrdt) Prepare(connection any) {
-	db := connection.(*sql.DB)
+	db := connection.(dbutils.Conn)
 	p.dataManager = newDataManager(db, p.Mode, p.Replication, p.Connection[0])
 	dataManagersLock.Lock()
 	dataManagers = append(dataManagers, p.dataManager)
 	dataManagersLock.Unlock()
+	live.GaugeFunc("benchmark_pg_crdt_changes_queue", "Local changes waiting to be sent to the remote server (pg_crdt)",
+		changesQueueDepth)
 	p.counter = newCounter(p.dataManager)
 	p.register = newRegister(p.dataManager)
 	p.set = newSet(p.dataManager)
@@ -212,7 +243,7 @@ func (p *PgCrdt) GetConfigs() map[string]string {
 	}
 }
 
-func (p *PgCrdt) dbSize(db *sql.DB, vacuumFull bool) int64 {
+func (p *PgCrdt) dbSize(db dbutils.Conn, vacuumFull bool) int64 {
 	if vacuumFull {
 		util.Try(db.Exec("vacuum full analyze"))
 	} else {
@@ -227,7 +258,7 @@ func (p *PgCrdt) dbSize(db *sql.DB, vacuumFull bool) int64 {
 }
 
 func (p *PgCrdt) GetMetrics(connection any) map[string]string {
-	db := connection.(*sql.DB)
+	db := connection.(dbutils.Conn)
 	return map[string]string{
 		"startSize": strconv.FormatInt(initialDbSize, 10),
 		"endSize":   strconv.FormatInt(p.dbSize(db, p.VacuumFull), 10),
@@ -235,7 +266,25 @@ func (p *PgCrdt) GetMetrics(connection any) map[string]string {
 }
 
 func (p *PgCrdt) Finalize(connections []any) {
+	finalizeDataManagers()
+}
+
+// Returns the number of local changes of the data managers of this process waiting to be sent to
+// the remote server
+func changesQueueDepth() []live.Sample {
+	dataManagersLock.Lock()
+	defer dataManagersLock.Unlock()
+	total := 0
+	for _, dm := range dataManagers {
+		total += len(dm.changesQueue)
+	}
+	return []live.Sample{{Value: float64(total)}}
+}
+
+func finalizeDataManagers() {
 	for _, dm := range dataManagers {
 		dm.finalize()
 	}
+	// only removed if empty (e.g., not used by another process)
+	os.Remove("./sqlite")
 }
@@ -2,7 +2,9 @@ package pg_crdt
 
 import (
 	"benchmarks/util"
+	"context"
 	"database/sql"
+	"math/rand"
 	"sync"
 
 	"github.com/automerge/automerge-go"
@@ -12,11 +14,11 @@ type Register struct {
 	dm *DataManager
 }
 
-func populateRegisters(wg *sync.WaitGroup, db *sql.DB, size int, valueLength int) {
+func populateRegisters(wg *sync.WaitGroup, db *sql.DB, size int, valueLength int, rng *rand.Rand) {
 	defer wg.Done()
 
 	doc := automerge.New()
-	doc.Path("r").Set(util.RandomString(valueLength))
+	doc.Path("r").Set(util.RandomString(rng, valueLength))
 
 	util.Try(db.Exec(`
 		insert into data
@@ -31,14 +33,20 @@ func newRegister(dm *DataManager) *Register {
 	return &Register{dm: dm}
 }
 
-func (r *Register) Get(id string) (string, error) {
-	doc := r.dm.getDoc(id)
+func (r *Register) Get(ctx context.Context, id string) (string, error) {
+	doc, err := r.dm.getDoc(ctx, id)
+	if err != nil {
+		return "", err
+	}
 	value := util.Try(doc.Path("r").Get())
 	return value.GoString(), nil
 }
 
-func (r *Register) Set(id string, value string) error {
-	doc := r.dm.getDoc(id)
+func (r *Register) Set(ctx context.Context, id string, value string) error {
+	doc, err := r.dm.getDoc(ctx, id)
+	if err != nil {
+		return err
+	}
 	doc.Path("r").Set(value)
-	return r.dm.applyChange(id, doc)
+	return r.dm.applyChange(ctx, id, doc)
 }
@@ -1,9 +1,10 @@
 package pg_crdt
 
 import (
+	engine "benchmarks/benchmark/engines/abstract"
 	"benchmarks/util"
+	"context"
 	"database/sql"
-	"errors"
 	"fmt"
 	"sync"
 
@@ -36,13 +37,19 @@ func newSet(dm *DataManager) *Set {
 	return &Set{dm: dm}
 }
 
-func (s *Set) Get(id string) ([]string, error) {
-	doc := s.dm.getDoc(id)
+func (s *Set) Get(ctx context.Context, id string) ([]string, error) {
+	doc, err := s.dm.getDoc(ctx, id)
+	if err != nil {
+		return nil, err
+	}
 	return doc.RootMap().Keys()
 }
 
-func (s *Set) Contains(id string, value string) (bool, error) {
-	doc := s.dm.getDoc(id)
+func (s *Set) Contains(ctx context.Context, id string, value string) (bool, error) {
+	doc, err := s.dm.getDoc(ctx, id)
+	if err != nil {
+		return false, err
+	}
 	v, _ := doc.RootMap().Get(value)
 
 	contains := new(bool)
@@ -55,18 +62,24 @@ func (s *Set) Contains(id string, value string) (bool, error) {
 	return *contains, nil
 }
 
-func (s *Set) Add(id string, value string) error {
-	doc := s.dm.getDoc(id)
+func (s *Set) Add(ctx context.Context, id string, value string) error {
+	doc, err := s.dm.getDoc(ctx, id)
+	if err != nil {
+		return err
+	}
 	doc.RootMap().Set(value, "")
-	return s.dm.applyChange(id, doc)
+	return s.dm.applyChange(ctx, id, doc)
 }
 
-func (s *Set) Rmv(id string, value string) error {
-	doc := s.dm.getDoc(id)
+func (s *Set) Rmv(ctx context.Context, id string, value string) error {
+	doc, err := s.dm.getDoc(ctx, id)
+	if err != nil {
+		return err
+	}
 	doc.RootMap().Delete(value)
-	return s.dm.applyChange(id, doc)
+	return s.dm.applyChange(ctx, id, doc)
 }
 
-func (s *Set) Clear(id string) error {
-	return errors.New("not implemented")
+func (s *Set) Clear(ctx context.Context, id string) error {
+	return engine.ErrNotImplemented
 }
@@ -2,6 +2,7 @@ package riak_engine
 
 import (
 	"benchmarks/util"
+	"context"
 	"fmt"
 	"sync"
 
@@ -53,28 +54,36 @@ func newCounter(client *riak.Client) *Counter {
 	return c
 }
 
-func (c *Counter) Get(id string) (int64, error) {
+func (c *Counter) Get(ctx context.Context, id string) (int64, error) {
 	cmd := util.Try(c.getBuilder().WithKey(id).Build())
-	util.CheckErr(c.client.Execute(cmd))
+	if err := execute(ctx, c.client, cmd); err != nil {
+		return 0, err
+	}
 	return cmd.(*riak.FetchCounterCommand).Response.CounterValue, nil
 }
 
-func (c *Counter) Inc(id string, delta int) error {
+func (c *Counter) Inc(ctx context.Context, id string, delta int) error {
 	cmd := util.Try(c.updBuilder().WithKey(id).WithIncrement(int64(delta)).Build())
-	util.CheckErr(c.client.Execute(cmd))
+	if err := execute(ctx, c.client, cmd); err != nil {
+		return err
+	}
 	return nil
 }
 
-func (c *Counter) Dec(id string, delta int) error {
+func (c *Counter) Dec(ctx context.Context, id string, delta int) error {
 	cmd := util.Try(c.updBuilder().WithKey(id).WithIncrement(int64(-delta)).Build())
-	util.CheckErr(c.client.Execute(cmd))
+	if err := execute(ctx, c.client, cmd); err != nil {
+		return err
+	}
 	return nil
 }
 
-func (c *Counter) GetAll() (map[string]int64, error) {
+func (c *Counter) GetAll(ctx context.Context) (map[string]int64, error) {
 	// get all the keys from the bucket
 	cmd := util.Try(c.getAllBuilder().Build())
-	util.CheckErr(c.client.Execute(cmd))
+	if err := execute(ctx, c.client, cmd); err != nil {
+		return nil, err
+	}
 
 	// get the values of each key. this is parallelized to reduce latency.
 	// although getting each key one by one is not ideal, this is still less expensive than using
@@ -88,7 +97,7 @@ func (c *Counter) GetAll() (map[string]int64, error) {
 		semaphore <- struct{}{}
 		go func(id string) {
 			defer wg.Done()
-			r, _ := c.Get(id)
+			r, _ := c.Get(ctx, id)
 			resultsLock.Lock()
 			results[id] = r
 			resultsLock.Unlock()
@@ -100,7 +109,7 @@ func (c *Counter) GetAll() (map[string]int64, error) {
 	return results, nil
 }
 
-func (c *Counter) GetMultiple(ids []string) (map[string]int64, error) {
+func (c *Counter) GetMultiple(ctx context.Context, ids []string) (map[string]int64, error) {
 	// get the values of each key. this is parallelized to reduce latency.
 	// although getting each key one by one is not ideal, this is still less expensive than using
 	// riak's map reduce.
@@ -113,7 +122,7 @@ func (c *Counter) GetMultiple(ids []string) (map[string]int64, error) {
 		semaphore <- struct{}{}
 		go func(id string) {
 			defer wg.Done()
-			r, _ := c.Get(id)
+			r, _ := c.Get(ctx, id)
 			resultsLock.Lock()
 			results[id] = r
 			resultsLock.Unlock()
@@ -1,9 +1,11 @@
 package riak_engine
 
 import (
+	engine "benchmarks/benchmark/engines/abstract"
 	"benchmarks/util"
-	"errors"
+	"context"
 	"fmt"
+	"math/rand"
 	"sync"
 
 	"github.com/basho/riak-go-client"
@@ -15,12 +17,12 @@ type Map struct {
 	client     *riak.Client
 }
 
-func populateMaps(wg *sync.WaitGroup, client *riak.Client, nMaps int, size int, valueLength int) {
+func populateMaps(wg *sync.WaitGroup, client *riak.Client, nMaps int, size int, valueLength int, rng *rand.Rand) {
 	defer wg.Done()
 
 	op := &riak.MapOperation{}
 	for i := 0; i < size; i++ {
-		op.SetRegister(fmt.Sprintf("%d", i), []byte(util.RandomString(valueLength)))
+		op.SetRegister(fmt.Sprintf("%d", i), []byte(util.RandomString(rng, valueLength)))
 	}
 
 	semaphore := make(chan struct{}, 32)
@@ -55,9 +57,11 @@ func newMap(client *riak.Client) *Map {
 	return m
 }
 
-func (m *Map) Get(id string) (map[string]string, error) {
+func (m *Map) Get(ctx context.Context, id string) (map[string]string, error) {
 	cmd := util.Try(m.getBuilder().WithKey(id).Build())
-	util.CheckErr(m.client.Execute(cmd))
+	if err := execute(ctx, m.client, cmd); err != nil {
+		return nil, err
+	}
 	r := cmd.(*riak.FetchMapCommand).Response.Map
 
 	map_ := map[string]string{}
@@ -68,9 +72,11 @@ func (m *Map) Get(id string) (map[string]string, error) {
 	return map_, nil
 }
 
-func (m *Map) Value(id string, key string) (string, error) {
+func (m *Map) Value(ctx context.Context, id string, key string) (string, error) {
 	cmd := util.Try(m.getBuilder().WithKey(id).Build())
-	util.CheckErr(m.client.Execute(cmd))
+	if err := execute(ctx, m.client, cmd); err != nil {
+		return "", err
+	}
 	r := cmd.(*riak.FetchMapCommand).Response.Map
 
 	if value, ok := r.Registers[key]; ok {
@@ -80,9 +86,11 @@ func (m *Map) Value(id string, key string) (string, error) {
 	}
 }
 
-func (m *Map) Contains(id string, key string) (bool, error) {
+func (m *Map) Contains(ctx context.Context, id string, key string) (bool, error) {
 	cmd := util.Try(m.getBuilder().WithKey(id).Build())
-	util.CheckErr(m.client.Execute(cmd))
+	if err := execute(ctx, m.client, cmd); err != nil {
+		return false, err
+	}
 	r := cmd.(*riak.FetchMapCommand).Response.Map
 
 	if _, ok := r.Registers[key]; ok {
@@ -92,28 +100,34 @@ func (m *Map) Contains(id string, key string) (bool, error) {
 	}
 }
 
-func (m *Map) Add(id string, key string, value string) error {
+func (m *Map) Add(ctx context.Context, id string, key string, value string) error {
 	op := &riak.MapOperation{}
 	op.SetRegister(key, []byte(value))
 	cmd := util.Try(m.updBuilder().WithKey(id).WithMapOperation(op).Build())
-	util.CheckErr(m.client.Execute(cmd))
+	if err := execute(ctx, m.client, cmd); err != nil {
+		return err
+	}
 	return nil
 }
 
-func (m *Map) Rmv(id string, key string) error {
+func (m *Map) Rmv(ctx context.Context, id string, key string) error {
 	// when removing a key from a map we need to get the context
 	cmd := util.Try(m.getBuilder().WithKey(id).Build())
-	util.CheckErr(m.client.Execute(cmd))
-	context := cmd.(*riak.FetchMapCommand).Response.Context
+	if err := execute(ctx, m.client, cmd); err != nil {
+		return err
+	}
+	causalContext := cmd.(*riak.FetchMapCommand).Response.Context
 
 	op := &riak.MapOperation{}
 	op.RemoveRegister(key)
-	cmd = util.Try(m.updBuilder().WithKey(id).WithMapOperation(op).WithContext(context).Build())
-	util.CheckErr(m.client.Execute(cmd))
+	cmd = util.Try(m.updBuilder().WithKey(id).WithMapOperation(op).WithContext(causalContext).Build())
+	if err := execute(ctx, m.client, cmd); err != nil {
+		return err
+	}
 
 	return nil
 }
 
-func (m *Map) Clear(id string) error {
-	return errors.New("not implemented")
+func (m *Map) Clear(ctx context.Context, id string) error {
+	return engine.ErrNotImplemented
 }
@@ -2,8 +2,10 @@ package riak_engine
 
 import (
 	"benchmarks/util"
+	"context"
 	"errors"
 	"fmt"
+	"math/rand"
 	"sync"
 
 	"github.com/basho/riak-go-client"
@@ -15,11 +17,11 @@ type Register struct {
 	client     *riak.Client
 }
 
-func populateRegisters(wg *sync.WaitGroup, client *riak.Client, size int, valueLength int) {
+func populateRegisters(wg *sync.WaitGroup, client *riak.Client, size int, valueLength int, rng *rand.Rand) {
 	defer wg.Done()
 
 	obj := &riak.Object{
-		Value:  []byte(util.RandomString(valueLength)),
+		Value:  []byte(util.RandomString(rng, valueLength)),
 		Bucket: "",
 		Key:    "",
 	}
@@ -56,9 +58,11 @@ func newRegister(client *riak.Client) *Register {
 	return r
 }
 
-func (r *Register) Get(id string) (string, error) {
+func (r *Register) Get(ctx context.Context, id string) (string, error) {
 	cmd := util.Try(r.getBuilder().WithKey(id).Build())
-	util.CheckErr(r.client.Execute(cmd))
+	if err := execute(ctx, r.client, cmd); err != nil {
+		return "", err
+	}
 	result := cmd.(*riak.FetchValueCommand).Response.Values
 
 	// should only happen in large deployments, when this is executed immediately after the populate
@@ -70,13 +74,15 @@ func (r *Register) Get(id string) (string, error) {
 	return string(result[0].Value), nil
 }
 
-func (r *Register) Set(id string, value string) error {
+func (r *Register) Set(ctx context.Context, id string, value string) error {
 	obj := &riak.Object{
 		Value:  []byte(value),
 		Bucket: "",
 		Key:    "",
 	}
 	cmd := util.Try(r.setBuilder().WithKey(id).WithContent(obj).Build())
-	util.CheckErr(r.client.Execute(cmd))
+	if err := execute(ctx, r.client, cmd); err != nil {
+		return err
+	}
 	return nil
 }
@@ -3,7 +3,11 @@ package riak_engine
 import (
 	engine "benchmarks/benchmark/engines/abstract"
 	"benchmarks/util"
+	"context"
+	"errors"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"slices"
 	"strconv"
@@ -29,6 +33,8 @@ type Riak struct {
 	Connection             []string
 	Reset                  bool `yaml:"reset"`
 	PopulateClient         int  `yaml:"populateClient"`
+
+	Seed int64 `yaml:"seed"`
 }
 
 var initialDbSize int64
@@ -40,6 +46,84 @@ func New(id int, configData []byte) *Riak {
 	return &r
 }
 
+func init() {
+	engine.RegisterEngine(&engine.Registration{
+		Name:    "riak",
+		Connect: connect,
+		Close:   disconnect,
+		New:     func(id int, configData []byte) engine.Engine { return New(id, configData) },
+		Types: map[string][]string{
+			"counter":  engine.Methods["counter"],
+			"register": engine.Methods["register"],
+			"set":      engine.MethodsExcept("Clear")["set"],
+			"map":      engine.MethodsExcept("Clear")["map"],
+		},
+		MaxConnections: 256, // riak's default, above which commands fail instead of waiting
+		Environment:    environment,
+	})
+}
+
+// Describes the node of a site
+func environment(connection any) (map[string]string, error) {
+	cmd := &riak.GetServerInfoCommand{}
+	if err := connection.(*riak.Client).Execute(cmd); err != nil {
+		return nil, err
+	}
+	return map[string]string{"node": cmd.Response.Node, "version": cmd.Response.ServerVersion}, nil
+}
+
+// Creates a client for a site (the isolation is not configurable in riak). of the pool settings,
+// only maxOpen and maxIdleTime apply: riak keeps at least one connection, without a lifetime
+func connect(address string, isolation string, pool engine.Pool) any {
+	node := util.Try(riak.NewNode(&riak.NodeOptions{
+		RemoteAddress:  address,
+		MaxConnections: uint16(pool.MaxOpen), // (0 = riak's default)
+		IdleTimeout:    time.Duration(pool.MaxIdleTime * float64(time.Second)),
+	}))
+	cluster := util.Try(riak.NewCluster(&riak.ClusterOptions{Nodes: []*riak.Node{node}}))
+	return util.Try(riak.NewClient(&riak.NewClientOptions{Cluster: cluster}))
+}
+
+func disconnect(connection any) {
+	connection.(*riak.Client).Stop()
+}
+
+// Executes a command, returning as soon as the context is done, even if the command has not
+// completed yet (the client does not support cancellation, so the command is left running)
+func execute(ctx context.Context, client *riak.Client, cmd riak.Command) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- client.Execute(cmd)
+	}()
+
+	select {
+	case err := <-done:
+		if isConnectionError(err) {
+			return fmt.Errorf("%w: %v", engine.ErrConnection, err)
+		}
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Whether an error returned by the client is caused by the connection to the cluster
+func isConnectionError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, riak.ErrCannotRead) || errors.Is(err, riak.ErrCannotWrite) {
+		return true
+	}
+
+	// the client errors do not support unwrapping
+	var clientErr riak.ClientError
+	if errors.As(err, &clientErr) {
+		return clientErr.Errmsg == riak.ErrClusterNoNodesAvailable ||
+			(clientErr.InnerError != nil && isConnectionError(clientErr.InnerError))
+	}
+
+	return false
+}
+
 func (r *Riak) Setup(connections []any) {}
 
 func (r *Riak) Cleanup(connections []any) {}
@@ -58,13 +142,13 @@ func (r *Riak) Populate(connections []any, typesToPopulate []string, itemsPerStr
 		go populateCounters(&wg, client, r.ItemsPerStructure)
 	}
 	if slices.Contains(r.TypesToPopulate, "register") {
-		go populateRegisters(&wg, client, r.ItemsPerStructure, valueLength)
+		go populateRegisters(&wg, client, r.ItemsPerStructure, valueLength, util.NewRand(r.Seed, "populate-register"))
 	}
 	if slices.Contains(r.TypesToPopulate, "set") {
 		go populateSets(&wg, client, r.ItemsPerStructure, r.InitialOpsPerStructure)
 	}
 	if slices.Contains(r.TypesToPopulate, "map") {
-		go populateMaps(&wg, client, r.ItemsPerStructure, r.InitialOpsPerStructure, valueLength)
+		go populateMaps(&wg, client, r.ItemsPerStructure, r.InitialOpsPerStructure, valueLength, util.NewRand(r.Seed, "populate-map"))
 	}
 	wg.Wait()
 
@@ -76,6 +160,13 @@ func (r *Riak) Populate(connections []any, typesToPopulate []string, itemsPerStr
 	initialDbSize = r.storageSize() - beginSize
 }
 
+func (r *Riak) Validate() error {
+	if r.PopulateClient < 0 || r.PopulateClient >= len(r.Connection) {
+		return fmt.Errorf("populateClient %d out of range (%d connections)", r.PopulateClient, len(r.Connection))
+	}
+	return nil
+}
+
 func (r *Riak) Prepare(connection any) {
 	client := connection.(*riak.Client)
 	r.counter = newCounter(client)
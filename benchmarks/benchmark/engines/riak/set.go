@@ -1,8 +1,9 @@
 package riak_engine
 
 import (
+	engine "benchmarks/benchmark/engines/abstract"
 	"benchmarks/util"
-	"errors"
+	"context"
 	"fmt"
 	"strconv"
 	"sync"
@@ -56,9 +57,11 @@ func newSet(client *riak.Client) *Set {
 	return s
 }
 
-func (s *Set) Get(id string) ([]string, error) {
+func (s *Set) Get(ctx context.Context, id string) ([]string, error) {
 	cmd := util.Try(s.getBuilder().WithKey(id).Build())
-	util.CheckErr(s.client.Execute(cmd))
+	if err := execute(ctx, s.client, cmd); err != nil {
+		return nil, err
+	}
 
 	result := []string{}
 	for _, v := range cmd.(*riak.FetchSetCommand).Response.SetValue {
@@ -68,9 +71,11 @@ func (s *Set) Get(id string) ([]string, error) {
 	return result, nil
 }
 
-func (s *Set) Contains(id string, value string) (bool, error) {
+func (s *Set) Contains(ctx context.Context, id string, value string) (bool, error) {
 	cmd := util.Try(s.getBuilder().WithKey(id).Build())
-	util.CheckErr(s.client.Execute(cmd))
+	if err := execute(ctx, s.client, cmd); err != nil {
+		return false, err
+	}
 
 	for _, v := range cmd.(*riak.FetchSetCommand).Response.SetValue {
 		s := string(v)
@@ -82,25 +87,29 @@ func (s *Set) Contains(id string, value string) (bool, error) {
 	return false, nil
 }
 
-func (s *Set) Add(id string, value string) error {
+func (s *Set) Add(ctx context.Context, id string, value string) error {
 	cmd := util.Try(s.updBuilder().WithKey(id).WithAdditions([]byte(value)).Build())
-	util.CheckErr(s.client.Execute(cmd))
+	if err := execute(ctx, s.client, cmd); err != nil {
+		return err
+	}
 	return nil
 }
 
-func (s *Set) Rmv(id string, value string) error {
+func (s *Set) Rmv(ctx context.Context, id string, value string) error {
 	// rmvs of non-existing keys without retrieving the context take a long time, so we first
 	// retrieve it, as recommended by riak
 	// (https://docs.riak.com/riak/kv/2.2.3/developing/data-types/sets/index.html#remove-from-a-set)
 	cmd := util.Try(s.getBuilder().WithKey(id).Build())
-	util.CheckErr(s.client.Execute(cmd))
-	context := cmd.(*riak.FetchSetCommand).Response.Context
+	if err := execute(ctx, s.client, cmd); err != nil {
+		return err
+	}
+	causalContext := cmd.(*riak.FetchSetCommand).Response.Context
 
-	cmd = util.Try(s.updBuilder().WithKey(id).WithRemovals([]byte(value)).WithContext(context).Build())
-	s.client.Execute(cmd)
-	return nil
+	cmd = util.Try(s.updBuilder().WithKey(id).WithRemovals([]byte(value)).WithContext(causalContext).Build())
+	execute(ctx, s.client, cmd)
+	return ctx.Err()
 }
 
-func (s *Set) Clear(id string) error {
-	return errors.New("not implemented")
+func (s *Set) Clear(ctx context.Context, id string) error {
+	return engine.ErrNotImplemented
 }
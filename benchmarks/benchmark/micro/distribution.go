@@ -0,0 +1,169 @@
+package micro
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+)
+
+// Dimensions of the items accessed by the operations: the structure ids, the elements of sets and
+// maps (keys), and the positions in lists (indexes)
+var dimensions = []string{"id", "key", "index"}
+
+var distributionTypes = []string{"uniform", "zipfian", "hotspot", "latest", "sequential"}
+
+// Distribution of the items accessed in a dimension
+type Distribution struct {
+	Type     string   // one of distributionTypes (default: uniform)
+	Theta    *float64 // skew of zipfian and latest, in ]0, 1[ (default: 0.99)
+	HotItems float64  `yaml:"hotItems"` // (hotspot) fraction of the items that are hot
+	HotOps   float64  `yaml:"hotOps"`   // (hotspot) fraction of the operations on the hot items
+}
+
+func (d Distribution) validate() error {
+	switch d.Type {
+	case "", "uniform", "sequential":
+	case "zipfian", "latest":
+		if d.Theta != nil && (*d.Theta <= 0 || *d.Theta >= 1) {
+			return fmt.Errorf("theta must be in ]0, 1[ (got %g)", *d.Theta)
+		}
+	case "hotspot":
+		if d.HotItems <= 0 || d.HotItems >= 1 || d.HotOps < 0 || d.HotOps > 1 {
+			return fmt.Errorf("hotItems must be in ]0, 1[ and hotOps in [0, 1] (got %g, %g)", d.HotItems, d.HotOps)
+		}
+	default:
+		return fmt.Errorf("unknown distribution '%s' (expected one of %v)", d.Type, distributionTypes)
+	}
+	return nil
+}
+
+// Generates item indexes in [0, n), where n is the current number of items (e.g., the length of a
+// list, which changes as the worker inserts and removes elements)
+type generator interface {
+	next(r *rand.Rand, n int) int
+}
+
+func (d Distribution) generator() generator {
+	theta := 0.99
+	if d.Theta != nil {
+		theta = *d.Theta
+	}
+	switch d.Type {
+	case "zipfian":
+		return &zipfian{theta: theta}
+	case "latest":
+		return latest{&zipfian{theta: theta}}
+	case "hotspot":
+		return hotspot{hotItems: d.HotItems, hotOps: d.HotOps}
+	case "sequential":
+		return &sequential{}
+	default:
+		return uniform{}
+	}
+}
+
+type uniform struct{}
+
+func (u uniform) next(r *rand.Rand, n int) int {
+	return r.Intn(n)
+}
+
+// Zipfian distribution where item 0 is the most popular, as in YCSB (Gray et al., "Quickly
+// generating billion-record synthetic databases")
+type zipfian struct {
+	n     int
+	theta float64
+	zetaN float64
+	alpha float64
+	eta   float64
+}
+
+// Partial sums of zeta for each theta (sums[n] = zeta(n, theta)), shared by the generators of all
+// workers and extended as the number of items grows. a generator follows the sizes of several
+// structures (e.g., the lengths of all lists), so n may change by many items at each operation
+var zetaSums = struct {
+	sync.RWMutex
+	sums map[float64][]float64
+}{sums: map[float64][]float64{}}
+
+func zeta(n int, theta float64) float64 {
+	zetaSums.RLock()
+	sums := zetaSums.sums[theta]
+	zetaSums.RUnlock()
+	if n < len(sums) {
+		return sums[n]
+	}
+
+	zetaSums.Lock()
+	defer zetaSums.Unlock()
+	sums = zetaSums.sums[theta]
+	if len(sums) == 0 {
+		sums = []float64{0}
+	}
+	for i := len(sums); i <= n; i++ {
+		sums = append(sums, sums[i-1]+1/math.Pow(float64(i), theta))
+	}
+	zetaSums.sums[theta] = sums
+	return sums[n]
+}
+
+// Adjusts the distribution to n items, in constant time once zeta(n, theta) is known
+func (z *zipfian) resize(n int) {
+	if n == z.n {
+		return
+	}
+	z.zetaN = zeta(n, z.theta)
+	z.n = n
+	z.alpha = 1 / (1 - z.theta)
+	z.eta = (1 - math.Pow(2/float64(n), 1-z.theta)) / (1 - zeta(2, z.theta)/z.zetaN)
+}
+
+func (z *zipfian) next(r *rand.Rand, n int) int {
+	z.resize(n)
+	u := r.Float64()
+	uz := u * z.zetaN
+	if uz < 1 || z.n == 1 {
+		return 0
+	}
+	if uz < 1+math.Pow(0.5, z.theta) {
+		return 1
+	}
+	return min(z.n-1, int(float64(z.n)*math.Pow(z.eta*u-z.eta+1, z.alpha)))
+}
+
+// Zipfian distribution where the last item is the most popular. as n follows the items inserted by
+// the worker, these are the most recently inserted ones (or the current tail of a list), as in YCSB
+type latest struct {
+	z *zipfian
+}
+
+func (l latest) next(r *rand.Rand, n int) int {
+	return n - 1 - l.z.next(r, n)
+}
+
+// hotOps of the operations access the first hotItems of the items, the others access the remaining
+// ones
+type hotspot struct {
+	hotItems float64
+	hotOps   float64
+}
+
+func (h hotspot) next(r *rand.Rand, n int) int {
+	hot := max(1, int(float64(n)*h.hotItems))
+	if hot >= n || r.Float64() < h.hotOps {
+		return r.Intn(hot)
+	}
+	return hot + r.Intn(n-hot)
+}
+
+// Cycles through the items in order (each worker starts at the first one)
+type sequential struct {
+	curr int
+}
+
+func (s *sequential) next(_ *rand.Rand, n int) int {
+	i := s.curr % n
+	s.curr = i + 1
+	return i
+}
@@ -0,0 +1,225 @@
+package micro
+
+import (
+	"benchmarks/testutil"
+	"context"
+	"math"
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+func TestValidateDistribution(t *testing.T) {
+	tests := []struct {
+		distribution Distribution
+		valid        bool
+	}{
+		{Distribution{}, true},
+		{Distribution{Type: "sequential"}, true},
+		{Distribution{Type: "zipfian"}, true},
+		{Distribution{Type: "hotspot", HotItems: 0.2, HotOps: 0.8}, true},
+		{Distribution{Type: "hotspot", HotItems: 0, HotOps: 0.8}, false},
+		{Distribution{Type: "hotspot", HotItems: 0.2, HotOps: 1.5}, false},
+		{Distribution{Type: "gaussian"}, false},
+	}
+	for _, test := range tests {
+		if err := test.distribution.validate(); (err == nil) != test.valid {
+			t.Errorf("%+v: unexpected validation result %v", test.distribution, err)
+		}
+	}
+}
+
+// Counts the items generated by a distribution over n items, checking that they are in range
+func sample(t *testing.T, d Distribution, n int, samples int) []int {
+	r := rand.New(rand.NewSource(1))
+	g := d.generator()
+	counts := make([]int, n)
+	for i := 0; i < samples; i++ {
+		item := g.next(r, n)
+		if item < 0 || item >= n {
+			t.Fatalf("%s: item %d out of [0, %d)", d.Type, item, n)
+		}
+		counts[item]++
+	}
+	return counts
+}
+
+func TestSkewedDistributions(t *testing.T) {
+	zipfian := sample(t, Distribution{Type: "zipfian"}, 1000, 10000)
+	if zipfian[0] < zipfian[1] || zipfian[1] < zipfian[10] || zipfian[0] < 1000 {
+		t.Errorf("expected the first items to be the most popular, got %v", zipfian[:11])
+	}
+
+	latest := sample(t, Distribution{Type: "latest"}, 1000, 10000)
+	if latest[999] < latest[998] || latest[998] < latest[989] || latest[999] < 1000 {
+		t.Errorf("expected the last items to be the most popular, got %v", latest[989:])
+	}
+
+	hot := 0
+	for _, count := range sample(t, Distribution{Type: "hotspot", HotItems: 0.1, HotOps: 0.9}, 1000, 10000)[:100] {
+		hot += count
+	}
+	if hot < 8800 || hot > 9200 {
+		t.Errorf("expected 90%% of the operations on the hot items, got %d of 10000", hot)
+	}
+}
+
+func TestSequential(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	g := Distribution{Type: "sequential"}.generator()
+	for i := 0; i < 7; i++ {
+		if item := g.next(r, 3); item != i%3 {
+			t.Fatalf("expected item %d, got %d", i%3, item)
+		}
+	}
+}
+
+func TestValidateTheta(t *testing.T) {
+	theta := func(v float64) *float64 { return &v }
+	tests := []struct {
+		theta *float64
+		valid bool
+	}{
+		{nil, true},
+		{theta(0.5), true},
+		{theta(0.99), true},
+		{theta(0), false},
+		{theta(1), false},
+		{theta(-0.5), false},
+	}
+	for _, test := range tests {
+		for _, d := range []string{"zipfian", "latest"} {
+			if err := (Distribution{Type: d, Theta: test.theta}).validate(); (err == nil) != test.valid {
+				t.Errorf("%s with theta %v: unexpected validation result %v", d, test.theta, err)
+			}
+		}
+	}
+}
+
+// the incremental zeta of a resized zipfian matches the one computed from scratch
+func TestZipfianResize(t *testing.T) {
+	z := &zipfian{theta: 0.99}
+	for _, n := range []int{100, 150, 120, 1000, 10} {
+		z.resize(n)
+		expected := 0.
+		for i := 1; i <= n; i++ {
+			expected += 1 / math.Pow(float64(i), 0.99)
+		}
+		if diff := z.zetaN - expected; diff > 1e-9 || diff < -1e-9 {
+			t.Errorf("zeta(%d) = %v after resizing, expected %v", n, z.zetaN, expected)
+		}
+	}
+}
+
+func TestLatestFollowsTheNumberOfItems(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	g := Distribution{Type: "latest"}.generator()
+	for _, n := range []int{10, 11, 1000, 2000} {
+		recent := 0
+		for i := 0; i < 1000; i++ {
+			item := g.next(r, n)
+			if item < 0 || item >= n {
+				t.Fatalf("item %d out of [0, %d)", item, n)
+			}
+			if item >= n/2 {
+				recent++
+			}
+		}
+		if recent < 700 {
+			t.Errorf("expected most items in the second half of %d, got %d of 1000", n, recent)
+		}
+	}
+}
+
+const latestConfig = `
+engine: test
+seed: 1
+itemsPerStructure: 1
+initialOpsPerStructure: 100
+distributions:
+  key: {type: latest}
+  index: {type: latest}
+`
+
+// Draws the arguments of an operation and executes it
+func execute(t *testing.T, m *Micro, operations map[string]func(context.Context) error, op string) {
+	m.Draw(op)
+	if err := operations[op](context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLatestInsertsNewKeys(t *testing.T) {
+	m := New(0, []byte(latestConfig))
+	operations := m.Prepare(nil)
+	for i := 0; i < 50; i++ {
+		execute(t, m, operations, "mapAdd")
+		if m.LastArgs().Key != strconv.Itoa(100+i) {
+			t.Fatalf("expected insertion %d to add key %d, got %s", i, 100+i, m.LastArgs().Key)
+		}
+	}
+	// the reads favor the most recently inserted keys
+	recent := 0
+	for i := 0; i < 1000; i++ {
+		m.Draw("mapValue")
+		key, _ := strconv.Atoi(m.LastArgs().Key)
+		if key >= 150 {
+			t.Fatalf("read of key %d, which was not inserted", key)
+		}
+		if key >= 140 {
+			recent++
+		}
+	}
+	if recent < 500 {
+		t.Errorf("expected most reads on the last inserted keys, got %d of 1000", recent)
+	}
+}
+
+func TestLatestFollowsTheListLength(t *testing.T) {
+	m := New(0, []byte(latestConfig))
+	operations := m.Prepare(nil)
+	for i := 0; i < 10; i++ {
+		execute(t, m, operations, "listAppend")
+	}
+	execute(t, m, operations, "listRmv")
+	// 109 elements, the last ones most accessed
+	maxIndex := 0
+	for i := 0; i < 1000; i++ {
+		m.Draw("listGetAt")
+		maxIndex = max(maxIndex, m.LastArgs().Index)
+	}
+	if maxIndex != 108 {
+		t.Errorf("expected the last index accessed to be 108, got %d", maxIndex)
+	}
+}
+
+func TestListLengthCountsCommittedOperations(t *testing.T) {
+	testutil.Instance.Reset()
+	m := New(0, []byte(latestConfig+"txTemplates:\n- {name: cart, operations: [mapAdd, counterInc]}\n"))
+	operations := m.Prepare(nil)
+	// the insertion is only counted once its transaction commits, after a retry
+	m.Draw("cart")
+	testutil.Instance.FailNextTx()
+	if err := operations["cart"](context.Background()); err == nil {
+		t.Fatal("expected the first transaction to fail")
+	}
+	if err := operations["cart"](context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// an insertion drawn but not executed is not counted
+	m.Draw("mapAdd")
+	execute(t, m, operations, "mapAdd")
+	if key := m.LastArgs().Key; key != "101" {
+		t.Errorf("expected the second committed insertion to add key 101, got %s", key)
+	}
+
+	// without latest nor sequential distributions, the sizes are not tracked
+	m = New(0, []byte("engine: test\nseed: 1\nitemsPerStructure: 1\ninitialOpsPerStructure: 100\n"))
+	operations = m.Prepare(nil)
+	for i := 0; i < 10; i++ {
+		execute(t, m, operations, "listAppend")
+	}
+	if len(m.added) != 0 {
+		t.Errorf("expected no sizes tracked, got %v", m.added)
+	}
+}
@@ -2,14 +2,14 @@ package micro
 
 import (
 	engine "benchmarks/benchmark/engines/abstract"
-	"benchmarks/benchmark/engines/crdv"
-	"benchmarks/benchmark/engines/electric"
-	"benchmarks/benchmark/engines/native"
-	"benchmarks/benchmark/engines/pg_crdt"
-	riak_engine "benchmarks/benchmark/engines/riak"
+	"benchmarks/trace"
 	"benchmarks/util"
+	"context"
+	"fmt"
 	"math/rand"
+	"slices"
 	"strconv"
+	"strings"
 
 	zlog "github.com/rs/zerolog/log"
 	"gopkg.in/yaml.v3"
@@ -22,31 +22,126 @@ type Micro struct {
 	TypesToPopulate        []string `yaml:"typesToPopulate"`
 	EngineName             string   `yaml:"engine"`
 	engine                 engine.Engine
+	registration           *engine.Registration
 	ValueLength            int `yaml:"valueLength"`
+
+	Seed int64      `yaml:"seed"`
+	rand *rand.Rand // keys and values of the worker's operations
+
+	// distribution of the items accessed in each dimension (see dimensions), which operations can
+	// override
+	Distributions map[string]Distribution
+	Operations    []struct {
+		Name          string
+		Distributions map[string]Distribution
+	}
+	generators map[string]generator // "operation.dimension" -> generator
+	added      map[string]int       // "dimension/structure id" -> elements added by the worker (see size)
+	pending    map[string]int       // elements added by the current operation, counted once it commits
+	args       trace.Args           // arguments of the current operation (see Draw)
+	txArgs     []trace.Args         // arguments of each operation of the current transaction template
+
+	// operations executed together in a transaction, issued by name (see TxTemplate)
+	TxTemplates []TxTemplate `yaml:"txTemplates"`
 }
 
 func New(id int, configData []byte) *Micro {
 	micro := Micro{}
 	util.CheckErr(yaml.Unmarshal(configData, &micro))
 	micro.id = id
+	micro.generators = map[string]generator{}
+	micro.added = map[string]int{}
+	micro.pending = map[string]int{}
+	micro.rand = util.NewRand(micro.Seed, fmt.Sprintf("benchmark-%d", id))
 
-	if micro.EngineName == "crdv" {
-		micro.engine = crdv.New(id, configData)
-	} else if micro.EngineName == "native" {
-		micro.engine = native.New(id, configData)
-	} else if micro.EngineName == "electric" {
-		micro.engine = electric.New(id, configData)
-	} else if micro.EngineName == "pg_crdt" {
-		micro.engine = pg_crdt.New(id, configData)
-	} else if micro.EngineName == "riak" {
-		micro.engine = riak_engine.New(id, configData)
-	} else {
-		panic("Unknown engine: " + micro.EngineName)
+	micro.registration = util.Try(engine.LookupEngine(micro.EngineName))
+	if micro.registration.New == nil {
+		panic("Engine '" + micro.EngineName + "' does not implement the data types")
 	}
+	micro.engine = micro.registration.New(id, configData)
 
 	return &micro
 }
 
+// Checks the operations against the engine's capabilities (e.g., "setAdd" requires set.Add)
+func (m *Micro) Validate(operations []string) error {
+	single := []string{}
+	for _, op := range operations {
+		if m.txTemplate(op) == nil {
+			single = append(single, op)
+		}
+	}
+	if err := m.validateOperations(single); err != nil {
+		return err
+	}
+	if err := m.validateTxTemplates(operations); err != nil {
+		return err
+	}
+
+	if m.ItemsPerStructure < 1 || m.ValueLength < 0 {
+		return fmt.Errorf("itemsPerStructure must be at least 1, and valueLength non-negative")
+	}
+	for _, op := range append(single, m.txOperations(operations)...) {
+		args := operationArgs[op]
+		if m.InitialOpsPerStructure < 1 && (slices.Contains(args, "key") || slices.Contains(args, "index")) {
+			return fmt.Errorf("initialOpsPerStructure must be at least 1 for operation '%s'", op)
+		}
+	}
+	for _, t := range m.TypesToPopulate {
+		if _, ok := idPrefixes[t]; !ok {
+			return fmt.Errorf("unknown type to populate '%s'", t)
+		}
+	}
+	if v, ok := m.engine.(engine.Validator); ok {
+		if err := v.Validate(); err != nil {
+			return fmt.Errorf("engine '%s': %w", m.EngineName, err)
+		}
+	}
+
+	if err := validateDistributions(m.Distributions); err != nil {
+		return err
+	}
+	for _, op := range m.Operations {
+		if err := validateDistributions(op.Distributions); err != nil {
+			return fmt.Errorf("operation '%s': %w", op.Name, err)
+		}
+	}
+	return nil
+}
+
+func (m *Micro) validateOperations(operations []string) error {
+	for _, op := range operations {
+		supported := false
+		for dataType := range engine.Methods {
+			method, ok := strings.CutPrefix(op, dataType)
+			if ok && m.registration.Supports(dataType, method) {
+				supported = true
+				break
+			}
+		}
+		if !supported {
+			return fmt.Errorf("operation '%s' is not supported by engine '%s'", op, m.EngineName)
+		}
+	}
+	return nil
+}
+
+func validateDistributions(distributions map[string]Distribution) error {
+	for dimension, d := range distributions {
+		if !slices.Contains(dimensions, dimension) {
+			return fmt.Errorf("unknown distribution dimension '%s' (expected one of %v)", dimension, dimensions)
+		}
+		if err := d.validate(); err != nil {
+			return fmt.Errorf("%s distribution: %w", dimension, err)
+		}
+	}
+	return nil
+}
+
+func (m *Micro) ConfigStructs() []any {
+	return []any{m, m.engine}
+}
+
 func (m *Micro) log(msg string) {
 	zlog.Info().Str("benchmark", "micro").Int("id", m.id).Msg(msg)
 }
@@ -61,23 +156,144 @@ func (m *Micro) Populate(connections []any) {
 	m.log("Populate done")
 }
 
-func (m *Micro) randomId(prefix string) string {
-	return prefix + "-" + strconv.Itoa(rand.Intn(m.ItemsPerStructure))
+// Returns the distribution of a dimension accessed by an operation
+func (m *Micro) distribution(op string, dimension string) Distribution {
+	d := m.Distributions[dimension]
+	for _, o := range m.Operations {
+		if override, ok := o.Distributions[dimension]; ok && o.Name == op {
+			d = override
+		}
+	}
+	return d
+}
+
+// Returns the next item of a dimension accessed by an operation, among n items
+func (m *Micro) next(op string, dimension string, n int) int {
+	key := op + "." + dimension
+	g, ok := m.generators[key]
+	if !ok {
+		g = m.distribution(op, dimension).generator()
+		m.generators[key] = g
+	}
+	return g.next(m.rand, n)
+}
+
+// Operations that add a key to a set or map, and the change in the length of a list made by each
+// operation
+var keyInsertions = []string{"setAdd", "mapAdd"}
+var listChanges = map[string]int{"listAdd": 1, "listAppend": 1, "listPrepend": 1, "listRmv": -1}
+
+// Whether the number of elements of the structures is tracked in a dimension, which only the latest
+// and sequential distributions follow
+func (m *Micro) tracks(dimension string) bool {
+	types := []string{m.Distributions[dimension].Type}
+	for _, o := range m.Operations {
+		types = append(types, o.Distributions[dimension].Type)
+	}
+	return slices.Contains(types, "latest") || slices.Contains(types, "sequential")
+}
+
+// Number of elements of a structure in a dimension (keys or indexes): the initial ones, and those
+// added since by the worker's committed operations and the current one (those of the other workers
+// are not seen). a list has at least one element, as far as the worker knows
+func (m *Micro) size(dimension string, id string) int {
+	key := dimension + "/" + id
+	return max(m.InitialOpsPerStructure+m.added[key]+m.pending[key], 1)
+}
+
+// Counts the elements added by the current operation, once it commits
+func (m *Micro) commit() {
+	for key, change := range m.pending {
+		m.added[key] = max(m.added[key]+change, 1-m.InitialOpsPerStructure)
+	}
+	clear(m.pending)
+}
+
+func (m *Micro) randomId(op string, prefix string) string {
+	return prefix + "-" + strconv.Itoa(m.next(op, "id", m.ItemsPerStructure))
 }
 
 func (m *Micro) randomValue() string {
-	return util.RandomString(m.ValueLength)
+	return util.RandomString(m.rand, m.ValueLength)
+}
+
+// With the latest distribution, an insertion adds a new key (the next one of the structure), so the
+// other operations following latest access the most recently inserted keys
+func (m *Micro) randomKey(op string, id string) string {
+	n := m.size("key", id)
+	if slices.Contains(keyInsertions, op) && m.distribution(op, "key").Type == "latest" {
+		m.pending["key/"+id]++
+		return strconv.Itoa(n)
+	}
+	return strconv.Itoa(m.next(op, "key", n))
+}
+
+func (m *Micro) randomIndex(op string, id string) int {
+	return m.next(op, "index", m.size("index", id))
+}
+
+// Prefix of the structure ids of each data type
+var idPrefixes = map[string]string{"counter": "c", "register": "r", "set": "s", "map": "m", "list": "l"}
+
+// Arguments of each operation, besides the structure id
+var operationArgs = map[string][]string{
+	"counterInc":  {"delta"},
+	"counterDec":  {"delta"},
+	"registerSet": {"value"},
+	"setContains": {"key"},
+	"setAdd":      {"key"},
+	"setRmv":      {"key"},
+	"mapValue":    {"key"},
+	"mapContains": {"key"},
+	"mapAdd":      {"key", "value"},
+	"mapRmv":      {"key"},
+	"listGetAt":   {"index"},
+	"listAdd":     {"index", "value"},
+	"listAppend":  {"value"},
+	"listPrepend": {"value"},
+	"listRmv":     {"index"},
 }
 
-func (m *Micro) randomKey() string {
-	return strconv.Itoa(rand.Intn(m.InitialOpsPerStructure))
+// Returns the data type of an operation (e.g., "set" for "setAdd"), or "" if unknown
+func dataTypeOf(op string) string {
+	for dataType := range idPrefixes {
+		if strings.HasPrefix(op, dataType) {
+			return dataType
+		}
+	}
+	return ""
 }
 
-func (m *Micro) randomIndex() int {
-	return rand.Intn(m.InitialOpsPerStructure)
+// Returns random arguments for an operation, following the distributions. the structure accessed
+// is the given item, if >= 0
+func (m *Micro) randomArgs(op string, item int) trace.Args {
+	a := trace.Args{}
+	if prefix, ok := idPrefixes[dataTypeOf(op)]; ok && item >= 0 {
+		a.Id = prefix + "-" + strconv.Itoa(item)
+	} else if ok {
+		a.Id = m.randomId(op, prefix)
+	}
+	for _, arg := range operationArgs[op] {
+		switch arg {
+		case "delta":
+			a.Index = m.rand.Intn(10) + 1
+		case "key":
+			a.Key = m.randomKey(op, a.Id)
+		case "index":
+			a.Index = m.randomIndex(op, a.Id)
+		case "value":
+			a.Value = m.randomValue()
+		}
+	}
+	if change, ok := listChanges[op]; ok && m.tracks("index") {
+		m.pending["index/"+a.Id] += change
+	}
+	return a
 }
 
-func (m *Micro) Prepare(connection any) map[string]func() error {
+// Prepares the operations with explicit arguments (e.g., replayed from a trace) instead of random
+// ones
+func (m *Micro) PrepareArgs(connection any) map[string]func(context.Context, trace.Args) error {
 	m.engine.Prepare(connection)
 
 	counter := m.engine.GetCounter()
@@ -86,49 +302,86 @@ func (m *Micro) Prepare(connection any) map[string]func() error {
 	map_ := m.engine.GetMap()
 	list := m.engine.GetList()
 
-	operations := map[string]func() error{}
+	operations := map[string]func(context.Context, trace.Args) error{}
 
 	if counter != nil {
-		operations["counterGet"] = func() error { return util.Second(counter.Get(m.randomId("c"))) }
-		operations["counterInc"] = func() error { return counter.Inc(m.randomId("c"), rand.Intn(10)+1) }
-		operations["counterDec"] = func() error { return counter.Dec(m.randomId("c"), rand.Intn(10)+1) }
+		operations["counterGet"] = func(ctx context.Context, a trace.Args) error { return util.Second(counter.Get(ctx, a.Id)) }
+		operations["counterInc"] = func(ctx context.Context, a trace.Args) error { return counter.Inc(ctx, a.Id, a.Index) }
+		operations["counterDec"] = func(ctx context.Context, a trace.Args) error { return counter.Dec(ctx, a.Id, a.Index) }
 	}
 
 	if register != nil {
-		operations["registerGet"] = func() error { return util.Second(register.Get(m.randomId("r"))) }
-		operations["registerSet"] = func() error { return register.Set(m.randomId("r"), m.randomValue()) }
+		operations["registerGet"] = func(ctx context.Context, a trace.Args) error { return util.Second(register.Get(ctx, a.Id)) }
+		operations["registerSet"] = func(ctx context.Context, a trace.Args) error { return register.Set(ctx, a.Id, a.Value) }
 	}
 
 	if set != nil {
-		operations["setGet"] = func() error { return util.Second(set.Get(m.randomId("s"))) }
-		operations["setContains"] = func() error { return util.Second(set.Contains(m.randomId("s"), m.randomKey())) }
-		operations["setAdd"] = func() error { return set.Add(m.randomId("s"), m.randomKey()) }
-		operations["setRmv"] = func() error { return set.Rmv(m.randomId("s"), m.randomKey()) }
-		operations["setClear"] = func() error { return set.Clear(m.randomId("s")) }
+		operations["setGet"] = func(ctx context.Context, a trace.Args) error { return util.Second(set.Get(ctx, a.Id)) }
+		operations["setContains"] = func(ctx context.Context, a trace.Args) error {
+			return util.Second(set.Contains(ctx, a.Id, a.Key))
+		}
+		operations["setAdd"] = func(ctx context.Context, a trace.Args) error { return set.Add(ctx, a.Id, a.Key) }
+		operations["setRmv"] = func(ctx context.Context, a trace.Args) error { return set.Rmv(ctx, a.Id, a.Key) }
+		operations["setClear"] = func(ctx context.Context, a trace.Args) error { return set.Clear(ctx, a.Id) }
 	}
 
 	if map_ != nil {
-		operations["mapGet"] = func() error { return util.Second(map_.Get(m.randomId("m"))) }
-		operations["mapValue"] = func() error { return util.Second(map_.Value(m.randomId("m"), m.randomKey())) }
-		operations["mapContains"] = func() error { return util.Second(map_.Contains(m.randomId("m"), m.randomKey())) }
-		operations["mapAdd"] = func() error { return map_.Add(m.randomId("m"), m.randomKey(), m.randomValue()) }
-		operations["mapRmv"] = func() error { return map_.Rmv(m.randomId("m"), m.randomKey()) }
-		operations["mapClear"] = func() error { return map_.Clear(m.randomId("m")) }
+		operations["mapGet"] = func(ctx context.Context, a trace.Args) error { return util.Second(map_.Get(ctx, a.Id)) }
+		operations["mapValue"] = func(ctx context.Context, a trace.Args) error { return util.Second(map_.Value(ctx, a.Id, a.Key)) }
+		operations["mapContains"] = func(ctx context.Context, a trace.Args) error {
+			return util.Second(map_.Contains(ctx, a.Id, a.Key))
+		}
+		operations["mapAdd"] = func(ctx context.Context, a trace.Args) error { return map_.Add(ctx, a.Id, a.Key, a.Value) }
+		operations["mapRmv"] = func(ctx context.Context, a trace.Args) error { return map_.Rmv(ctx, a.Id, a.Key) }
+		operations["mapClear"] = func(ctx context.Context, a trace.Args) error { return map_.Clear(ctx, a.Id) }
 	}
 
 	if list != nil {
-		operations["listGet"] = func() error { return util.Second(list.Get(m.randomId("l"))) }
-		operations["listGetAt"] = func() error { return util.Second(list.GetAt(m.randomId("l"), m.randomIndex())) }
-		operations["listAdd"] = func() error { return list.Add(m.randomId("l"), m.randomIndex(), m.randomValue()) }
-		operations["listAppend"] = func() error { return list.Append(m.randomId("l"), m.randomValue()) }
-		operations["listPrepend"] = func() error { return list.Prepend(m.randomId("l"), m.randomValue()) }
-		operations["listRmv"] = func() error { return list.Rmv(m.randomId("l"), m.randomIndex()) }
-		operations["listClear"] = func() error { return list.Clear(m.randomId("l")) }
+		operations["listGet"] = func(ctx context.Context, a trace.Args) error { return util.Second(list.Get(ctx, a.Id)) }
+		operations["listGetAt"] = func(ctx context.Context, a trace.Args) error { return util.Second(list.GetAt(ctx, a.Id, a.Index)) }
+		operations["listAdd"] = func(ctx context.Context, a trace.Args) error { return list.Add(ctx, a.Id, a.Index, a.Value) }
+		operations["listAppend"] = func(ctx context.Context, a trace.Args) error { return list.Append(ctx, a.Id, a.Value) }
+		operations["listPrepend"] = func(ctx context.Context, a trace.Args) error { return list.Prepend(ctx, a.Id, a.Value) }
+		operations["listRmv"] = func(ctx context.Context, a trace.Args) error { return list.Rmv(ctx, a.Id, a.Index) }
+		operations["listClear"] = func(ctx context.Context, a trace.Args) error { return list.Clear(ctx, a.Id) }
 	}
 
 	return operations
 }
 
+func (m *Micro) Prepare(connection any) map[string]func(context.Context) error {
+	operations := map[string]func(context.Context) error{}
+	functions := m.PrepareArgs(connection)
+	for name, f := range functions {
+		name, f := name, f
+		operations[name] = func(ctx context.Context) error {
+			err := f(ctx, m.args)
+			if err == nil {
+				m.commit()
+			}
+			return err
+		}
+	}
+	m.prepareTxTemplates(functions, operations)
+	return operations
+}
+
+// Draws the random arguments of the next operation, reused by all its attempts (see
+// benchmark.Randomized)
+func (m *Micro) Draw(op string) {
+	clear(m.pending)
+	if t := m.txTemplate(op); t != nil {
+		m.drawTx(t)
+		return
+	}
+	m.args = m.randomArgs(op, -1)
+}
+
+// Returns the arguments of the last operation (see benchmark.Traceable)
+func (m *Micro) LastArgs() trace.Args {
+	return m.args
+}
+
 func (m *Micro) GetConfigs() map[string]string {
 	configs := m.engine.GetConfigs()
 	configs["initialOpsPerStructure"] = strconv.Itoa(m.InitialOpsPerStructure)
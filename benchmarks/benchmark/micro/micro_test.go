@@ -0,0 +1,105 @@
+package micro
+
+import (
+	"benchmarks/testutil"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const config = `
+engine: test
+seed: 1
+itemsPerStructure: 1000
+initialOpsPerStructure: 1000
+valueLength: 8
+txTemplates:
+- {name: cart, operations: [mapAdd, counterInc]}
+`
+
+func TestTxTemplate(t *testing.T) {
+	testutil.Instance.Reset()
+	m := New(0, []byte(config))
+	if err := m.Validate([]string{"cart", "mapValue"}); err != nil {
+		t.Fatal(err)
+	}
+	operations := m.Prepare(nil)
+
+	m.Draw("cart")
+	if err := operations["cart"](context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	calls := testutil.Instance.Calls()
+	if len(calls) != 2 || !strings.HasPrefix(calls[0], "add m-") || !strings.HasPrefix(calls[1], "inc c-") {
+		t.Fatalf("expected the operations of the template in order, got %v", calls)
+	}
+	// all the operations access the structures with the same index
+	var item string
+	fmt.Sscanf(calls[0], "add m-%s", &item)
+	if !strings.HasPrefix(calls[1], "inc c-"+item+" ") {
+		t.Errorf("expected the same item in the whole transaction, got %v", calls)
+	}
+	if m.LastArgs().Id != "c-"+item {
+		t.Errorf("expected the arguments of the last operation, got %+v", m.LastArgs())
+	}
+}
+
+func TestValidateTxTemplates(t *testing.T) {
+	tests := []struct {
+		name      string
+		templates string
+		error     string
+	}{
+		{"unnamed", "- {operations: [mapAdd]}", "without a name"},
+		{"repeated", "- {name: cart, operations: [mapAdd]}\n- {name: cart, operations: [mapAdd]}", "repeated"},
+		{"operation name", "- {name: mapAdd, operations: [mapAdd]}", "has the name of an operation"},
+		{"empty", "- {name: cart}", "without operations"},
+		{"unsupported operation", "- {name: cart, operations: [setAdd]}", "setAdd"},
+	}
+	for _, test := range tests {
+		m := New(0, []byte("engine: test\ntxTemplates:\n"+test.templates))
+		if err := m.Validate(nil); err == nil || !strings.Contains(err.Error(), test.error) {
+			t.Errorf("%s: expected an error with %q, got %v", test.name, test.error, err)
+		}
+	}
+}
+
+func TestAttemptsReuseTheArguments(t *testing.T) {
+	testutil.Instance.Reset()
+	m := New(0, []byte(config))
+	operations := m.Prepare(nil)
+
+	m.Draw("mapAdd")
+	for attempt := 0; attempt < 2; attempt++ {
+		if err := operations["mapAdd"](context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	calls := testutil.Instance.Calls()
+	if len(calls) != 2 || calls[0] != calls[1] {
+		t.Errorf("expected the same arguments in both attempts, got %v", calls)
+	}
+	if a := m.LastArgs(); a.Key == "" || calls[0] != fmt.Sprintf("add %s %s %s", a.Id, a.Key, a.Value) {
+		t.Errorf("expected the recorded arguments %+v to be the ones executed, got %v", a, calls)
+	}
+}
+
+func TestRetriedTransactionReusesTheArguments(t *testing.T) {
+	testutil.Instance.Reset()
+	m := New(0, []byte(config))
+	operations := m.Prepare(nil)
+
+	m.Draw("cart")
+	testutil.Instance.FailNextTx()
+	if err := operations["cart"](context.Background()); err == nil {
+		t.Fatal("expected the first transaction to fail")
+	}
+	if err := operations["cart"](context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	calls := testutil.Instance.Calls()
+	if len(calls) != 4 || calls[0] != calls[2] || calls[1] != calls[3] {
+		t.Fatalf("expected the retry to repeat the transaction, got %v", calls)
+	}
+}
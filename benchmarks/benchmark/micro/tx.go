@@ -0,0 +1,112 @@
+package micro
+
+import (
+	engine "benchmarks/benchmark/engines/abstract"
+	"benchmarks/trace"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Sequence of operations executed in a single transaction, issued by its name like the other
+// operations (e.g., adding an item to a cart: mapAdd, counterInc, setAdd). all the operations
+// access the structures with the same index (e.g., m-5, c-5, and s-5), chosen by the template's id
+// distribution
+type TxTemplate struct {
+	Name       string
+	Operations []string
+}
+
+func (m *Micro) txTemplate(name string) *TxTemplate {
+	for i, t := range m.TxTemplates {
+		if t.Name == name {
+			return &m.TxTemplates[i]
+		}
+	}
+	return nil
+}
+
+// Returns the operations of the templates among the given operations
+func (m *Micro) txOperations(operations []string) []string {
+	result := []string{}
+	for _, op := range operations {
+		if t := m.txTemplate(op); t != nil {
+			result = append(result, t.Operations...)
+		}
+	}
+	return result
+}
+
+// Checks the templates, and that the engine runs transactions if some of the operations issued is
+// a template
+func (m *Micro) validateTxTemplates(operations []string) error {
+	names := []string{}
+	for _, t := range m.TxTemplates {
+		if t.Name == "" {
+			return fmt.Errorf("transaction template without a name")
+		}
+		if slices.Contains(names, t.Name) {
+			return fmt.Errorf("repeated transaction template '%s'", t.Name)
+		}
+		names = append(names, t.Name)
+		if dataType := dataTypeOf(t.Name); slices.Contains(engine.Methods[dataType], strings.TrimPrefix(t.Name, dataType)) {
+			return fmt.Errorf("transaction template '%s' has the name of an operation", t.Name)
+		}
+		if len(t.Operations) == 0 {
+			return fmt.Errorf("transaction template '%s' without operations", t.Name)
+		}
+		if err := m.validateOperations(t.Operations); err != nil {
+			return fmt.Errorf("transaction template '%s': %w", t.Name, err)
+		}
+	}
+
+	if _, ok := m.engine.(engine.Transactional); !ok {
+		for _, op := range operations {
+			if m.txTemplate(op) != nil {
+				return fmt.Errorf("transaction template '%s' is unsupported: engine '%s' does not run transactions",
+					op, m.EngineName)
+			}
+		}
+	}
+	return nil
+}
+
+// Adds the templates to the operations, if the engine runs transactions. a retry reruns the same
+// transaction, with the arguments drawn by drawTx
+func (m *Micro) prepareTxTemplates(functions map[string]func(context.Context, trace.Args) error,
+	operations map[string]func(context.Context) error,
+) {
+	tx, ok := m.engine.(engine.Transactional)
+	if !ok {
+		return
+	}
+	for _, t := range m.TxTemplates {
+		t := t
+		operations[t.Name] = func(ctx context.Context) error {
+			err := tx.InTx(ctx, func(ctx context.Context) error {
+				for i, op := range t.Operations {
+					if err := functions[op](ctx, m.txArgs[i]); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err == nil {
+				m.commit()
+			}
+			return err
+		}
+	}
+}
+
+// Draws the item and the arguments of each operation of the next transaction of a template. the
+// last ones are also the operation's arguments in traces
+func (m *Micro) drawTx(t *TxTemplate) {
+	item := m.next(t.Name, "id", m.ItemsPerStructure)
+	m.txArgs = m.txArgs[:0]
+	for _, op := range t.Operations {
+		m.txArgs = append(m.txArgs, m.randomArgs(op, item))
+	}
+	m.args = m.txArgs[len(m.txArgs)-1]
+}
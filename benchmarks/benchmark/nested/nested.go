@@ -1,8 +1,11 @@
 package nested
 
 import (
+	"benchmarks/benchmark"
 	dbutils "benchmarks/dbUtils"
+	"benchmarks/histogram"
 	"benchmarks/util"
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -28,6 +31,10 @@ type Nested struct {
 	PopulateBatchSize int     `yaml:"populateBatchSize"`
 	readStmt          *sql.Stmt
 	explainStmt       *sql.Stmt
+	settings          []dbutils.Settings // of each site before the benchmark (see Finalize)
+
+	Seed int64 `yaml:"seed"`
+	rand *rand.Rand
 }
 
 var ids []string
@@ -35,13 +42,14 @@ var totalPlanRt *atomic.Int64 // micro seconds
 var totalExecRt *atomic.Int64 // micro seconds
 var totalCount *atomic.Int64
 var rtsMutex *sync.Mutex
-var planRts []float64 // micro seconds
-var execRts []float64 // micro seconds
+var planRts *histogram.Histogram
+var execRts *histogram.Histogram
 
 func New(id int, configData []byte) *Nested {
 	delay := Nested{}
 	delay.id = id
 	util.CheckErr(yaml.Unmarshal(configData, &delay))
+	delay.rand = util.NewRand(delay.Seed, fmt.Sprintf("benchmark-%d", id))
 	return &delay
 }
 
@@ -49,13 +57,34 @@ func (n *Nested) log(msg string) {
 	zlog.Info().Str("benchmark", "nested").Int("id", n.id).Msg(msg)
 }
 
+func (n *Nested) Validate(operations []string) error {
+	if err := dbutils.CheckModes(n.Modes); err != nil {
+		return err
+	}
+	if n.Items < 1 || n.NestingLevel < 1 || n.PopulateBatchSize < 1 {
+		return fmt.Errorf("items, nestingLevel, and populateBatchSize must be at least 1")
+	}
+	if n.MergeParallelism < 1 || n.MergeDelta <= 0 || n.MergeBatchSize < 1 {
+		return fmt.Errorf("mergeParallelism and mergeBatchSize must be at least 1, and mergeDelta positive")
+	}
+	if n.ReadQuery == "" {
+		return fmt.Errorf("missing readQuery")
+	}
+	return benchmark.CheckOperations("nested", operations, []string{"read", "explain"})
+}
+
+func (n *Nested) ConfigStructs() []any {
+	return []any{n}
+}
+
 func (n *Nested) Setup(connections []any) {
+	n.settings = dbutils.SnapshotSettings(util.CastArray[any, *sql.DB](connections))
 	totalPlanRt = &atomic.Int64{}
 	totalExecRt = &atomic.Int64{}
 	totalCount = &atomic.Int64{}
 	rtsMutex = &sync.Mutex{}
-	planRts = []float64{}
-	execRts = []float64{}
+	planRts = histogram.New()
+	execRts = histogram.New()
 }
 
 func (n *Nested) Populate(connections []any) {
@@ -98,22 +127,28 @@ func (n *Nested) Populate(connections []any) {
 }
 
 func (n *Nested) randomId() string {
-	return ids[rand.Intn(len(ids))]
+	return ids[n.rand.Intn(len(ids))]
 }
 
-func (n *Nested) read(db *sql.DB, id string) error {
-	rs := util.Try(n.readStmt.Query(id))
+func (n *Nested) read(ctx context.Context, db dbutils.Conn, id string) error {
+	rs, err := n.readStmt.QueryContext(ctx, id)
+	if err != nil {
+		return err
+	}
 	defer rs.Close()
 	rs.Next()
 
 	var json string
 	rs.Scan(&json)
 
-	return nil
+	return rs.Err()
 }
 
-func (n *Nested) explain(db *sql.DB, id string) error {
-	rs := util.Try(n.explainStmt.Query(id))
+func (n *Nested) explain(ctx context.Context, db dbutils.Conn, id string) error {
+	rs, err := n.explainStmt.QueryContext(ctx, id)
+	if err != nil {
+		return err
+	}
 	defer rs.Close()
 
 	for rs.Next() {
@@ -123,16 +158,19 @@ func (n *Nested) explain(db *sql.DB, id string) error {
 			rt, _ := strconv.ParseFloat(strings.Split(line, " ")[2], 64)
 			totalPlanRt.Add(int64(rt * 1000))
 			rtsMutex.Lock()
-			planRts = append(planRts, rt*1000)
+			planRts.Record(rt / 1000)
 			rtsMutex.Unlock()
 		} else if strings.HasPrefix(line, "Execution Time:") {
 			rt, _ := strconv.ParseFloat(strings.Split(line, " ")[2], 64)
 			totalExecRt.Add(int64(rt * 1000))
 			rtsMutex.Lock()
-			execRts = append(execRts, rt*1000)
+			execRts.Record(rt / 1000)
 			rtsMutex.Unlock()
 		}
 	}
+	if err := rs.Err(); err != nil {
+		return err
+	}
 
 	totalCount.Add(1)
 
@@ -140,8 +178,8 @@ func (n *Nested) explain(db *sql.DB, id string) error {
 	return errors.New("")
 }
 
-func (n *Nested) Prepare(connection any) map[string]func() error {
-	db := connection.(*sql.DB)
+func (n *Nested) Prepare(connection any) map[string]func(context.Context) error {
+	db := connection.(dbutils.Conn)
 	// switch to the correct modes
 	dbutils.SetReadMode(db, n.Modes["readMode"])
 	dbutils.SetWriteMode(db, n.Modes["writeMode"])
@@ -157,14 +195,14 @@ func (n *Nested) Prepare(connection any) map[string]func() error {
 	n.explainStmt = util.Try(db.Prepare("explain analyze select data from nested_view where id = $1"))
 
 	// ops
-	operations := map[string]func() error{
-		"read":    func() error { return n.read(db, n.randomId()) },
-		"explain": func() error { return n.explain(db, n.randomId()) },
+	operations := map[string]func(context.Context) error{
+		"read":    func(ctx context.Context) error { return n.read(ctx, db, n.randomId()) },
+		"explain": func(ctx context.Context) error { return n.explain(ctx, db, n.randomId()) },
 	}
 	return operations
 }
 
-func (n *Nested) planSize(db *sql.DB) int {
+func (n *Nested) planSize(db dbutils.Conn) int {
 	rs := util.Try(db.Query("explain analyze " + n.ReadQuery))
 	defer rs.Close()
 	size := 0
@@ -191,21 +229,23 @@ func (n *Nested) GetConfigs() map[string]string {
 }
 
 func (n *Nested) GetMetrics(connection any) map[string]string {
-	db := connection.(*sql.DB)
-	totalRts := planRts
-	totalRts = append(totalRts, execRts...)
+	db := connection.(dbutils.Conn)
+	totalRts := histogram.New()
+	totalRts.Merge(planRts)
+	totalRts.Merge(execRts)
 	return map[string]string{
 		"planSize":  strconv.Itoa(n.planSize(db)),
 		"planTime":  fmt.Sprintf("%.6f", float64(totalPlanRt.Load())/float64(totalCount.Load())/1e6),
 		"execTime":  fmt.Sprintf("%.6f", float64(totalExecRt.Load())/float64(totalCount.Load())/1e6),
 		"totalTime": fmt.Sprintf("%.6f", float64(totalPlanRt.Load()+totalExecRt.Load())/float64(totalCount.Load())/1e6),
-		"planP95":   fmt.Sprintf("%.6f", util.Percentile(planRts, 95)/1e6),
-		"execP95":   fmt.Sprintf("%.6f", util.Percentile(execRts, 95)/1e6),
-		"totalP95":  fmt.Sprintf("%.6f", util.Percentile(totalRts, 95)/1e6),
+		"planP95":   fmt.Sprintf("%.6f", planRts.Percentile(95)),
+		"execP95":   fmt.Sprintf("%.6f", execRts.Percentile(95)),
+		"totalP95":  fmt.Sprintf("%.6f", totalRts.Percentile(95)),
 	}
 }
 
 func (n *Nested) Finalize(connections []any) {
 	dbs := util.CastArray[any, *sql.DB](connections)
 	dbutils.WaitForSyncAllDBs(dbs)
+	dbutils.RestoreSettings(dbs, n.settings)
 }
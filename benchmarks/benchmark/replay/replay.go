@@ -0,0 +1,151 @@
+package replay
+
+import (
+	"benchmarks/benchmark/micro"
+	"benchmarks/trace"
+	"benchmarks/util"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	zlog "github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+// Reissues the operations of the traces recorded by the micro benchmark (see 'record'), against
+// any engine with the data types. Each worker replays the trace of the worker with the same id
+type Replay struct {
+	id      int
+	Trace   string // directory with the traces, one per worker
+	Timing  string // 'original' (the operations keep their start times) or 'asap' (back-to-back)
+	micro   *micro.Micro
+	reader  *trace.Reader
+	current trace.Entry
+}
+
+var timings = []string{"original", "asap"}
+
+func New(id int, configData []byte) *Replay {
+	replay := Replay{}
+	util.CheckErr(yaml.Unmarshal(configData, &replay))
+	replay.id = id
+	if replay.Timing == "" {
+		replay.Timing = "original"
+	}
+	// the structures are populated and accessed as in micro
+	replay.micro = micro.New(id, configData)
+	return &replay
+}
+
+func (r *Replay) log(msg string) {
+	zlog.Info().Str("benchmark", "replay").Int("id", r.id).Msg(msg)
+}
+
+// Checks the operations of the traces against the engine
+func (r *Replay) Validate(operations []string) error {
+	if !slices.Contains(timings, r.Timing) {
+		return fmt.Errorf("unknown timing '%s' (expected one of %v)", r.Timing, timings)
+	}
+	files, err := trace.Files(r.Trace)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		reader, err := trace.Open(file)
+		if err != nil {
+			return err
+		}
+		for {
+			e, err := reader.Read()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				reader.Close()
+				return fmt.Errorf("%s: %w", file, err)
+			}
+			if !slices.Contains(operations, e.Operation) {
+				operations = append(operations, e.Operation)
+			}
+			// only the arguments of the last operation of a transaction are recorded
+			if slices.ContainsFunc(r.micro.TxTemplates, func(t micro.TxTemplate) bool { return t.Name == e.Operation }) {
+				reader.Close()
+				return fmt.Errorf("%s: transaction template '%s' cannot be replayed", file, e.Operation)
+			}
+		}
+		reader.Close()
+	}
+	return r.micro.Validate(operations)
+}
+
+func (r *Replay) ConfigStructs() []any {
+	return append([]any{r}, r.micro.ConfigStructs()...)
+}
+
+func (r *Replay) Setup(connections []any) {
+	r.micro.Setup(connections)
+}
+
+func (r *Replay) Populate(connections []any) {
+	r.micro.Populate(connections)
+}
+
+func (r *Replay) Prepare(connection any) map[string]func(context.Context) error {
+	path := trace.WorkerPath(r.Trace, r.id)
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		r.log("No trace for this worker")
+	} else {
+		r.reader = util.Try(trace.Open(path))
+	}
+
+	operations := map[string]func(context.Context) error{}
+	for name, f := range r.micro.PrepareArgs(connection) {
+		f := f
+		operations[name] = func(ctx context.Context) error { return f(ctx, r.current.Args) }
+	}
+	return operations
+}
+
+// Returns the next operation of the trace (see benchmark.Scripted)
+func (r *Replay) NextOperation() (string, float64, bool) {
+	if r.reader == nil {
+		return "", 0, false
+	}
+	e, err := r.reader.Read()
+	if err == io.EOF {
+		r.reader.Close()
+		r.reader = nil
+		return "", 0, false
+	}
+	util.CheckErr(err)
+
+	r.current = e
+	if r.Timing == "asap" {
+		return e.Operation, -1, true
+	}
+	return e.Operation, e.At, true
+}
+
+// Returns the arguments of the last operation, so a replay can be recorded too
+func (r *Replay) LastArgs() trace.Args {
+	return r.current.Args
+}
+
+func (r *Replay) GetConfigs() map[string]string {
+	configs := r.micro.GetConfigs()
+	configs["timing"] = r.Timing
+	return configs
+}
+
+func (r *Replay) GetMetrics(connection any) map[string]string {
+	return r.micro.GetMetrics(connection)
+}
+
+func (r *Replay) Finalize(connections []any) {
+	if r.reader != nil {
+		r.reader.Close()
+	}
+	r.micro.Finalize(connections)
+}
@@ -2,6 +2,7 @@ package schema
 
 import (
 	"benchmarks/benchmark/timestampEncoding/row"
+	dbutils "benchmarks/dbUtils"
 	"benchmarks/util"
 	"database/sql"
 	"fmt"
@@ -47,7 +48,7 @@ func (e *ArraySchema) Populate(db *sql.DB, rows []row.Row) {
 	util.Try(db.Exec("ALTER TABLE CurrentTime ADD PRIMARY KEY (k)"))
 }
 
-func (e *ArraySchema) Prepare(db *sql.DB, sites int) *SchemaStmts {
+func (e *ArraySchema) Prepare(db dbutils.Conn, sites int) *SchemaStmts {
 	stmts := SchemaStmts{}
 
 	var maxKs []string
@@ -2,6 +2,7 @@ package schema
 
 import (
 	"benchmarks/benchmark/timestampEncoding/row"
+	dbutils "benchmarks/dbUtils"
 	"benchmarks/util"
 	"database/sql"
 	"fmt"
@@ -108,7 +109,7 @@ func (e *CubeSchema) Populate(db *sql.DB, rows []row.Row) {
 	`))
 }
 
-func (e *CubeSchema) Prepare(db *sql.DB, sites int) *SchemaStmts {
+func (e *CubeSchema) Prepare(db dbutils.Conn, sites int) *SchemaStmts {
 	stmts := SchemaStmts{}
 
 	getMaxRows := []string{}
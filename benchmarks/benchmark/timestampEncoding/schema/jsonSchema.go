@@ -2,6 +2,7 @@ package schema
 
 import (
 	"benchmarks/benchmark/timestampEncoding/row"
+	dbutils "benchmarks/dbUtils"
 	"benchmarks/util"
 	"database/sql"
 	"encoding/json"
@@ -53,7 +54,7 @@ func (e *JsonSchema) Populate(db *sql.DB, rows []row.Row) {
 	util.Try(db.Exec("ALTER TABLE CurrentTime ADD PRIMARY KEY (k)"))
 }
 
-func (e *JsonSchema) Prepare(db *sql.DB, sites int) *SchemaStmts {
+func (e *JsonSchema) Prepare(db dbutils.Conn, sites int) *SchemaStmts {
 	stmts := SchemaStmts{}
 
 	var jsonElements []string
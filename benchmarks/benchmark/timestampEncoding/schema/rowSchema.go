@@ -2,6 +2,7 @@ package schema
 
 import (
 	"benchmarks/benchmark/timestampEncoding/row"
+	dbutils "benchmarks/dbUtils"
 	"benchmarks/util"
 	"database/sql"
 	"fmt"
@@ -70,7 +71,7 @@ func (e *RowSchema) Populate(db *sql.DB, rows []row.Row) {
 	util.Try(db.Exec("ALTER TABLE CurrentTime ADD PRIMARY KEY (k, site)"))
 }
 
-func (e *RowSchema) Prepare(db *sql.DB, sites int) *SchemaStmts {
+func (e *RowSchema) Prepare(db dbutils.Conn, sites int) *SchemaStmts {
 	stmts := SchemaStmts{}
 
 	readQuery := `
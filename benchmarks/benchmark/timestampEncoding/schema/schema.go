@@ -2,13 +2,14 @@ package schema
 
 import (
 	"benchmarks/benchmark/timestampEncoding/row"
+	dbutils "benchmarks/dbUtils"
 	"database/sql"
 )
 
 type Schema interface {
-	Populate(*sql.DB, []row.Row)       // Populate the database with initial data
-	Prepare(*sql.DB, int) *SchemaStmts // Prepare the statements
-	Drop(*sql.DB)                      // Drops the previously created tables
+	Populate(*sql.DB, []row.Row)            // Populate the database with initial data
+	Prepare(dbutils.Conn, int) *SchemaStmts // Prepare the statements
+	Drop(*sql.DB)                           // Drops the previously created tables
 }
 
 type SchemaStmts struct {
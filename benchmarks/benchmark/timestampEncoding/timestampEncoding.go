@@ -1,11 +1,14 @@
 package timestampencoding
 
 import (
+	"benchmarks/benchmark"
 	"benchmarks/benchmark/timestampEncoding/row"
 	"benchmarks/benchmark/timestampEncoding/schema"
+	dbutils "benchmarks/dbUtils"
 	"benchmarks/util"
+	"context"
 	"database/sql"
-	"log"
+	"fmt"
 	"math/rand"
 	"strconv"
 
@@ -21,12 +24,16 @@ type TimestampEncoding struct {
 	Sites      int
 	statements *schema.SchemaStmts
 	schemaObj  schema.Schema
+
+	Seed int64 `yaml:"seed"`
+	rand *rand.Rand
 }
 
 func (t *TimestampEncoding) generateHistory() []row.Row {
 	var rows []row.Row
 	var allClocks []row.Clock
 	var currClocks []row.Clock
+	rng := util.NewRand(t.Seed, "populate")
 
 	for i := 0; i < t.Sites; i++ {
 		currClocks = append(currClocks, make(row.Clock, t.Sites))
@@ -42,7 +49,7 @@ func (t *TimestampEncoding) generateHistory() []row.Row {
 
 			for remoteSite := 0; remoteSite < t.Sites && i < t.Ops; remoteSite++ {
 				if remoteSite != site {
-					if rand.Float64() < 0.05 {
+					if rng.Float64() < 0.05 {
 						row.MergeClocks(clock, currClocks[remoteSite])
 						clockCopy := make(row.Clock, len(clock))
 						copy(clockCopy, clock)
@@ -56,7 +63,7 @@ func (t *TimestampEncoding) generateHistory() []row.Row {
 
 	for i := 0; i < t.Items; i++ {
 		for _, c := range allClocks {
-			rows = append(rows, row.Row{K: int64(i), V: rand.Int63(), Lts: c})
+			rows = append(rows, row.Row{K: int64(i), V: rng.Int63(), Lts: c})
 		}
 	}
 
@@ -67,6 +74,7 @@ func New(id int, configData []byte) *TimestampEncoding {
 	timestampEncoding := TimestampEncoding{}
 	timestampEncoding.id = id
 	util.CheckErr(yaml.Unmarshal(configData, &timestampEncoding))
+	timestampEncoding.rand = util.NewRand(timestampEncoding.Seed, fmt.Sprintf("benchmark-%d", id))
 
 	switch timestampEncoding.Schema {
 	case "row":
@@ -77,15 +85,27 @@ func New(id int, configData []byte) *TimestampEncoding {
 		timestampEncoding.schemaObj = &schema.JsonSchema{}
 	case "cube":
 		timestampEncoding.schemaObj = &schema.CubeSchema{}
-	default:
-		log.Fatalf("Invalid schema '%s' in TimestampEncoding\n", timestampEncoding.Schema)
 	}
 
 	return &timestampEncoding
 }
 
+func (t *TimestampEncoding) ConfigStructs() []any {
+	return []any{t}
+}
+
 func (*TimestampEncoding) Setup(connections []any) {}
 
+func (t *TimestampEncoding) Validate(operations []string) error {
+	if t.schemaObj == nil {
+		return fmt.Errorf("invalid schema '%s' (expected one of row, array, json, cube)", t.Schema)
+	}
+	if t.Ops < 1 || t.Items < 1 || t.Sites < 1 {
+		return fmt.Errorf("ops, items, and sites must be at least 1")
+	}
+	return benchmark.CheckOperations("timestampEncoding", operations, []string{"readKey", "readAll", "currTime", "write"})
+}
+
 func (t *TimestampEncoding) Populate(connections []any) {
 	dbs := util.CastArray[any, *sql.DB](connections)
 	rows := t.generateHistory()
@@ -96,10 +116,13 @@ func (t *TimestampEncoding) Populate(connections []any) {
 	util.Try(dbs[0].Exec("checkpoint"))
 }
 
-func (t *TimestampEncoding) readKey(_ *sql.DB) error {
-	key := rand.Int63n(int64(t.Items))
+func (t *TimestampEncoding) readKey(ctx context.Context, _ dbutils.Conn) error {
+	key := t.rand.Int63n(int64(t.Items))
 	result := []row.Row{}
-	rs := util.Try(t.statements.ReadKey.Query(key))
+	rs, err := t.statements.ReadKey.QueryContext(ctx, key)
+	if err != nil {
+		return err
+	}
 
 	for rs.Next() {
 		var k int64
@@ -113,12 +136,15 @@ func (t *TimestampEncoding) readKey(_ *sql.DB) error {
 		result = append(result, row.Row{K: k, V: v, Lts: lts_})
 	}
 
-	return nil
+	return rs.Err()
 }
 
-func (t *TimestampEncoding) readAll(_ *sql.DB) error {
+func (t *TimestampEncoding) readAll(ctx context.Context, _ dbutils.Conn) error {
 	result := []row.Row{}
-	rs := util.Try(t.statements.ReadAll.Query())
+	rs, err := t.statements.ReadAll.QueryContext(ctx)
+	if err != nil {
+		return err
+	}
 
 	for rs.Next() {
 		var k int64
@@ -128,48 +154,57 @@ func (t *TimestampEncoding) readAll(_ *sql.DB) error {
 		result = append(result, row.Row{K: k, V: v, Lts: lts})
 	}
 
-	return nil
+	return rs.Err()
 }
 
-func (t *TimestampEncoding) currTime(_ *sql.DB) error {
-	key := rand.Int63n(int64(t.Items))
-	rs := util.Try(t.statements.CurrTime.Query(key))
+func (t *TimestampEncoding) currTime(ctx context.Context, _ dbutils.Conn) error {
+	key := t.rand.Int63n(int64(t.Items))
+	rs, err := t.statements.CurrTime.QueryContext(ctx, key)
+	if err != nil {
+		return err
+	}
 
 	for rs.Next() {
 		var t string
 		rs.Scan(&t)
 	}
 
-	return nil
+	return rs.Err()
 }
 
-func (t *TimestampEncoding) write(db *sql.DB) error {
-	key := rand.Int63n(int64(t.Items))
-	value := rand.Int63()
-	txn, _ := db.Begin()
+func (t *TimestampEncoding) write(ctx context.Context, db dbutils.Conn) error {
+	key := t.rand.Int63n(int64(t.Items))
+	value := t.rand.Int63()
+	txn, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer txn.Rollback()
 
-	r := txn.Stmt(t.statements.NextTime).QueryRow(key)
+	r := dbutils.TxStmt(ctx, txn, db, t.statements.NextTime).QueryRowContext(ctx, key)
 	var ts string
 	r.Scan(&ts)
 
-	util.Try(txn.Stmt(t.statements.Write).Exec(key, value, ts))
+	if _, err := dbutils.TxStmt(ctx, txn, db, t.statements.Write).ExecContext(ctx, key, value, ts); err != nil {
+		return err
+	}
 
 	return txn.Commit()
 }
 
-func (t *TimestampEncoding) Prepare(connection any) map[string]func() error {
-	db := connection.(*sql.DB)
+func (t *TimestampEncoding) Prepare(connection any) map[string]func(context.Context) error {
+	db := connection.(dbutils.Conn)
 	t.statements = t.schemaObj.Prepare(db, t.Sites)
-	operations := map[string]func() error{
-		"readKey":  func() error { return t.readKey(db) },
-		"readAll":  func() error { return t.readAll(db) },
-		"currTime": func() error { return t.currTime(db) },
-		"write":    func() error { return t.write(db) },
+	operations := map[string]func(context.Context) error{
+		"readKey":  func(ctx context.Context) error { return t.readKey(ctx, db) },
+		"readAll":  func(ctx context.Context) error { return t.readAll(ctx, db) },
+		"currTime": func(ctx context.Context) error { return t.currTime(ctx, db) },
+		"write":    func(ctx context.Context) error { return t.write(ctx, db) },
 	}
 	return operations
 }
 
-func (t *TimestampEncoding) size(db *sql.DB) int64 {
+func (t *TimestampEncoding) size(db dbutils.Conn) int64 {
 	util.Try(db.Exec("vacuum analyze"))
 	row := t.statements.Size.QueryRow()
 	var s int64
@@ -187,7 +222,7 @@ func (t *TimestampEncoding) GetConfigs() map[string]string {
 }
 
 func (t *TimestampEncoding) GetMetrics(connection any) map[string]string {
-	db := connection.(*sql.DB)
+	db := connection.(dbutils.Conn)
 	return map[string]string{
 		"size": strconv.FormatInt(t.size(db), 10),
 	}
@@ -0,0 +1,295 @@
+package chart
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Size (pixels) of the plot area and its margins; the legend is below the plot, one entry per line
+const (
+	plotWidth    = 560
+	plotHeight   = 260
+	marginLeft   = 72
+	marginRight  = 24
+	marginTop    = 16
+	marginBottom = 48
+	legendLine   = 18
+	charWidth    = 6  // approximate width of a character
+	maxCategory  = 48 // characters of the categories of bar charts
+	rotation     = 20 // (degrees) of the categories that do not fit
+)
+
+// Colors of the series or bars (colorblind-safe), reused if there are more
+var palette = []string{"#4477aa", "#ee6677", "#228833", "#ccbb44", "#66ccee", "#aa3377", "#bbbbbb", "#222222"}
+
+// Line of a line chart (points with a non-finite coordinate are skipped)
+type Series struct {
+	Name string
+	X    []float64
+	Y    []float64
+}
+
+// Line chart, rendered as a standalone SVG element
+type Line struct {
+	XLabel string
+	YLabel string
+	LogY   bool // logarithmic y axis (non-positive values are skipped)
+	Series []Series
+}
+
+// Bar chart with a group of bars per category (e.g., the percentiles of each operation)
+type Bars struct {
+	YLabel     string
+	Categories []string    // along the x axis
+	Groups     []string    // bars of each category, in the legend
+	Values     [][]float64 // [group][category] (non-finite values are not drawn)
+}
+
+// Range of an axis and its ticks
+type axis struct {
+	min   float64
+	max   float64
+	ticks []float64
+	log   bool
+}
+
+// Returns an axis covering [min, max] with about n ticks at round values
+func newAxis(min float64, max float64, n int) axis {
+	if min > max {
+		min, max = 0, 1
+	}
+	if min == max {
+		min, max = min-math.Max(math.Abs(min)/2, 0.5), max+math.Max(math.Abs(max)/2, 0.5)
+	}
+	step := niceStep((max - min) / float64(n))
+	a := axis{min: math.Floor(min/step) * step, max: math.Ceil(max/step) * step}
+	for v := a.min; v <= a.max+step/2; v += step {
+		a.ticks = append(a.ticks, math.Round(v/step)*step)
+	}
+	return a
+}
+
+// Returns a logarithmic axis covering [min, max] (positive), with a tick per power of 10
+func newLogAxis(min float64, max float64) axis {
+	if min > max {
+		min, max = 1, 10
+	}
+	a := axis{min: math.Floor(math.Log10(min)), max: math.Ceil(math.Log10(max)), log: true}
+	if a.min == a.max {
+		a.max++
+	}
+	for e := a.min; e <= a.max; e++ {
+		a.ticks = append(a.ticks, math.Pow(10, e))
+	}
+	return a
+}
+
+// Returns the smallest step of 1, 2, or 5 times a power of 10 that is at least 'raw'
+func niceStep(raw float64) float64 {
+	magnitude := math.Pow(10, math.Floor(math.Log10(raw)))
+	for _, m := range []float64{1, 2, 5} {
+		if raw <= m*magnitude {
+			return m * magnitude
+		}
+	}
+	return 10 * magnitude
+}
+
+// Returns the position of a value along the axis, from 0 to 1
+func (a axis) fraction(v float64) float64 {
+	if a.log {
+		v = math.Log10(v)
+	}
+	return (v - a.min) / (a.max - a.min)
+}
+
+// Formats a tick, with a suffix for large values (e.g., 25k, 1.5M)
+func formatTick(v float64) string {
+	for _, s := range []struct {
+		scale  float64
+		suffix string
+	}{{1e9, "G"}, {1e6, "M"}, {1e4, "k"}} {
+		if math.Abs(v) >= s.scale {
+			return strconv.FormatFloat(v/s.scale, 'g', 4, 64) + s.suffix
+		}
+	}
+	return strconv.FormatFloat(v, 'g', 6, 64)
+}
+
+func valid(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+// SVG being drawn: the plot area, with the axes on its left and bottom, and the legend below
+type canvas struct {
+	b      strings.Builder
+	bottom int // space below the plot area, for the x axis
+}
+
+// Writes the start of the SVG element and the legend
+func (c *canvas) start(legend []string) {
+	if c.bottom == 0 {
+		c.bottom = marginBottom
+	}
+	h := marginTop + plotHeight + c.bottom + legendLine*len(legend) + 8
+	w := marginLeft + plotWidth + marginRight
+	fmt.Fprintf(&c.b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="11">`,
+		w, h, w, h)
+	fmt.Fprintf(&c.b, `<rect width="%d" height="%d" fill="white"/>`, w, h)
+	for i, name := range legend {
+		y := marginTop + plotHeight + c.bottom + legendLine*i
+		fmt.Fprintf(&c.b, `<rect x="%d" y="%d" width="10" height="10" fill="%s"/>`, marginLeft, y, color(i))
+		fmt.Fprintf(&c.b, `<text x="%d" y="%d">%s</text>`, marginLeft+16, y+9, html.EscapeString(name))
+	}
+}
+
+// Draws the y axis (grid lines, ticks, and label)
+func (c *canvas) yAxis(a axis, label string) {
+	for _, t := range a.ticks {
+		y := c.y(a, t)
+		fmt.Fprintf(&c.b, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#eee"/>`, marginLeft, y, marginLeft+plotWidth, y)
+		fmt.Fprintf(&c.b, `<text x="%d" y="%.1f" text-anchor="end" dominant-baseline="middle">%s</text>`,
+			marginLeft-6, y, formatTick(t))
+	}
+	fmt.Fprintf(&c.b, `<text transform="translate(16 %d) rotate(-90)" text-anchor="middle">%s</text>`,
+		marginTop+plotHeight/2, html.EscapeString(label))
+	fmt.Fprintf(&c.b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#444"/>`, marginLeft, marginTop, marginLeft, marginTop+plotHeight)
+	fmt.Fprintf(&c.b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#444"/>`, marginLeft, marginTop+plotHeight,
+		marginLeft+plotWidth, marginTop+plotHeight)
+}
+
+func (c *canvas) xLabel(label string) {
+	fmt.Fprintf(&c.b, `<text x="%d" y="%d" text-anchor="middle">%s</text>`, marginLeft+plotWidth/2,
+		marginTop+plotHeight+c.bottom-8, html.EscapeString(label))
+}
+
+func (c *canvas) x(a axis, v float64) float64 {
+	return marginLeft + a.fraction(v)*plotWidth
+}
+
+func (c *canvas) y(a axis, v float64) float64 {
+	return marginTop + (1-a.fraction(v))*plotHeight
+}
+
+func (c *canvas) end() string {
+	c.b.WriteString(`</svg>`)
+	return c.b.String()
+}
+
+func color(i int) string {
+	return palette[i%len(palette)]
+}
+
+// Renders the chart as an SVG element
+func (l *Line) SVG() string {
+	xMin, xMax := math.Inf(1), math.Inf(-1)
+	yMin, yMax := math.Inf(1), math.Inf(-1)
+	for _, s := range l.Series {
+		for i := range s.X {
+			if l.point(s, i) {
+				xMin, xMax = math.Min(xMin, s.X[i]), math.Max(xMax, s.X[i])
+				yMin, yMax = math.Min(yMin, s.Y[i]), math.Max(yMax, s.Y[i])
+			}
+		}
+	}
+	xAxis := newAxis(xMin, xMax, 6)
+	yAxis := newAxis(math.Min(0, yMin), yMax, 5)
+	if l.LogY {
+		yAxis = newLogAxis(yMin, yMax)
+	}
+
+	c := &canvas{}
+	names := []string{}
+	for _, s := range l.Series {
+		names = append(names, s.Name)
+	}
+	c.start(names)
+	c.yAxis(yAxis, l.YLabel)
+	for _, t := range xAxis.ticks {
+		fmt.Fprintf(&c.b, `<text x="%.1f" y="%d" text-anchor="middle">%s</text>`, c.x(xAxis, t),
+			marginTop+plotHeight+16, formatTick(t))
+	}
+	c.xLabel(l.XLabel)
+
+	for i, s := range l.Series {
+		points := []string{}
+		for j := range s.X {
+			if l.point(s, j) {
+				points = append(points, fmt.Sprintf("%.1f,%.1f", c.x(xAxis, s.X[j]), c.y(yAxis, s.Y[j])))
+			}
+		}
+		fmt.Fprintf(&c.b, `<polyline fill="none" stroke="%s" stroke-width="1.5" points="%s"/>`, color(i),
+			strings.Join(points, " "))
+		// markers, unless the series is dense (e.g., a time series)
+		if len(points) <= 30 {
+			for _, p := range points {
+				x, y, _ := strings.Cut(p, ",")
+				fmt.Fprintf(&c.b, `<circle cx="%s" cy="%s" r="2.5" fill="%s"/>`, x, y, color(i))
+			}
+		}
+	}
+	return c.end()
+}
+
+// Whether the i-th point of a series is drawn
+func (l *Line) point(s Series, i int) bool {
+	return i < len(s.Y) && valid(s.X[i]) && valid(s.Y[i]) && (!l.LogY || s.Y[i] > 0)
+}
+
+// Renders the chart as an SVG element
+func (b *Bars) SVG() string {
+	yMax := math.Inf(-1)
+	for _, values := range b.Values {
+		for _, v := range values {
+			if valid(v) {
+				yMax = math.Max(yMax, v)
+			}
+		}
+	}
+	yAxis := newAxis(0, yMax, 5)
+
+	band := float64(plotWidth) / float64(max(len(b.Categories), 1))
+	barWidth := band * 0.8 / float64(max(len(b.Groups), 1))
+	// categories that do not fit are rotated (and long ones shortened), with more space below
+	labels := []string{}
+	longest := 0
+	for _, category := range b.Categories {
+		if len(category) > maxCategory {
+			category = category[:maxCategory-3] + "..."
+		}
+		labels = append(labels, category)
+		longest = max(longest, len(category))
+	}
+	rotate := float64(longest*charWidth) > band
+	c := &canvas{}
+	if rotate {
+		c.bottom = 24 + int(float64(longest*charWidth)*math.Sin(rotation*math.Pi/180))
+	}
+	c.start(b.Groups)
+	c.yAxis(yAxis, b.YLabel)
+
+	for i, category := range b.Categories {
+		x := marginLeft + band*(float64(i)+0.5)
+		y := marginTop + plotHeight + 14
+		label := html.EscapeString(labels[i])
+		if rotate {
+			fmt.Fprintf(&c.b, `<text transform="translate(%.1f %d) rotate(-%d)" text-anchor="end"><title>%s</title>%s</text>`,
+				x, y, rotation, html.EscapeString(category), label)
+		} else {
+			fmt.Fprintf(&c.b, `<text x="%.1f" y="%d" text-anchor="middle">%s</text>`, x, y, label)
+		}
+		for g, values := range b.Values {
+			if i >= len(values) || !valid(values[i]) {
+				continue
+			}
+			top := c.y(yAxis, values[i])
+			fmt.Fprintf(&c.b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"><title>%s: %s</title></rect>`,
+				marginLeft+band*float64(i)+band*0.1+barWidth*float64(g), top, barWidth, marginTop+plotHeight-top, color(g),
+				html.EscapeString(b.Groups[g]), formatTick(values[i]))
+		}
+	}
+	return c.end()
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"benchmarks/stats"
+	"benchmarks/store"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Metrics where higher values are better (for the others, e.g., rt, lower is better)
+var higherIsBetter = []string{"tps"}
+
+// Metrics compared with absolute deltas, as they are already fractions (e.g., 0 -> 0.01 aborts)
+var absoluteDelta = []string{"ar"}
+
+// Comparison of a metric of an operation between the baseline and the candidate
+type Comparison struct {
+	operation string
+	metric    string
+	baseline  float64
+	candidate float64
+	delta     float64 // relative (or absolute, see absoluteDelta); positive = worse
+	p         float64 // p-value of the Welch's t-test (NaN without per-run statistics)
+	verdict   string  // "regression", "improvement", "undefined" (NaN delta), or "" (within budget or not significant)
+}
+
+// Returns the value of a metric of an operation (see resultColumns)
+func operationValue(o store.Operation, metric string) float64 {
+	values := map[string]store.Float{
+		"rt": o.Rt, "tps": o.Tps, "ct": o.Ct, "ar": o.Ar, "rtP50": o.RtP50, "rtP90": o.RtP90,
+		"rtP95": o.RtP95, "rtP99": o.RtP99, "rtP999": o.RtP999, "rtMax": o.RtMax, "late": o.Late,
+		"dropped": o.Dropped, "timeouts": o.Timeouts, "retries": o.Retries, "rtRetry": o.RtRetry,
+		"serialization": o.Serialization, "deadlock": o.Deadlock, "connection": o.Connection,
+		"semantic": o.Semantic, "other": o.Other,
+	}
+	if v, ok := values[metric]; ok {
+		return float64(v)
+	}
+	return math.NaN()
+}
+
+// Returns the summary of a metric across runs (a single value if there are no statistics)
+func operationSummary(o store.Operation, metric string) stats.Summary {
+	if s, ok := o.Stats[metric]; ok {
+		return stats.Summary{N: s.Runs, Mean: float64(s.Mean), Stddev: float64(s.Stddev)}
+	}
+	return stats.Summary{N: 1, Mean: operationValue(o, metric), Stddev: math.NaN()}
+}
+
+// Identifies the summary records that can be compared: same benchmark, engine, number of
+// workers, etc., configs, and swept values ('ignore' lists the keys left out)
+func matchKey(r *store.Record, ignore []string) string {
+	pairs := []string{}
+	for k, v := range matchFields(r, ignore) {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, " ")
+}
+
+// Returns the fields of the match key of a record
+func matchFields(r *store.Record, ignore []string) map[string]string {
+	fields := map[string]string{
+		"benchmark": r.Run.Benchmark,
+		"engine":    r.Run.Engine,
+		"workers":   strconv.Itoa(r.Run.Workers),
+		"isolation": r.Run.Isolation,
+		"sites":     strconv.Itoa(r.Run.Sites),
+		"rate":      strconv.FormatFloat(r.Run.Rate, 'g', -1, 64),
+	}
+	for k, v := range r.Configs {
+		fields[k] = v
+	}
+	for k, v := range r.Sweep {
+		fields[k] = v
+	}
+	for _, k := range ignore {
+		delete(fields, k)
+	}
+	return fields
+}
+
+// Reads the summary records of a file, by match key (the last one wins, if repeated)
+func readSummaries(path string, ignore []string) map[string]*store.Record {
+	records, err := store.Read(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	summaries := map[string]*store.Record{}
+	for _, r := range records {
+		if r.Kind == store.KindSummary {
+			summaries[matchKey(r, ignore)] = r
+		}
+	}
+	return summaries
+}
+
+// Parses the regression budget: a default fraction, optionally followed by per-metric values
+// (e.g., "0.05,rtP99=0.2")
+func parseBudget(budget string) (float64, map[string]float64) {
+	defaultBudget := 0.
+	perMetric := map[string]float64{}
+	for _, e := range splitList(budget) {
+		metric, value, found := strings.Cut(e, "=")
+		if !found {
+			value = metric
+		}
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			log.Fatalf("Invalid budget '%s'.\n", e)
+		}
+		if found {
+			perMetric[metric] = v
+		} else {
+			defaultBudget = v
+		}
+	}
+	return defaultBudget, perMetric
+}
+
+// Compares a metric of an operation present in both result sets
+func compareMetric(base store.Operation, cand store.Operation, metric string, budget float64, alpha float64) Comparison {
+	c := Comparison{
+		operation: base.Name,
+		metric:    metric,
+		baseline:  operationValue(base, metric),
+		candidate: operationValue(cand, metric),
+		p:         stats.WelchTest(operationSummary(base, metric), operationSummary(cand, metric)),
+	}
+
+	if c.baseline == c.candidate || math.IsNaN(c.baseline) && math.IsNaN(c.candidate) {
+		c.delta = 0
+	} else if slices.Contains(absoluteDelta, metric) {
+		c.delta = c.candidate - c.baseline
+	} else {
+		c.delta = (c.candidate - c.baseline) / math.Abs(c.baseline)
+	}
+	if slices.Contains(higherIsBetter, metric) {
+		c.delta = -c.delta
+	}
+	// e.g., the response time of an operation that never committed on one side
+	if math.IsNaN(c.delta) {
+		c.verdict = "undefined"
+		return c
+	}
+
+	// without per-run statistics (e.g., a single run), only the budget is considered
+	significant := math.IsNaN(c.p) || c.p < alpha
+	if significant && c.delta > budget {
+		c.verdict = "regression"
+	} else if significant && c.delta < -budget {
+		c.verdict = "improvement"
+	}
+	return c
+}
+
+func formatDelta(c Comparison) string {
+	delta := c.delta
+	if math.IsNaN(delta) {
+		return "n/a"
+	}
+	if slices.Contains(higherIsBetter, c.metric) {
+		delta = -delta
+	}
+	if slices.Contains(absoluteDelta, c.metric) {
+		return fmt.Sprintf("%+.4f", delta)
+	}
+	return fmt.Sprintf("%+.2f%%", delta*100)
+}
+
+// Compares two result sets, printing a report; returns the exit code (1 if the regression
+// budget was exceeded or a delta is undefined, 2 if no results could be compared)
+func compare(arguments []string) int {
+	flags := flag.NewFlagSet("compare", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: benchmarks compare [options] <baseline> <candidate>")
+		fmt.Fprintln(flags.Output(), "Compares two result sets (.jsonl, .csv, or .db/.sqlite files written with -results).")
+		flags.PrintDefaults()
+	}
+	metrics := flags.String("metrics", "rt,tps,ar,rtP50,rtP90,rtP95,rtP99,rtP999", "Comma-separated list of metrics to compare")
+	operations := flags.String("operations", "", "Comma-separated list of operations to compare (default: all)")
+	budget := flags.String("budget", "0.05",
+		"Maximum regression, as a fraction of the baseline (absolute for ar), optionally with per-metric values (e.g., 0.05,rtP99=0.2)")
+	alpha := flags.Float64("alpha", 0.05, "Significance level; regressions must also be significant when both sides have multiple runs")
+	ignore := flags.String("ignore", "", "Comma-separated list of keys ignored when matching results (e.g., engine)")
+	flags.Parse(arguments)
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return 2
+	}
+
+	defaultBudget, perMetric := parseBudget(*budget)
+	baseline := readSummaries(flags.Arg(0), splitList(*ignore))
+	candidate := readSummaries(flags.Arg(1), splitList(*ignore))
+
+	keys := []string{}
+	for k := range baseline {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	regressions := 0
+	undefined := 0
+	compared := 0
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, key := range keys {
+		cand, ok := candidate[key]
+		if !ok {
+			fmt.Fprintf(out, "\nOnly in baseline: %s\n", key)
+			continue
+		}
+
+		fmt.Fprintf(out, "\n%s\n", key)
+		fmt.Fprintln(out, "operation\tmetric\tbaseline\tcandidate\tdelta\tp\tverdict")
+		candOperations := map[string]store.Operation{}
+		for _, o := range cand.Operations {
+			candOperations[o.Name] = o
+		}
+		for _, base := range baseline[key].Operations {
+			if *operations != "" && !slices.Contains(splitList(*operations), base.Name) {
+				continue
+			}
+			candOperation, ok := candOperations[base.Name]
+			if !ok {
+				fmt.Fprintf(out, "%s\t(only in baseline)\n", base.Name)
+				continue
+			}
+			for _, metric := range splitList(*metrics) {
+				metricBudget, ok := perMetric[metric]
+				if !ok {
+					metricBudget = defaultBudget
+				}
+				c := compareMetric(base, candOperation, metric, metricBudget, *alpha)
+				compared++
+				if c.verdict == "regression" {
+					regressions++
+				} else if c.verdict == "undefined" {
+					undefined++
+				}
+				fmt.Fprintf(out, "%s\t%s\t%.6f\t%.6f\t%s\t%.4f\t%s\n", c.operation, c.metric, c.baseline,
+					c.candidate, formatDelta(c), c.p, c.verdict)
+			}
+		}
+	}
+	for key := range candidate {
+		if _, ok := baseline[key]; !ok {
+			fmt.Fprintf(out, "\nOnly in candidate: %s\n", key)
+		}
+	}
+	out.Flush()
+
+	if compared == 0 {
+		fmt.Fprintln(os.Stderr, "No results could be compared (check the match keys, or -ignore and -operations).")
+		return 2
+	}
+	fmt.Printf("\n%d regressions and %d undefined deltas in %d comparisons\n", regressions, undefined, compared)
+	if regressions > 0 || undefined > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"benchmarks/store"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompareMetric(t *testing.T) {
+	base := store.Operation{Name: "mapAdd", Rt: 0.002, Tps: 1000, Ar: 0.01}
+	tests := []struct {
+		name     string
+		cand     store.Operation
+		metric   string
+		expected string
+	}{
+		{"slower", store.Operation{Name: "mapAdd", Rt: 0.003}, "rt", "regression"},
+		{"faster", store.Operation{Name: "mapAdd", Rt: 0.001}, "rt", "improvement"},
+		{"within budget", store.Operation{Name: "mapAdd", Rt: 0.00205}, "rt", ""},
+		{"fewer transactions", store.Operation{Name: "mapAdd", Tps: 900}, "tps", "regression"},
+		{"more transactions", store.Operation{Name: "mapAdd", Tps: 1100}, "tps", "improvement"},
+		// aborts are compared with absolute deltas
+		{"more aborts", store.Operation{Name: "mapAdd", Ar: 0.1}, "ar", "regression"},
+		{"few more aborts", store.Operation{Name: "mapAdd", Ar: 0.02}, "ar", ""},
+	}
+	for _, test := range tests {
+		if c := compareMetric(base, test.cand, test.metric, 0.05, 0.05); c.verdict != test.expected {
+			t.Errorf("%s: verdict %q (delta %v), expected %q", test.name, c.verdict, c.delta, test.expected)
+		}
+	}
+}
+
+func TestCompareMetricUndefined(t *testing.T) {
+	base := store.Operation{Name: "mapAdd", Rt: 0.002, Ar: 0.01, RtRetry: store.Float(math.NaN())}
+	cand := store.Operation{Name: "mapAdd", Rt: store.Float(math.NaN()), Ar: 0.01, RtRetry: store.Float(math.NaN())}
+
+	c := compareMetric(base, cand, "rt", 0.05, 0.05)
+	if c.verdict != "undefined" || formatDelta(c) != "n/a" {
+		t.Errorf("expected an undefined delta, got %v (%s)", c.verdict, formatDelta(c))
+	}
+	// undefined on both sides, e.g., the response time with retries when there were none
+	if c := compareMetric(base, cand, "rtRetry", 0.05, 0.05); c.verdict != "" || c.delta != 0 {
+		t.Errorf("expected no difference, got %v (%v)", c.verdict, c.delta)
+	}
+	if c := compareMetric(base, store.Operation{Name: "mapAdd", Rt: 0.003}, "rt", 0.05, 0.05); c.verdict != "regression" {
+		t.Errorf("expected a regression, got %v (%v)", c.verdict, c.delta)
+	}
+}
+
+// Writes a result set with a summary of a single operation
+func writeSummary(t *testing.T, engine string, operation store.Operation) string {
+	path := filepath.Join(t.TempDir(), engine+".jsonl")
+	record := store.Record{
+		Kind:       store.KindSummary,
+		Run:        store.Run{Benchmark: "micro", Engine: engine, Workers: 4},
+		Operations: []store.Operation{operation},
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCompareExitCode(t *testing.T) {
+	stdout := os.Stdout
+	os.Stdout, _ = os.Open(os.DevNull)
+	defer func() { os.Stdout = stdout }()
+
+	baseline := writeSummary(t, "crdv", store.Operation{Name: "total", Rt: 0.002, Tps: 1000})
+	tests := []struct {
+		name      string
+		arguments []string
+		expected  int
+	}{
+		{"same", []string{baseline, writeSummary(t, "crdv", store.Operation{Name: "total", Rt: 0.002, Tps: 1000})}, 0},
+		{"regression", []string{baseline, writeSummary(t, "crdv", store.Operation{Name: "total", Rt: 0.003, Tps: 1000})}, 1},
+		{"undefined", []string{baseline, writeSummary(t, "crdv", store.Operation{Name: "total", Rt: store.Float(math.NaN()), Tps: 1000})}, 1},
+		{"no matches", []string{baseline, writeSummary(t, "native", store.Operation{Name: "total", Rt: 0.002, Tps: 1000})}, 2},
+		{"ignored key", []string{"-ignore", "engine", baseline, writeSummary(t, "native", store.Operation{Name: "total", Rt: 0.002, Tps: 1000})}, 0},
+		{"no operations", []string{"-operations", "mapAdd", baseline, baseline}, 2},
+	}
+	for _, test := range tests {
+		if code := compare(append([]string{"-metrics", "rt,tps"}, test.arguments...)); code != test.expected {
+			t.Errorf("%s: exit code %d, expected %d", test.name, code, test.expected)
+		}
+	}
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"benchmarks/benchmark"
+	engine "benchmarks/benchmark/engines/abstract"
+	"benchmarks/config"
+	"benchmarks/util"
+	"benchmarks/worker"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var isolations = []string{"READ UNCOMMITTED", "READ COMMITTED", "REPEATABLE READ", "SERIALIZABLE"}
+
+// Returns the errors in the harness keys (ranges and values)
+func checkArgs(args *BenchmarkArgs) []error {
+	errs := []error{}
+	fail := func(format string, a ...any) {
+		errs = append(errs, fmt.Errorf(format, a...))
+	}
+
+	if len(args.Connection) == 0 {
+		fail("missing connection")
+	}
+	if len(args.Workers) == 0 {
+		fail("missing workers")
+	}
+	for _, nWorkers := range args.Workers {
+		if nWorkers < 1 {
+			fail("workers must be at least 1 (got %d)", nWorkers)
+		}
+	}
+	if args.Time <= 0 && args.Transactions < 1 {
+		fail("either time or transactions must be positive")
+	}
+	if args.Warmup < 0 || args.Cooldown < 0 {
+		fail("warmup and cooldown must be non-negative")
+	} else if args.Time > 0 && args.Warmup+args.Cooldown >= args.Time {
+		fail("warmup + cooldown (%ds) leave no measurement window in %ds", args.Warmup+args.Cooldown, args.Time)
+	}
+	if args.Runs < 1 {
+		fail("runs must be at least 1")
+	}
+	if s := args.SteadyState; s != nil {
+		if s.Method != "mser" && s.Method != "cv" {
+			fail("unknown steadyState method '%s' (expected mser or cv)", s.Method)
+		}
+		if s.Window <= 0 || s.Windows < 2 || s.MaxCv <= 0 {
+			fail("steadyState: window and maxCv must be positive, and windows at least 2")
+		}
+		if args.Time <= 0 || len(args.Phases) > 0 {
+			fail("steadyState requires a fixed time, without phases")
+		}
+	}
+	if args.CiTarget < 0 || args.OutlierThreshold < 0 {
+		fail("ciTarget and outlierThreshold must be non-negative")
+	}
+	if args.CiTarget > 0 && args.MaxRuns < args.Runs {
+		fail("maxRuns (%d) must be at least runs (%d) when ciTarget is set", args.MaxRuns, args.Runs)
+	}
+	if args.Isolation != "" && !slices.Contains(isolations, strings.ToUpper(args.Isolation)) {
+		fail("unknown isolation '%s' (expected one of %v)", args.Isolation, isolations)
+	}
+	if p := args.Pool; p.Mode != "shared" && p.Mode != "worker" {
+		fail("unknown pool mode '%s' (expected shared or worker)", p.Mode)
+	} else if p.MaxOpen < 0 || p.MaxIdle < 0 || p.MaxLifetime < 0 || p.MaxIdleTime < 0 {
+		fail("pool: maxOpen, maxIdle, maxLifetime, and maxIdleTime must be non-negative")
+	}
+	errs = append(errs, checkPlacement(args)...)
+
+	if args.Rate < 0 || args.MaxLag < 0 || args.Interval < 0 || args.Timeout < 0 {
+		fail("rate, maxLag, interval, and timeout must be non-negative")
+	}
+	if args.RateScope != "" && args.RateScope != "global" && args.RateScope != "worker" {
+		fail("unknown rateScope '%s' (expected global or worker)", args.RateScope)
+	}
+	if args.Arrival != "" && args.Arrival != "constant" && args.Arrival != "poisson" {
+		fail("unknown arrival '%s' (expected constant or poisson)", args.Arrival)
+	}
+
+	for _, op := range allOperations(args) {
+		if op.Name == "" || op.Weight < 0 {
+			fail("operations need a name and a non-negative weight (got '%s', %d)", op.Name, op.Weight)
+		}
+	}
+
+	for class, policy := range args.Retry {
+		if !slices.Contains(worker.ErrorClasses, class) {
+			fail("unknown error class '%s' (expected one of %v)", class, worker.ErrorClasses)
+		}
+		if policy.Attempts < 0 || policy.Backoff < 0 || policy.MaxBackoff < 0 {
+			fail("retry of %s: attempts, backoff, and maxBackoff must be non-negative", class)
+		}
+	}
+
+	for _, agent := range args.Agents {
+		if agent.Address == "" {
+			fail("missing agent address")
+		}
+		for _, site := range agent.Sites {
+			if site < 0 || site >= len(args.Connection) {
+				fail("agent %s: site %d out of range (%d connections)", agent.Address, site, len(args.Connection))
+			}
+		}
+	}
+	return errs
+}
+
+// Returns the operations issued in any run: the top-level ones, and those of each phase and site
+func allOperations(args *BenchmarkArgs) []worker.Operation {
+	operations := append([]worker.Operation{}, args.Operations...)
+	for _, p := range args.Phases {
+		operations = append(operations, p.Operations...)
+	}
+	for _, s := range args.Placement {
+		operations = append(operations, s.Operations...)
+	}
+	return operations
+}
+
+// Creates a benchmark to check the config, turning its panics (e.g., type errors in its keys) into
+// errors
+func checkedBenchmark(benchmarkFactory func(int) benchmark.Benchmark) (b benchmark.Benchmark, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	return benchmarkFactory(-1), nil
+}
+
+// Checks an execution before connecting: the harness keys, the type errors of the benchmark and
+// engine, and the operations and ranges of the benchmark. returns the benchmark, if created
+func validate(args *BenchmarkArgs, benchmarkFactory func(int) benchmark.Benchmark) (benchmark.Benchmark, []error) {
+	errs := checkArgs(args)
+
+	b, err := checkedBenchmark(benchmarkFactory)
+	if err != nil {
+		errs = append(errs, err)
+	} else {
+		operations := []string{}
+		for _, op := range allOperations(args) {
+			operations = append(operations, op.Name)
+		}
+		if err := b.Validate(operations); err != nil {
+			errs = append(errs, err)
+		}
+		if _, ok := b.(benchmark.Traceable); args.Record != "" && !ok {
+			errs = append(errs, fmt.Errorf("benchmark '%s' cannot be recorded", args.Benchmark))
+		}
+	}
+	if len(args.Agents) > 0 && !slices.Contains(agentBenchmarks, args.Benchmark) {
+		errs = append(errs, fmt.Errorf("benchmark '%s' cannot run on agents (expected one of %v)", args.Benchmark, agentBenchmarks))
+	}
+	return b, errs
+}
+
+// Returns the config of an execution with every key resolved: the keys in the file, the defaults of
+// the keys absent from it, and the values filled by the harness (e.g., the phases)
+func resolvedConfig(args *BenchmarkArgs, b benchmark.Benchmark) *yaml.Node {
+	doc := yaml.Node{}
+	util.CheckErr(yaml.Unmarshal(args.FileData, &doc))
+	if len(doc.Content) == 0 {
+		doc.Content = []*yaml.Node{{Kind: yaml.MappingNode}}
+	}
+	root := doc.Content[0]
+
+	structs := append([]any{args}, b.ConfigStructs()...)
+	for i, s := range structs {
+		node := yaml.Node{}
+		util.CheckErr(node.Encode(s))
+		for j := 0; j+1 < len(node.Content); j += 2 {
+			key, value := node.Content[j], node.Content[j+1]
+			if i == 0 && key.Value == "sweep" {
+				// already expanded into the executions
+				continue
+			}
+			k := 0
+			for k < len(root.Content) && root.Content[k].Value != key.Value {
+				k += 2
+			}
+			if k >= len(root.Content) {
+				root.Content = append(root.Content, key, value)
+			} else if i == 0 && key.Value != "operations" {
+				// the benchmark may read more of each operation (e.g., the distributions of micro)
+				root.Content[k+1] = value
+			}
+		}
+	}
+	return root
+}
+
+// Describes the runs of an execution, and returns their maximum duration (seconds, excluding the
+// populate and setup)
+func plannedRuns(args *BenchmarkArgs, registration *engine.Registration) ([]string, int) {
+	lines := []string{}
+	total := 0
+	for _, nWorkers := range args.Workers {
+		nWorkers = limitWorkers(nWorkers, args, registration)
+		runs := fmt.Sprintf("%d run", args.Runs)
+		if args.Runs != 1 {
+			runs += "s"
+		}
+		maxRuns := args.Runs
+		if args.CiTarget > 0 {
+			runs += fmt.Sprintf(" (up to %d, until the 95%% CI of the tps is within %g%% of the mean)", args.MaxRuns, args.CiTarget*100)
+			maxRuns = args.MaxRuns
+		}
+
+		length := fmt.Sprintf("of %ds (warmup %ds, cooldown %ds)", args.Time, args.Warmup, args.Cooldown)
+		if args.SteadyState != nil {
+			length = fmt.Sprintf("of %ds (warmup detected by %s, otherwise %ds, cooldown %ds)", args.Time,
+				args.SteadyState.Method, args.Warmup, args.Cooldown)
+		}
+		if len(args.Phases) > 0 {
+			phases := []string{}
+			for _, p := range args.Phases {
+				phases = append(phases, fmt.Sprintf("%s %ds", p.Name, p.Duration))
+			}
+			length = fmt.Sprintf("of %ds (phases: %s)", args.Time, strings.Join(phases, ", "))
+		} else if args.Time <= 0 {
+			length = fmt.Sprintf("of %d transactions", args.Transactions)
+		}
+
+		populate := "populated before each run"
+		if args.NoReload {
+			populate = "populated before the first run"
+		}
+		where := ""
+		if len(args.Placement) > 0 {
+			counts := []string{}
+			for _, count := range siteWorkers(nWorkers, args) {
+				counts = append(counts, strconv.Itoa(count))
+			}
+			where = ", placed " + strings.Join(counts, "/")
+		}
+		if args.Pool.Mode == "worker" {
+			where += ", a connection per worker"
+		}
+		if len(args.Agents) > 0 {
+			where += fmt.Sprintf(", on %d agents", len(args.Agents))
+		}
+		lines = append(lines, fmt.Sprintf("%d workers: %s %s, %s%s", nWorkers, runs, length, populate, where))
+		total += maxRuns * max(args.Time, 0)
+	}
+	return lines, total
+}
+
+// Prints the resolved config and the planned runs of each execution, without connecting
+func printPlan(allArgs []*BenchmarkArgs, registrations []*engine.Registration, factories []func(int) benchmark.Benchmark) {
+	total := 0
+	for i, args := range allArgs {
+		header := fmt.Sprintf("# execution %d/%d", i+1, len(allArgs))
+		if len(args.Point) > 0 {
+			header += " (sweep: " + args.Point.String() + ")"
+		}
+		fmt.Println(header)
+
+		encoder := yaml.NewEncoder(os.Stdout)
+		encoder.SetIndent(2)
+		util.CheckErr(encoder.Encode(resolvedConfig(args, factories[i](-1))))
+		util.CheckErr(encoder.Close())
+
+		lines, duration := plannedRuns(args, registrations[i])
+		fmt.Println("# planned runs")
+		for _, line := range lines {
+			fmt.Println("#   " + line)
+		}
+		fmt.Println()
+		total += duration
+	}
+	fmt.Printf("# %d execution(s), up to %v of measured runs (excluding populate and setup)\n", len(allArgs),
+		time.Duration(total)*time.Second)
+}
+
+// Resolves and validates every execution of a config (see validate), and checks the keys of the
+// file against all of them, exiting with every error found. returns the arguments, engines, and
+// benchmark factories of each execution
+func resolve(data []byte) ([]*BenchmarkArgs, []*engine.Registration, []func(int) benchmark.Benchmark) {
+	allArgs := buildSweepArgs(data)
+	registrations := []*engine.Registration{}
+	factories := []func(int) benchmark.Benchmark{}
+	structs := []any{}
+	errs := []string{}
+	for _, args := range allArgs {
+		registration := engineRegistration(args)
+		benchmarkFactory := getBenchmarkFactory(args.Benchmark, args.FileData)
+		b, pointErrs := validate(args, benchmarkFactory)
+		if err := checkPlacementLimit(args, registration); err != nil {
+			pointErrs = append(pointErrs, err)
+		}
+		if b != nil {
+			structs = append(structs, b.ConfigStructs()...)
+		}
+		// errors common to all executions are reported once
+		for _, err := range pointErrs {
+			if !slices.Contains(errs, err.Error()) {
+				errs = append(errs, err.Error())
+			}
+		}
+		registrations = append(registrations, registration)
+		factories = append(factories, benchmarkFactory)
+	}
+
+	// the keys are checked in the file itself, so the lines match
+	if len(structs) > 0 {
+		for _, err := range config.UnknownKeys(data, append(structs, &BenchmarkArgs{})...) {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		log.Fatalf("Invalid config:\n%s\n", strings.Join(errs, "\n"))
+	}
+	return allArgs, registrations, factories
+}
+
+// 'validate' subcommand: checks a config and prints its plan, as -dry-run
+func validateConfig(arguments []string) int {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: benchmarks validate -conf <file>")
+		fmt.Fprintln(flags.Output(), "Checks a config file and prints the resolved config and planned runs, without connecting.")
+		flags.PrintDefaults()
+	}
+	configFile := flags.String("conf", "", "Benchmark config file")
+	flags.Parse(arguments)
+
+	setupLogging(true, "")
+	printPlan(resolve(readConfig(*configFile)))
+	return 0
+}
@@ -0,0 +1,191 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Returns an error for each key of the config (at any depth) that is not read by any of the given
+// structs (or pointers to them), as unmarshalled by yaml. the harness, benchmark, and engine each
+// unmarshal the same config into their own struct, so a key is only unknown if none of them reads it
+func UnknownKeys(data []byte, structs ...any) []error {
+	root := yaml.Node{}
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return []error{err}
+	}
+	types := []reflect.Type{}
+	for _, s := range structs {
+		types = append(types, reflect.TypeOf(s))
+	}
+	errs := []error{}
+	check(&root, types, "", &errs)
+	return errs
+}
+
+func check(node *yaml.Node, types []reflect.Type, path string, errs *[]error) {
+	types = deref(types)
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.AliasNode:
+		for _, n := range node.Content {
+			check(n, types, path, errs)
+		}
+	case yaml.SequenceNode:
+		elems := []reflect.Type{}
+		for _, t := range types {
+			if t.Kind() == reflect.Interface {
+				return
+			}
+			if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+				elems = append(elems, t.Elem())
+			}
+		}
+		for i, n := range node.Content {
+			check(n, elems, fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	case yaml.MappingNode:
+		// a mapping where a scalar is expected is a type error, reported by yaml itself
+		if !slices.ContainsFunc(types, func(t reflect.Type) bool {
+			return t.Kind() == reflect.Struct || t.Kind() == reflect.Map || t.Kind() == reflect.Interface
+		}) {
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Value == "<<" {
+				check(value, types, path, errs)
+				continue
+			}
+			children, found, known := lookup(types, key.Value)
+			if !found {
+				*errs = append(*errs, unknownKey(key, join(path, key.Value), known))
+				continue
+			}
+			check(value, children, join(path, key.Value), errs)
+		}
+	}
+}
+
+// Returns the types of a key in the given structs or maps, whether any of them reads it, and the
+// keys they read
+func lookup(types []reflect.Type, key string) ([]reflect.Type, bool, []string) {
+	children := []reflect.Type{}
+	found := false
+	known := []string{}
+	for _, t := range types {
+		switch t.Kind() {
+		case reflect.Interface:
+			return nil, true, nil
+		case reflect.Map:
+			children = append(children, t.Elem())
+			found = true
+		case reflect.Struct:
+			for name, field := range fields(t) {
+				known = append(known, name)
+				if name == key {
+					children = append(children, field)
+					found = true
+				}
+			}
+		}
+	}
+	return children, found, known
+}
+
+// Returns the keys of a struct and their types, following the yaml conventions (the lowercased
+// field name, unless tagged, and the fields of inlined structs)
+func fields(t reflect.Type) map[string]reflect.Type {
+	keys := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := strings.Split(f.Tag.Get("yaml"), ",")
+		if tag[0] == "-" {
+			continue
+		}
+		if len(tag) > 1 && tag[1] == "inline" {
+			for k, v := range fields(deref([]reflect.Type{f.Type})[0]) {
+				keys[k] = v
+			}
+			continue
+		}
+		name := tag[0]
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		keys[name] = f.Type
+	}
+	return keys
+}
+
+func deref(types []reflect.Type) []reflect.Type {
+	result := []reflect.Type{}
+	for _, t := range types {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		result = append(result, t)
+	}
+	return result
+}
+
+func join(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func unknownKey(key *yaml.Node, path string, known []string) error {
+	err := fmt.Sprintf("line %d: unknown key '%s'", key.Line, path)
+	if suggestion := closest(key.Value, known); suggestion != "" {
+		err += fmt.Sprintf(" (did you mean '%s'?)", suggestion)
+	}
+	return errors.New(err)
+}
+
+// Returns the known key closest to a (mistyped) one, if any is close enough
+func closest(key string, known []string) string {
+	best := ""
+	bestDistance := max(1, len(key)/4) + 1
+	for _, k := range known {
+		if strings.EqualFold(k, key) {
+			return k
+		}
+		if d := distance(strings.ToLower(k), strings.ToLower(key)); d < bestDistance || (d == bestDistance && k < best) {
+			best, bestDistance = k, d
+		}
+	}
+	return best
+}
+
+// Edit distance, where swapping two adjacent characters (e.g., wieght) is a single edit
+func distance(a string, b string) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+// Structs of the harness, a benchmark, and an engine reading the same config
+type harness struct {
+	Benchmark  string
+	Workers    []int
+	Operations []struct {
+		Name   string
+		Weight int
+	}
+	Pool struct {
+		MaxOpen int `yaml:"maxOpen"`
+	}
+	Ignored string `yaml:"-"`
+}
+
+type Common struct {
+	Seed int64
+}
+
+type bench struct {
+	Common            `yaml:",inline"`
+	ItemsPerStructure int `yaml:"itemsPerStructure"`
+	Distributions     map[string]struct{ Type string }
+	Extra             any
+}
+
+type engineConfig struct {
+	MergeDelta float64 `yaml:"mergeDelta"`
+}
+
+func TestUnknownKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		expected []string // fragments of each error, in order
+	}{
+		{"valid", `
+benchmark: micro
+workers: [1, 2]
+operations:
+- {name: mapAdd, weight: 1}
+pool: {maxOpen: 4}
+seed: 1
+itemsPerStructure: 10
+distributions:
+  key: {type: zipfian}
+extra: {anything: [1, {goes: here}]}
+mergeDelta: 0.5
+`, nil},
+		{"top-level typo", "workres: [1]", []string{"line 1: unknown key 'workres' (did you mean 'workers'?)"}},
+		{"case", "mergedelta: 0.5", []string{"unknown key 'mergedelta' (did you mean 'mergeDelta'?)"}},
+		{"nested", "pool: {maxopen: 4, idle: 2}", []string{"'pool.maxopen' (did you mean 'maxOpen'?)", "'pool.idle'"}},
+		{"in a list", "operations:\n- {name: mapAdd, wieght: 1}", []string{"line 2: unknown key 'operations[0].wieght' (did you mean 'weight'?)"}},
+		{"inline", "sede: 1", []string{"'sede' (did you mean 'seed'?)"}},
+		{"map values", "distributions:\n  key: {typ: zipfian}", []string{"'distributions.key.typ' (did you mean 'type'?)"}},
+		{"ignored field", "ignored: x", []string{"'ignored'"}},
+		{"far from any key", "foo: 1", []string{"unknown key 'foo'"}},
+		{"merge", `
+operations:
+- &op {name: mapAdd, weight: 1}
+- <<: *op
+  wieght: 2
+- <<: {name: mapValue, wieght: 1}
+`, []string{"line 5: unknown key 'operations[1].wieght'", "line 6: unknown key 'operations[2].wieght'"}},
+	}
+	for _, test := range tests {
+		errs := UnknownKeys([]byte(test.config), &harness{}, &bench{}, engineConfig{})
+		if len(errs) != len(test.expected) {
+			t.Errorf("%s: expected %d errors, got %v", test.name, len(test.expected), errs)
+			continue
+		}
+		for i, err := range errs {
+			if !strings.Contains(err.Error(), test.expected[i]) {
+				t.Errorf("%s: expected an error with %q, got %q", test.name, test.expected[i], err)
+			}
+		}
+	}
+
+	// a key read only by the engine is unknown without it
+	if errs := UnknownKeys([]byte("mergeDelta: 0.5"), &harness{}, &bench{}); len(errs) != 1 {
+		t.Errorf("expected mergeDelta to be unknown without the engine, got %v", errs)
+	}
+}
+
+func TestClosest(t *testing.T) {
+	known := []string{"workers", "weight", "mergeDelta", "time", "rate"}
+	tests := []struct {
+		key, expected string
+	}{
+		{"workers", "workers"},
+		{"Workers", "workers"},
+		{"mergedelta", "mergeDelta"},
+		{"wieght", "weight"},
+		{"worker", "workers"},
+		{"tme", "time"},
+		{"xyz", ""},
+		{"mergeDeltaSize", ""},
+	}
+	for _, test := range tests {
+		if c := closest(test.key, known); c != test.expected {
+			t.Errorf("closest(%q) = %q, expected %q", test.key, c, test.expected)
+		}
+	}
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"", "", 0},
+		{"rate", "rate", 0},
+		{"", "rate", 4},
+		{"rate", "rte", 1},
+		{"rate", "rates", 1},
+		{"rate", "race", 1},
+		// swapping adjacent characters is a single edit
+		{"weight", "wieght", 1},
+		{"ab", "ba", 1},
+		{"abc", "ca", 3},
+		{"kitten", "sitting", 3},
+	}
+	for _, test := range tests {
+		if d := distance(test.a, test.b); d != test.expected {
+			t.Errorf("distance(%q, %q) = %d, expected %d", test.a, test.b, d, test.expected)
+		}
+		if d := distance(test.b, test.a); d != test.expected {
+			t.Errorf("distance(%q, %q) = %d, expected %d", test.b, test.a, d, test.expected)
+		}
+	}
+}
@@ -0,0 +1,459 @@
+package main
+
+import (
+	"benchmarks/live"
+	"benchmarks/util"
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-isatty"
+	"github.com/rs/zerolog"
+	zlog "github.com/rs/zerolog/log"
+)
+
+const (
+	redrawInterval   = time.Second      // of the dashboard, in a terminal
+	progressInterval = 10 * time.Second // of the progress lines, otherwise
+	paneLines        = 6                // last lines of the output and log shown in the terminal
+	paneWidth        = 110              // characters of those lines (the terminal size is not known)
+	sparklineLength  = 60               // throughput values (one per redraw) of the sparkline
+)
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// Engine gauges of the live metrics shown, when set
+var dashboardGauges = []struct {
+	name  string
+	title string
+	bytes bool
+}{
+	{"benchmark_crdv_unmerged_rows", "unmerged rows", false},
+	{"benchmark_pg_crdt_changes_queue", "changes to send", false},
+	{"benchmark_db_size_bytes", "db size", true},
+}
+
+// Live view of the executions (-dashboard), from the live metrics: redrawn in place when stdout is a
+// terminal, otherwise printed as periodic 'Progress:' lines. the methods are no-ops on nil
+type dashboard struct {
+	mu           sync.Mutex
+	allArgs      []*BenchmarkArgs
+	terminal     bool
+	start        time.Time
+	point        int
+	workersIndex int // of the current number of workers in args.Workers
+	nWorkers     int
+	run          int
+	stage        string // 'setup', 'running', or 'teardown'
+	runStart     time.Time
+	previous     map[string]live.Snapshot
+	previousTime time.Time
+	sparkline    []float64
+	stop         chan bool
+	done         sync.WaitGroup
+	stdout       *os.File // (terminal) the real stdout, replaced by a pipe while the dashboard is shown
+	pipe         *os.File
+	captured     *os.File // (terminal) everything printed to stdout, written to it when closed (kept if the benchmark dies first)
+	closed       sync.Once
+	output       []string
+	log          []string // (terminal) nil if the log is not shown, i.e., stderr is redirected
+	logger       zerolog.Logger
+}
+
+// The dashboard of this process, if enabled
+var dash *dashboard
+
+// Starts the dashboard of the executions, enabling the live metrics. in a terminal, the output is
+// captured in a file (and printed when closed) and the log is shown in the dashboard, unless redirected
+func startDashboard(allArgs []*BenchmarkArgs) *dashboard {
+	live.Enable()
+	d := &dashboard{
+		allArgs:  allArgs,
+		terminal: isatty.IsTerminal(os.Stdout.Fd()),
+		start:    time.Now(),
+		stage:    "setup",
+		stop:     make(chan bool),
+	}
+	d.previous, d.previousTime = live.Operations(), d.start
+	interval := progressInterval
+	if d.terminal {
+		interval = redrawInterval
+		d.stdout = os.Stdout
+		d.captured = util.Try(os.CreateTemp("", "benchmarks-output-*.txt"))
+		zlog.Info().Str("file", d.captured.Name()).Msg("Output captured by the dashboard")
+		reader, writer, err := os.Pipe()
+		util.CheckErr(err)
+		os.Stdout, d.pipe = writer, writer
+		d.done.Add(1)
+		go d.capture(reader)
+		if isatty.IsTerminal(os.Stderr.Fd()) {
+			d.log, d.logger = []string{}, zlog.Logger
+			// without the debug lines, one per operation
+			zlog.Logger = zlog.Output(zerolog.ConsoleWriter{Out: paneWriter{d}, NoColor: true, TimeFormat: time.TimeOnly}).
+				Level(zerolog.InfoLevel)
+		}
+		log.SetOutput(fatalWriter{d})
+		// alternate screen, hidden cursor
+		fmt.Fprint(d.stdout, "\x1b[?1049h\x1b[?25l")
+	}
+
+	stop := d.stop
+	d.done.Add(1)
+	go func() {
+		defer d.done.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				d.refresh()
+			}
+		}
+	}()
+	return d
+}
+
+// Reads the captured output, keeping its last lines for the dashboard
+func (d *dashboard) capture(reader io.Reader) {
+	defer d.done.Done()
+	buffered := bufio.NewReader(reader)
+	for {
+		line, err := buffered.ReadString('\n')
+		d.captured.WriteString(line)
+		d.mu.Lock()
+		if line != "" {
+			d.output = lastLines(append(d.output, strings.TrimRight(line, "\n")))
+		}
+		d.mu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Log lines shown in the dashboard
+type paneWriter struct {
+	d *dashboard
+}
+
+func (w paneWriter) Write(p []byte) (int, error) {
+	w.d.mu.Lock()
+	defer w.d.mu.Unlock()
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		w.d.log = lastLines(append(w.d.log, line))
+	}
+	return len(p), nil
+}
+
+func lastLines(lines []string) []string {
+	return lines[max(0, len(lines)-paneLines):]
+}
+
+// Output of the standard log while the dashboard is shown. the standard log is only used for fatal
+// errors (log.Fatal), which exit without running the deferred close, so the terminal is restored
+// first
+type fatalWriter struct {
+	d *dashboard
+}
+
+func (w fatalWriter) Write(p []byte) (int, error) {
+	w.d.close()
+	return os.Stderr.Write(p)
+}
+
+// Restores the terminal before a panic crashes the benchmark, for goroutines other than main's
+// (whose deferred close already runs); meant to be deferred
+func (d *dashboard) recoverPanic() {
+	if r := recover(); r != nil {
+		d.close()
+		panic(r)
+	}
+}
+
+// Stops the dashboard, restoring the terminal and printing the captured output (once, as it is also
+// called on a second interrupt, a panic, or a fatal error)
+func (d *dashboard) close() {
+	if d == nil {
+		return
+	}
+	d.closed.Do(func() {
+		close(d.stop)
+		if !d.terminal {
+			d.done.Wait()
+			return
+		}
+		os.Stdout = d.stdout
+		d.pipe.Close()
+		d.done.Wait()
+		if d.log != nil {
+			zlog.Logger = d.logger
+		}
+		fmt.Fprint(d.stdout, "\x1b[?25h\x1b[?1049l")
+		d.captured.Seek(0, io.SeekStart)
+		io.Copy(d.stdout, d.captured)
+		d.captured.Close()
+		os.Remove(d.captured.Name())
+	})
+}
+
+func (d *dashboard) startPoint(point int) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.point = point
+}
+
+// Sets up the 'run'-th run of the number of workers at 'index' of the current point
+func (d *dashboard) startRun(index int, nWorkers int, run int) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.workersIndex, d.nWorkers, d.run = index, nWorkers, run
+	d.stage = "setup"
+}
+
+func (d *dashboard) startWorkers() {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.stage = "running"
+	d.runStart = time.Now()
+	d.sparkline = nil
+}
+
+func (d *dashboard) stopWorkers() {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.stage = "teardown"
+}
+
+// Per-operation rates since the previous refresh
+type operationRates struct {
+	name string
+	tps  float64
+	ar   float64 // aborts / (commits + aborts)
+	p95  float64 // (seconds)
+}
+
+// Computes the rates since the previous refresh and redraws the dashboard (or prints a progress line)
+func (d *dashboard) refresh() {
+	now := time.Now()
+	current := live.Operations()
+	gauges := []string{}
+	for _, g := range dashboardGauges {
+		if samples := live.Gauge(g.name); len(samples) > 0 {
+			values := []string{}
+			for _, s := range samples {
+				value := fmt.Sprintf("%.0f", s.Value)
+				if g.bytes {
+					value = formatBytes(s.Value)
+				}
+				if site, ok := s.Labels["site"]; ok {
+					value = "site " + site + ": " + value
+				}
+				values = append(values, value)
+			}
+			gauges = append(gauges, fmt.Sprintf("%-14s %s", g.title, strings.Join(values, "   ")))
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	elapsed := now.Sub(d.previousTime).Seconds()
+	rates := []operationRates{}
+	total := 0.0
+	names := []string{}
+	for name := range current {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		delta := current[name].Sub(d.previous[name])
+		aborts := int64(0)
+		for outcome, n := range delta.Outcomes {
+			if outcome != "committed" && outcome != "timeout" && outcome != "dropped" {
+				aborts += n
+			}
+		}
+		r := operationRates{name: name, tps: float64(delta.Count) / elapsed, p95: delta.Quantile(0.95)}
+		r.ar = float64(aborts) / float64(delta.Count+aborts)
+		rates = append(rates, r)
+		total += r.tps
+	}
+	if d.stage == "running" {
+		d.sparkline = append(d.sparkline, total)[max(0, len(d.sparkline)+1-sparklineLength):]
+	}
+	d.previous, d.previousTime = current, now
+
+	if d.terminal {
+		d.draw(now, rates, total, gauges)
+	} else {
+		d.printProgress(now, rates, total, gauges)
+	}
+}
+
+// Describes the current point, number of workers, and run
+func (d *dashboard) position(now time.Time) []string {
+	args := d.allArgs[d.point]
+	point := fmt.Sprintf("point %d/%d", d.point+1, len(d.allArgs))
+	if len(args.Point) > 0 {
+		point += " (" + args.Point.String() + ")"
+	}
+	runs := fmt.Sprintf("run %d/%d", d.run+1, args.Runs)
+	if args.CiTarget > 0 {
+		runs = fmt.Sprintf("run %d/%d-%d", d.run+1, args.Runs, args.MaxRuns)
+	}
+	stage := d.stage
+	if d.stage == "running" {
+		stage += " " + now.Sub(d.runStart).Round(time.Second).String()
+		if args.Time > 0 {
+			stage += fmt.Sprintf("/%ds", args.Time)
+		}
+	}
+	return []string{point, fmt.Sprintf("%d workers (%d/%d)", d.nWorkers, d.workersIndex+1, len(args.Workers)), runs, stage}
+}
+
+// Returns the (maximum) time left of the measured runs, excluding populate and setup, and whether it is
+// known (i.e., the runs are timed)
+func (d *dashboard) remaining(now time.Time) (time.Duration, bool) {
+	seconds := 0
+	for i := d.point; i < len(d.allArgs); i++ {
+		args := d.allArgs[i]
+		if args.Time <= 0 {
+			return 0, false
+		}
+		maxRuns := args.Runs
+		if args.CiTarget > 0 {
+			maxRuns = args.MaxRuns
+		}
+		if i > d.point {
+			seconds += len(args.Workers) * maxRuns * args.Time
+			continue
+		}
+		seconds += (len(args.Workers) - d.workersIndex - 1) * maxRuns * args.Time
+		seconds += (maxRuns - d.run - 1) * args.Time
+		if d.stage != "teardown" {
+			seconds += args.Time
+		}
+	}
+	left := time.Duration(seconds) * time.Second
+	if d.stage == "running" {
+		left -= min(now.Sub(d.runStart), time.Duration(d.allArgs[d.point].Time)*time.Second)
+	}
+	return left.Round(time.Second), true
+}
+
+func (d *dashboard) times(now time.Time) string {
+	times := "elapsed " + now.Sub(d.start).Round(time.Second).String()
+	if left, known := d.remaining(now); known {
+		times += ", remaining ~" + left.String()
+	}
+	return times
+}
+
+func (d *dashboard) printProgress(now time.Time, rates []operationRates, total float64, gauges []string) {
+	parts := []string{strings.Join(d.position(now), ", ") + ", " + d.times(now)}
+	if d.stage == "running" {
+		parts = append(parts, fmt.Sprintf("%.0f tps", total))
+		for _, r := range rates {
+			parts = append(parts, fmt.Sprintf("%s %.0f tps, ar %s, p95 %s", r.name, r.tps, formatRatio(r.ar), formatSeconds(r.p95)))
+		}
+	}
+	for _, g := range gauges {
+		parts = append(parts, strings.Join(strings.Fields(g), " "))
+	}
+	fmt.Println("Progress: " + strings.Join(parts, "; "))
+}
+
+func (d *dashboard) draw(now time.Time, rates []operationRates, total float64, gauges []string) {
+	args := d.allArgs[d.point]
+	lines := []string{
+		fmt.Sprintf("%s / %s   %s", args.Benchmark, args.Engine, d.times(now)),
+		strings.Join(d.position(now), "   "),
+		"",
+		fmt.Sprintf("%-24s %10s %10s %10s", "operation", "tps", "abort rate", "p95"),
+	}
+	for _, r := range rates {
+		lines = append(lines, fmt.Sprintf("%-24s %10.1f %10s %10s", r.name, r.tps, formatRatio(r.ar), formatSeconds(r.p95)))
+	}
+	lines = append(lines, fmt.Sprintf("%-24s %10.1f", "total", total), "", "throughput "+sparkline(d.sparkline), "")
+	lines = append(lines, gauges...)
+	if len(gauges) > 0 {
+		lines = append(lines, "")
+	}
+	lines = append(lines, "output (in "+d.captured.Name()+")")
+	for _, line := range d.output {
+		lines = append(lines, "  "+truncate(line))
+	}
+	if d.log != nil {
+		lines = append(lines, "", "log")
+		for _, line := range d.log {
+			lines = append(lines, "  "+truncate(line))
+		}
+	}
+	// redrawn from the top, clearing the rest of each line and of the screen
+	fmt.Fprint(d.stdout, "\x1b[H"+strings.Join(lines, "\x1b[K\n")+"\x1b[K\n\x1b[J")
+}
+
+// Draws the values as a line of block characters, scaled to the largest
+func sparkline(values []float64) string {
+	top := 0.0
+	for _, v := range values {
+		top = math.Max(top, v)
+	}
+	b := strings.Builder{}
+	for _, v := range values {
+		i := 0
+		if top > 0 {
+			i = int(math.Round(v / top * float64(len(sparks)-1)))
+		}
+		b.WriteRune(sparks[i])
+	}
+	if top > 0 {
+		b.WriteString(fmt.Sprintf(" (max %.0f tps)", top))
+	}
+	return b.String()
+}
+
+func truncate(line string) string {
+	if runes := []rune(line); len(runes) > paneWidth {
+		return string(runes[:paneWidth-3]) + "..."
+	}
+	return line
+}
+
+func formatRatio(r float64) string {
+	if math.IsNaN(r) {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f%%", r*100)
+}
+
+func formatSeconds(s float64) string {
+	if math.IsNaN(s) {
+		return "-"
+	}
+	return fmt.Sprintf("%.2fms", s*1000)
+}
+
+func formatBytes(b float64) string {
+	return fmt.Sprintf("%.1fMB", b/1e6)
+}
@@ -2,23 +2,260 @@ package dbutils
 
 import (
 	"benchmarks/util"
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/lib/pq"
 )
 
+// Connection to a site used by a worker: the pool of the site (*sql.DB), or a connection of the pool
+// dedicated to the worker (see Dedicated)
+type Conn interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+	Prepare(query string) (*sql.Stmt, error)
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// Connection of a pool dedicated to a worker (pool mode 'worker'): its statements are prepared
+// once, on this connection, and its session settings last for the whole run
+type Dedicated struct {
+	*sql.Conn
+}
+
+func (c Dedicated) Exec(query string, args ...any) (sql.Result, error) {
+	return c.ExecContext(context.Background(), query, args...)
+}
+
+func (c Dedicated) Query(query string, args ...any) (*sql.Rows, error) {
+	return c.QueryContext(context.Background(), query, args...)
+}
+
+func (c Dedicated) QueryRow(query string, args ...any) *sql.Row {
+	return c.QueryRowContext(context.Background(), query, args...)
+}
+
+func (c Dedicated) Prepare(query string) (*sql.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+// Returns stmt bound to tx, a transaction of conn. the statements of a dedicated connection are
+// returned as is: they already run in its transaction, as they run in its session (binding them
+// with Tx.Stmt would prepare them again in each transaction)
+func TxStmt(ctx context.Context, tx *sql.Tx, conn Conn, stmt *sql.Stmt) *sql.Stmt {
+	if _, ok := conn.(Dedicated); ok {
+		return stmt
+	}
+	return tx.StmtContext(ctx, stmt)
+}
+
+// Connector that counts the connections it opens (database/sql only reports those open now and
+// some of those closed)
+type countingConnector struct {
+	driver.Connector
+	opened atomic.Int64
+}
+
+func (c *countingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err == nil {
+		c.opened.Add(1)
+	}
+	return conn, err
+}
+
+// State of a site's pool (*sql.DB) kept by Connect
+type site struct {
+	isolation string // default transaction isolation before Connect changed it, restored by Disconnect
+	connector *countingConnector
+}
+
+var sites sync.Map // *sql.DB -> *site
+
+// Opens a connection pool to a site, setting its default transaction isolation (the pool limits
+// are set by the caller)
+func Connect(address string, isolation string) *sql.DB {
+	connector := &countingConnector{Connector: util.Try(pq.NewConnector(address))}
+	db := sql.OpenDB(connector)
+	util.CheckErr(db.Ping())
+	s := &site{connector: connector}
+	util.CheckErr(db.QueryRow("show default_transaction_isolation").Scan(&s.isolation))
+	sites.Store(db, s)
+	util.Try(db.Exec("alter system set default_transaction_isolation = '" + isolation + "'"))
+	util.Try(db.Exec("select pg_reload_conf()"))
+	return db
+}
+
+// Changes the transaction isolation back to the value it had before Connect and closes the
+// connection pool. the pool is closed even if the isolation cannot be restored (e.g., the server is
+// gone), as this runs while tearing down
+func Disconnect(db *sql.DB) error {
+	defer db.Close()
+	s, ok := sites.LoadAndDelete(db)
+	if !ok {
+		return nil
+	}
+	if _, err := db.Exec("alter system set default_transaction_isolation = '" + s.(*site).isolation + "'"); err != nil {
+		return fmt.Errorf("restoring the transaction isolation: %w", err)
+	}
+	if _, err := db.Exec("select pg_reload_conf()"); err != nil {
+		return fmt.Errorf("restoring the transaction isolation: %w", err)
+	}
+	return nil
+}
+
+// Returns the number of connections opened by a pool returned by Connect
+func Opened(db *sql.DB) int64 {
+	if s, ok := sites.Load(db); ok {
+		return s.(*site).connector.opened.Load()
+	}
+	return 0
+}
+
+// Returns the size (bytes) of the current database, without vacuuming it first (e.g., for live
+// metrics)
+func DatabaseSize(db *sql.DB) (int64, error) {
+	var s int64
+	err := db.QueryRow("select pg_database_size(current_database())").Scan(&s)
+	return s, err
+}
+
+// Settings always included in the environment, even with their default values
+var environmentSettings = []string{"shared_buffers", "synchronous_commit", "wal_level", "max_connections", "fsync"}
+
+// Describes the server of the current database: its version, the settings that differ from the
+// default (plus environmentSettings), and the version of each installed extension (e.g., clocks
+// and list_ids)
+func Environment(db *sql.DB) (map[string]string, error) {
+	env := map[string]string{}
+	var version string
+	if err := db.QueryRow("show server_version").Scan(&version); err != nil {
+		return nil, err
+	}
+	env["version"] = version
+
+	rows, err := db.Query(`
+		select 'setting.' || name, current_setting(name)
+		from pg_settings
+		where source not in ('default', 'override') or name = any($1)
+		union all
+		select 'extension.' || extname, extversion
+		from pg_extension
+	`, pq.Array(environmentSettings))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var k, v string
+		if err := rows.Scan(&k, &v); err != nil {
+			return nil, err
+		}
+		env[k] = v
+	}
+	return env, rows.Err()
+}
+
+// Valid values of each crdv mode
+var Modes = map[string][]string{"readMode": {"local", "all"}, "writeMode": {"sync", "async"}}
+
+// Returns an error if some crdv mode is unknown or has an invalid value
+func CheckModes(modes map[string]string) error {
+	for mode, value := range modes {
+		values, ok := Modes[mode]
+		if !ok {
+			return fmt.Errorf("unknown mode '%s' (expected one of readMode, writeMode)", mode)
+		}
+		if !slices.Contains(values, value) {
+			return fmt.Errorf("invalid %s '%s' (expected one of %v)", mode, value, values)
+		}
+	}
+	return nil
+}
+
 // Sets the database read mode: 'local' or 'all'
-func SetReadMode(db *sql.DB, mode string) {
+func SetReadMode(db Conn, mode string) {
 	util.Try(db.Exec("select switch_read_mode($1)", mode))
 }
 
 // Sets the database write mode: 'sync' or 'async'
-func SetWriteMode(db *sql.DB, mode string) {
+func SetWriteMode(db Conn, mode string) {
 	util.Try(db.Exec("select switch_write_mode($1)", mode))
 }
 
-// Resets the data in all sites
+// Settings of a site changed by the benchmark: the crdv modes and the merge daemon
+type Settings struct {
+	ReadMode    string
+	WriteMode   string
+	MergeDaemon []string // arguments of the merge daemon (parallelism, delta, batch size), or nil if stopped
+}
+
+// Returns the current settings of each site, to restore them when the benchmark ends (see
+// RestoreSettings)
+func SnapshotSettings(dbs []*sql.DB) []Settings {
+	settings := make([]Settings, len(dbs))
+	var wg sync.WaitGroup
+	for i, db := range dbs {
+		wg.Add(1)
+		go func(i int, db *sql.DB) {
+			defer wg.Done()
+			s := &settings[i]
+			// the read mode switches the Data view, and the write mode the merge trigger of Shared
+			util.CheckErr(db.QueryRow(`
+				select case when pg_get_viewdef('data'::regclass) ilike '%dataall%' then 'all' else 'local' end,
+					(select case when tgenabled = 'D' then 'async' else 'sync' end
+					from pg_trigger
+					where tgname = 'shared_insert_local_sync_trigger')
+			`).Scan(&s.ReadMode, &s.WriteMode))
+
+			var args string
+			err := db.QueryRow(`
+				select substring(query from 'CALL merge_daemon\((.*)\)')
+				from pg_stat_activity
+				where query like 'CALL merge_daemon(%' and datname = current_database()
+				limit 1
+			`).Scan(&args)
+			if err != sql.ErrNoRows {
+				util.CheckErr(err)
+				if parts := strings.Split(args, ", "); len(parts) == 3 {
+					s.MergeDaemon = parts
+				}
+			}
+		}(i, db)
+	}
+	wg.Wait()
+	return settings
+}
+
+// Sets the modes of a site, and restarts its merge daemon (or stops it)
+func applySettings(db *sql.DB, s Settings) {
+	SetWriteMode(db, s.WriteMode)
+	SetReadMode(db, s.ReadMode)
+	if s.MergeDaemon == nil {
+		util.Try(db.Exec("select unschedule_merge_daemon()"))
+	} else {
+		util.Try(db.Exec("select schedule_merge_daemon($1, $2, $3)", s.MergeDaemon[0], s.MergeDaemon[1], s.MergeDaemon[2]))
+	}
+}
+
+// Resets the data in all sites, with the default modes and the merge daemon with the configured
+// parallelism
 func InitDb(dbs []*sql.DB, mergeParallelism int, mergeDelta float64, mergeBatchSize int) {
+	settings := Settings{
+		ReadMode:  "local",
+		WriteMode: "sync",
+		MergeDaemon: []string{strconv.Itoa(mergeParallelism), strconv.FormatFloat(mergeDelta, 'f', -1, 64),
+			strconv.Itoa(mergeBatchSize)},
+	}
 	// deletes are not propagated, so we need to clean each database individually
 	var wg sync.WaitGroup
 	for _, db := range dbs {
@@ -27,10 +264,7 @@ func InitDb(dbs []*sql.DB, mergeParallelism int, mergeDelta float64, mergeBatchS
 			defer wg.Done()
 			util.Try(db.Exec("select unschedule_merge_daemon()"))
 			util.Try(db.Exec("select reset_data()"))
-			SetWriteMode(db, "sync")
-			SetReadMode(db, "local")
-			util.Try(db.Exec("select schedule_merge_daemon($1, $2, $3)",
-				mergeParallelism, mergeDelta, mergeBatchSize))
+			applySettings(db, settings)
 		}(db)
 	}
 	wg.Wait()
@@ -63,6 +297,20 @@ func ScaleMergeDaemon(dbs []*sql.DB) {
 	wg.Wait()
 }
 
+// Restores the settings of each site taken before the benchmark changed them (see
+// SnapshotSettings), e.g., after Prepare switched the modes and ScaleMergeDaemon the merge daemon
+func RestoreSettings(dbs []*sql.DB, settings []Settings) {
+	var wg sync.WaitGroup
+	for i, db := range dbs {
+		wg.Add(1)
+		go func(db *sql.DB, s Settings) {
+			defer wg.Done()
+			applySettings(db, s)
+		}(db, settings[i])
+	}
+	wg.Wait()
+}
+
 // Delete any unmerged rows from the Shared table, so the benchmark can finish faster
 func DiscardUnmergedRows(dbs []*sql.DB) {
 	var wg sync.WaitGroup
@@ -117,7 +365,7 @@ func VacuumAndCheckpointAllDBs(dbs []*sql.DB) {
 }
 
 // Log query plans (for debug)
-func EnableAutoExplain(db *sql.DB) {
+func EnableAutoExplain(db Conn) {
 	util.Try(db.Exec("LOAD 'auto_explain'"))
 	util.Try(db.Exec("SET auto_explain.log_min_duration = 10;"))
 	util.Try(db.Exec("SET auto_explain.log_analyze = true;"))
@@ -125,7 +373,7 @@ func EnableAutoExplain(db *sql.DB) {
 }
 
 // Returns the database size, in bytes
-func DbSize(db *sql.DB, vacuumFull bool) int64 {
+func DbSize(db Conn, vacuumFull bool) int64 {
 	if vacuumFull {
 		util.Try(db.Exec("vacuum full analyze"))
 	} else {
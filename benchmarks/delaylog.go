@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Replication delay and throughput of an interval of a run of the delay benchmark
+type delayPoint struct {
+	workers int
+	time    float64 // (seconds) since the workers started running
+	delay   float64 // operations not yet applied, as seen by the main worker
+	tps     float64
+}
+
+// Delay and completed operations of an interval, as they are read
+type delayInterval struct {
+	delay        float64
+	transactions int
+	measurements int
+}
+
+// Reads the log (stderr, in JSON) of an execution of the delay benchmark, in intervals of 'bucket'
+// seconds of each run. the delay is the number of operations the main worker has not seen yet,
+// given the latest value of each counter read by the other workers (as process_delay_log.py)
+func readDelayLog(path string, mainWorker int, bucket float64) ([]delayPoint, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	type key struct {
+		workers int
+		time    float64
+	}
+	intervals := map[key]*delayInterval{}
+	interval := func(workers int, t time.Time, begin time.Time) *delayInterval {
+		elapsed := t.Sub(begin).Seconds()
+		if bucket > 0 {
+			elapsed = float64(int(elapsed/bucket)) * bucket
+		}
+		k := key{workers, elapsed}
+		if intervals[k] == nil {
+			intervals[k] = &delayInterval{}
+		}
+		return intervals[k]
+	}
+
+	latest := map[string]float64{} // counter -> latest value read by the other workers
+	var begin time.Time
+	workers := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	for scanner.Scan() {
+		entry := map[string]any{}
+		if json.Unmarshal(scanner.Bytes(), &entry) != nil {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339Nano, stringField(entry, "time"))
+		if err != nil {
+			continue
+		}
+
+		switch stringField(entry, "message") {
+		case "Run started":
+			workers = int(numberField(entry, "workers"))
+			latest = map[string]float64{}
+			begin = time.Time{}
+		case "Running":
+			if begin.IsZero() {
+				begin = t
+			}
+		case "completed":
+			if realTime, err := time.Parse(time.RFC3339Nano, stringField(entry, "real_time")); err == nil && !begin.IsZero() {
+				interval(workers, realTime, begin).transactions++
+			}
+		case "read":
+			if begin.IsZero() {
+				continue
+			}
+			if int(numberField(entry, "worker")) != mainWorker {
+				for k, v := range entry {
+					if value, ok := v.(float64); ok && strings.HasPrefix(k, "_") {
+						latest[k] = max(latest[k], value)
+					}
+				}
+				continue
+			}
+			i := interval(workers, t, begin)
+			if len(latest) > 0 {
+				missing := 0.
+				for k, value := range latest {
+					missing += max(value-numberField(entry, k), 0)
+				}
+				i.delay += missing * numberField(entry, "totalCounters") / float64(len(latest))
+			}
+			i.measurements++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	keys := []key{}
+	for k := range intervals {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].workers != keys[j].workers {
+			return keys[i].workers < keys[j].workers
+		}
+		return keys[i].time < keys[j].time
+	})
+	points := []delayPoint{}
+	delay := 0.
+	for i, k := range keys {
+		// intervals without measurements keep the delay of the previous one
+		if i > 0 && keys[i-1].workers != k.workers {
+			delay = 0
+		}
+		if v := intervals[k]; v.measurements > 0 {
+			delay = v.delay / float64(v.measurements)
+		}
+		tps := float64(intervals[k].transactions)
+		if bucket > 0 {
+			tps /= bucket
+		}
+		points = append(points, delayPoint{workers: k.workers, time: k.time, delay: delay, tps: tps})
+	}
+	return points, nil
+}
+
+func stringField(entry map[string]any, key string) string {
+	s, _ := entry[key].(string)
+	return s
+}
+
+// Returns a number of a log entry (0 if absent)
+func numberField(entry map[string]any, key string) float64 {
+	v, _ := entry[key].(float64)
+	return v
+}
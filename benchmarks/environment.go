@@ -0,0 +1,75 @@
+package main
+
+import (
+	engine "benchmarks/benchmark/engines/abstract"
+	"benchmarks/store"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+
+	zlog "github.com/rs/zerolog/log"
+)
+
+// Describes the host and Go runtime of this process, and the revision it was built from
+func clientEnvironment() map[string]string {
+	env := map[string]string{
+		"os":         runtime.GOOS,
+		"arch":       runtime.GOARCH,
+		"cpus":       strconv.Itoa(runtime.NumCPU()),
+		"gomaxprocs": strconv.Itoa(runtime.GOMAXPROCS(0)),
+		"goVersion":  runtime.Version(),
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		env["hostname"] = hostname
+	}
+	// linux only
+	if kernel, err := os.ReadFile("/proc/sys/kernel/osrelease"); err == nil {
+		env["kernel"] = strings.TrimSpace(string(kernel))
+	}
+	if meminfo, err := os.ReadFile("/proc/meminfo"); err == nil {
+		for _, line := range strings.Split(string(meminfo), "\n") {
+			if total, ok := strings.CutPrefix(line, "MemTotal:"); ok {
+				env["memory"] = strings.TrimSpace(total)
+			}
+		}
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				env["revision"] = setting.Value
+			case "vcs.modified":
+				env["modified"] = setting.Value
+			}
+		}
+	}
+	return env
+}
+
+// Captures the environment of a run: this process as the client, and the server of each site.
+// a site that cannot be described is recorded with the error instead of stopping the run
+func snapshotEnvironment(connections []any, registration *engine.Registration) *store.Environment {
+	environment := &store.Environment{
+		Clients: []map[string]string{clientEnvironment()},
+		Sites:   []map[string]string{},
+	}
+	for i, connection := range connections {
+		site := map[string]string{}
+		if registration.Environment != nil {
+			env, err := registration.Environment(connection)
+			if err != nil {
+				zlog.Warn().Int("site", i).Err(err).Msg("Could not capture the environment")
+				env = map[string]string{"error": err.Error()}
+			}
+			// engines may describe nothing (nil)
+			if env != nil {
+				site = env
+			}
+		}
+		site["engine"] = registration.Name
+		environment.Sites = append(environment.Sites, site)
+	}
+	return environment
+}
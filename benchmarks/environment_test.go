@@ -0,0 +1,17 @@
+package main
+
+import (
+	engine "benchmarks/benchmark/engines/abstract"
+	"testing"
+)
+
+func TestSnapshotEnvironmentWithoutDescription(t *testing.T) {
+	registration := &engine.Registration{
+		Name:        "test",
+		Environment: func(connection any) (map[string]string, error) { return nil, nil },
+	}
+	environment := snapshotEnvironment([]any{"site0"}, registration)
+	if len(environment.Sites) != 1 || environment.Sites[0]["engine"] != "test" {
+		t.Errorf("expected the engine of the site, got %v", environment.Sites)
+	}
+}
@@ -3,17 +3,20 @@ module benchmarks
 go 1.21
 
 require (
-	github.com/automerge/automerge-go v0.0.0-20240213171625-b7d9d510d501 // indirect
+	github.com/automerge/automerge-go v0.0.0-20240213171625-b7d9d510d501
+	github.com/basho/riak-go-client v1.7.0
+	github.com/google/uuid v1.6.0
+	github.com/lib/pq v1.10.9
+	github.com/mattn/go-isatty v0.0.20
+	github.com/mattn/go-sqlite3 v1.14.22
+	github.com/rs/zerolog v1.31.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
 	github.com/basho/backoff v0.0.0-20150307023525-2ff7c4694083 // indirect
-	github.com/basho/riak-go-client v1.7.0 // indirect
 	github.com/golang/protobuf v1.5.3 // indirect
-	github.com/google/uuid v1.6.0 // indirect
-	github.com/lib/pq v1.10.9 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
-	github.com/mattn/go-isatty v0.0.20 // indirect
-	github.com/mattn/go-sqlite3 v1.14.22 // indirect
-	github.com/rs/zerolog v1.31.0 // indirect
 	golang.org/x/sys v0.14.0 // indirect
 	google.golang.org/protobuf v1.26.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
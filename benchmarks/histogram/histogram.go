@@ -0,0 +1,123 @@
+package histogram
+
+import (
+	"math"
+	"math/bits"
+)
+
+// Log-linear (HDR-style) histogram of response times, with microsecond resolution. Values below
+// subBucketCount are stored exactly; above that, each power of two is split into subBucketHalf
+// buckets, so the relative error is bounded by 1/subBucketHalf (~0.4%). Memory is bounded by the
+// magnitude of the largest value recorded, not by the number of values, and histograms can be
+// merged across workers and runs. Not safe for concurrent use.
+type Histogram struct {
+	Counts []int64 // number of values in each bucket (grown on demand)
+	Total  int64   // number of values recorded
+	Sum    int64   // sum of the values recorded (microseconds)
+	Min    int64   // smallest value recorded (microseconds)
+	Max    int64   // largest value recorded (microseconds)
+}
+
+const subBucketBits = 9
+const subBucketCount = 1 << subBucketBits
+const subBucketHalf = subBucketCount / 2
+
+func New() *Histogram {
+	return &Histogram{Min: math.MaxInt64}
+}
+
+// Returns the bucket of a value (microseconds)
+func index(v int64) int {
+	if v < subBucketCount {
+		return int(v)
+	}
+	exp := bits.Len64(uint64(v)) - subBucketBits
+	return exp*subBucketHalf + int(v>>exp)
+}
+
+// Returns the highest value (microseconds) that falls in some bucket
+func highestEquivalent(i int) int64 {
+	if i < subBucketCount {
+		return int64(i)
+	}
+	exp := i/subBucketHalf - 1
+	sub := int64(i - exp*subBucketHalf)
+	return ((sub + 1) << exp) - 1
+}
+
+// Records a value, in seconds
+func (h *Histogram) Record(seconds float64) {
+	h.RecordMicros(int64(math.Round(seconds * 1e6)))
+}
+
+// Records a value, in microseconds
+func (h *Histogram) RecordMicros(v int64) {
+	v = max(v, 0)
+	i := index(v)
+	if i >= len(h.Counts) {
+		h.Counts = append(h.Counts, make([]int64, i-len(h.Counts)+1)...)
+	}
+	h.Counts[i]++
+	h.Total++
+	h.Sum += v
+	h.Min = min(h.Min, v)
+	h.Max = max(h.Max, v)
+}
+
+// Adds the values of another histogram to this one
+func (h *Histogram) Merge(other *Histogram) {
+	if other == nil || other.Total == 0 {
+		return
+	}
+	if len(other.Counts) > len(h.Counts) {
+		h.Counts = append(h.Counts, make([]int64, len(other.Counts)-len(h.Counts))...)
+	}
+	for i, c := range other.Counts {
+		h.Counts[i] += c
+	}
+	h.Total += other.Total
+	h.Sum += other.Sum
+	h.Min = min(h.Min, other.Min)
+	h.Max = max(h.Max, other.Max)
+}
+
+// Returns the number of values recorded
+func (h *Histogram) Count() int64 {
+	return h.Total
+}
+
+// Returns the average value, in seconds
+func (h *Histogram) Mean() float64 {
+	if h.Total == 0 {
+		return math.NaN()
+	}
+	return float64(h.Sum) / float64(h.Total) / 1e6
+}
+
+// Returns the largest value, in seconds
+func (h *Histogram) MaxValue() float64 {
+	if h.Total == 0 {
+		return math.NaN()
+	}
+	return float64(h.Max) / 1e6
+}
+
+// Returns the value (seconds) below which p% (0-100) of the values fall
+func (h *Histogram) Percentile(p float64) float64 {
+	if h.Total == 0 {
+		return math.NaN()
+	}
+
+	target := int64(math.Ceil(p / 100 * float64(h.Total)))
+	target = min(max(target, 1), h.Total)
+
+	var curr int64
+	for i, c := range h.Counts {
+		curr += c
+		if curr >= target {
+			return float64(min(max(highestEquivalent(i), h.Min), h.Max)) / 1e6
+		}
+	}
+
+	return float64(h.Max) / 1e6
+}
@@ -0,0 +1,132 @@
+package histogram
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// Value below which p% of the sorted values fall, as defined by Percentile
+func reference(sorted []int64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return float64(sorted[min(max(rank, 1), len(sorted))-1]) / 1e6
+}
+
+// Whether a value is within the error of the buckets of the expected one
+func withinBucket(value float64, expected float64) bool {
+	return math.Abs(value-expected) <= expected/subBucketHalf+1e-6
+}
+
+func TestRecord(t *testing.T) {
+	h := New()
+	if !math.IsNaN(h.Percentile(50)) || !math.IsNaN(h.Mean()) || !math.IsNaN(h.MaxValue()) {
+		t.Errorf("expected NaN statistics without values")
+	}
+	for _, v := range []float64{0.000001, 0.000003, 0.000002, -0.5} {
+		h.Record(v)
+	}
+	// small values are exact, and negative ones are recorded as 0
+	if h.Count() != 4 || h.Min != 0 || h.Max != 3 || h.Sum != 6 {
+		t.Errorf("unexpected histogram %+v", h)
+	}
+	if p := h.Percentile(50); p != 0.000001 {
+		t.Errorf("p50 = %v, expected 0.000001", p)
+	}
+	if m := h.Mean(); math.Abs(m-0.0000015) > 1e-12 {
+		t.Errorf("mean = %v, expected 0.0000015", m)
+	}
+}
+
+func TestPercentiles(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	h := New()
+	values := []int64{}
+	for i := 0; i < 100000; i++ {
+		// log-normal response times, around 2ms
+		v := int64(math.Round(math.Exp(r.NormFloat64()+math.Log(0.002)) * 1e6))
+		values = append(values, v)
+		h.RecordMicros(v)
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+
+	for _, p := range []float64{50, 90, 99, 99.9} {
+		if value, expected := h.Percentile(p), reference(values, p); !withinBucket(value, expected) {
+			t.Errorf("p%v = %v, expected %v", p, value, expected)
+		}
+	}
+	// the maximum is exact
+	if value, expected := h.Percentile(100), float64(values[len(values)-1])/1e6; value != expected || h.MaxValue() != expected {
+		t.Errorf("max = %v (p100 = %v), expected %v", h.MaxValue(), value, expected)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	merged := New()
+	all := New()
+	values := []int64{}
+	for worker := 0; worker < 4; worker++ {
+		h := New()
+		for i := 0; i < 10000; i++ {
+			// each worker with a different scale
+			v := int64(r.ExpFloat64() * float64(1000*(worker+1)))
+			values = append(values, v)
+			h.RecordMicros(v)
+			all.RecordMicros(v)
+		}
+		merged.Merge(h)
+	}
+	merged.Merge(nil)
+	merged.Merge(New())
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+
+	if merged.Total != all.Total || merged.Sum != all.Sum || merged.Min != all.Min || merged.Max != all.Max {
+		t.Errorf("merged %v values (sum %v, min %v, max %v), expected %v (sum %v, min %v, max %v)",
+			merged.Total, merged.Sum, merged.Min, merged.Max, all.Total, all.Sum, all.Min, all.Max)
+	}
+	for _, p := range []float64{50, 99, 99.9, 100} {
+		if merged.Percentile(p) != all.Percentile(p) {
+			t.Errorf("p%v = %v after merging, expected %v", p, merged.Percentile(p), all.Percentile(p))
+		}
+		if value, expected := merged.Percentile(p), reference(values, p); !withinBucket(value, expected) {
+			t.Errorf("p%v = %v, expected %v", p, value, expected)
+		}
+	}
+
+	// merging into an empty histogram, with fewer buckets
+	empty := New()
+	empty.Merge(merged)
+	if empty.Percentile(99) != merged.Percentile(99) || empty.Min != merged.Min {
+		t.Errorf("unexpected merge into an empty histogram: p99 = %v, min = %v", empty.Percentile(99), empty.Min)
+	}
+}
+
+func TestAboveTheTopBucket(t *testing.T) {
+	h := New()
+	for i := 0; i < 99; i++ {
+		h.Record(0.001)
+	}
+	top := len(h.Counts)
+	// an hour, far above the buckets allocated so far
+	h.Record(3600)
+	if len(h.Counts) <= top || h.Counts[index(3600*1e6)] != 1 {
+		t.Fatalf("expected the buckets to grow to the value, got %d buckets", len(h.Counts))
+	}
+	if p := h.Percentile(100); p != 3600 {
+		t.Errorf("max = %v, expected 3600", p)
+	}
+	if p := h.Percentile(99); !withinBucket(p, 0.001) {
+		t.Errorf("p99 = %v, expected 0.001", p)
+	}
+
+	// the largest values keep the relative error of the buckets
+	huge := int64(1) << 50
+	h.RecordMicros(huge)
+	if v := highestEquivalent(index(huge)); v < huge || float64(v-huge) > float64(huge)/subBucketHalf {
+		t.Errorf("bucket of %d up to %d", huge, v)
+	}
+	if p := h.Percentile(100); p != float64(huge)/1e6 {
+		t.Errorf("max = %v, expected %v", p, float64(huge)/1e6)
+	}
+}
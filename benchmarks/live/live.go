@@ -0,0 +1,343 @@
+// Live metrics of a benchmark in progress, served at /metrics in the Prometheus text format.
+// Recording is a no-op until Serve (or Enable, for the dashboard) is called, so runs without live
+// metrics pay nothing.
+package live
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Upper bounds (seconds) of the response time histogram buckets
+var Buckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type Labels map[string]string
+
+type Sample struct {
+	Labels Labels
+	Value  float64
+}
+
+// Live metrics of an operation, shared by all workers
+type Operation struct {
+	mu       sync.Mutex
+	outcomes map[string]int64 // outcome ('committed', 'timeout', 'dropped', or the error class) -> count
+	retries  int64
+	late     int64
+	buckets  []int64 // number of committed operations in each bucket (not cumulative)
+	count    int64
+	sum      float64
+}
+
+type gauge struct {
+	help   string
+	values map[string]Sample // formatted labels -> sample
+}
+
+type gaugeFunc struct {
+	help string
+	f    func() []Sample
+}
+
+var enabled atomic.Bool
+var lock sync.Mutex
+var operations = map[string]*Operation{}
+var gauges = map[string]*gauge{}
+var gaugeFuncs = map[string]gaugeFunc{}
+
+// Starts serving the metrics at http://address/metrics
+func Serve(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		Write(w)
+	})
+	Enable()
+	go http.Serve(listener, mux)
+	return nil
+}
+
+// Starts recording without serving the metrics (e.g., for the dashboard, which reads them directly)
+func Enable() {
+	enabled.Store(true)
+}
+
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// Returns the metrics of an operation (nil, on which recording is a no-op, if not enabled)
+func ForOperation(name string) *Operation {
+	if !Enabled() {
+		return nil
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if operations[name] == nil {
+		operations[name] = &Operation{outcomes: map[string]int64{}, buckets: make([]int64, len(Buckets)+1)}
+	}
+	return operations[name]
+}
+
+// Records the execution of an operation; 'outcome' is 'committed', 'timeout', 'dropped', or the
+// error class, and rt (seconds) is only considered for committed operations
+func (o *Operation) Record(outcome string, rt float64, retries int, late bool) {
+	if o == nil {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.outcomes[outcome]++
+	o.retries += int64(retries)
+	if late {
+		o.late++
+	}
+	if outcome == "committed" {
+		o.buckets[sort.SearchFloat64s(Buckets, rt)]++
+		o.count++
+		o.sum += rt
+	}
+}
+
+// Sets the value of a gauge (a no-op if not enabled)
+func SetGauge(name string, help string, labels Labels, value float64) {
+	if !Enabled() {
+		return
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if gauges[name] == nil {
+		gauges[name] = &gauge{help, map[string]Sample{}}
+	}
+	gauges[name].values[formatLabels(labels)] = Sample{labels, value}
+}
+
+// Registers a gauge computed on each scrape, replacing any other with the same name
+func GaugeFunc(name string, help string, f func() []Sample) {
+	lock.Lock()
+	defer lock.Unlock()
+	gaugeFuncs[name] = gaugeFunc{help, f}
+}
+
+func RemoveGaugeFunc(name string) {
+	lock.Lock()
+	defer lock.Unlock()
+	delete(gaugeFuncs, name)
+}
+
+func escape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func formatLabels(labels Labels) string {
+	pairs := []string{}
+	for k, v := range labels {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, k, escape(v)))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Formats a sample line, merging the extra label (e.g., le="0.1") into the others
+func sample(name string, labels string, extra string, value any) string {
+	if labels != "" && extra != "" {
+		labels += ","
+	}
+	labels += extra
+	if labels != "" {
+		return fmt.Sprintf("%s{%s} %v\n", name, labels, value)
+	}
+	return fmt.Sprintf("%s %v\n", name, value)
+}
+
+func header(name string, help string, kind string) string {
+	return fmt.Sprintf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// Writes all metrics in the Prometheus text format
+func Write(w io.Writer) {
+	// gauge functions may be slow (e.g., query a database), so they are copied and called unlocked
+	lock.Lock()
+	names := []string{}
+	for name := range operations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	ops := []*Operation{}
+	for _, name := range names {
+		ops = append(ops, operations[name])
+	}
+	funcs := map[string]gaugeFunc{}
+	for name, f := range gaugeFuncs {
+		funcs[name] = f
+	}
+	out := strings.Builder{}
+	gaugeNames := []string{}
+	for name := range gauges {
+		gaugeNames = append(gaugeNames, name)
+	}
+	sort.Strings(gaugeNames)
+	for _, name := range gaugeNames {
+		out.WriteString(header(name, gauges[name].help, "gauge"))
+		labels := []string{}
+		for l := range gauges[name].values {
+			labels = append(labels, l)
+		}
+		sort.Strings(labels)
+		for _, l := range labels {
+			out.WriteString(sample(name, l, "", gauges[name].values[l].Value))
+		}
+	}
+	lock.Unlock()
+
+	funcNames := []string{}
+	for name := range funcs {
+		funcNames = append(funcNames, name)
+	}
+	sort.Strings(funcNames)
+	for _, name := range funcNames {
+		out.WriteString(header(name, funcs[name].help, "gauge"))
+		for _, s := range funcs[name].f() {
+			out.WriteString(sample(name, formatLabels(s.Labels), "", s.Value))
+		}
+	}
+
+	if len(ops) > 0 {
+		outcomes := strings.Builder{}
+		retries := strings.Builder{}
+		late := strings.Builder{}
+		rts := strings.Builder{}
+		for i, o := range ops {
+			o.mu.Lock()
+			op := formatLabels(Labels{"operation": names[i]})
+			keys := []string{}
+			for k := range o.outcomes {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				outcomes.WriteString(sample("benchmark_operations_total", formatLabels(Labels{"operation": names[i], "outcome": k}), "", o.outcomes[k]))
+			}
+			retries.WriteString(sample("benchmark_operation_retries_total", op, "", o.retries))
+			late.WriteString(sample("benchmark_operation_late_total", op, "", o.late))
+			cumulative := int64(0)
+			for j, bound := range Buckets {
+				cumulative += o.buckets[j]
+				rts.WriteString(sample("benchmark_operation_duration_seconds_bucket", op, fmt.Sprintf(`le="%v"`, bound), cumulative))
+			}
+			rts.WriteString(sample("benchmark_operation_duration_seconds_bucket", op, `le="+Inf"`, o.count))
+			rts.WriteString(sample("benchmark_operation_duration_seconds_sum", op, "", o.sum))
+			rts.WriteString(sample("benchmark_operation_duration_seconds_count", op, "", o.count))
+			o.mu.Unlock()
+		}
+		out.WriteString(header("benchmark_operations_total", "Executed operations, by outcome (committed, timeout, dropped, or the error class)", "counter"))
+		out.WriteString(outcomes.String())
+		out.WriteString(header("benchmark_operation_retries_total", "Retries of the operations", "counter"))
+		out.WriteString(retries.String())
+		out.WriteString(header("benchmark_operation_late_total", "Operations started after their intended start (open-loop)", "counter"))
+		out.WriteString(late.String())
+		out.WriteString(header("benchmark_operation_duration_seconds", "Response time of the committed operations", "histogram"))
+		out.WriteString(rts.String())
+	}
+
+	io.WriteString(w, out.String())
+}
+
+// Counts of an operation at some instant
+type Snapshot struct {
+	Outcomes map[string]int64
+	Buckets  []int64 // committed operations in each bucket (not cumulative; the last one is above all bounds)
+	Count    int64
+}
+
+// Returns the current counts of each operation
+func Operations() map[string]Snapshot {
+	lock.Lock()
+	defer lock.Unlock()
+	snapshots := map[string]Snapshot{}
+	for name, o := range operations {
+		o.mu.Lock()
+		outcomes := map[string]int64{}
+		for k, v := range o.outcomes {
+			outcomes[k] = v
+		}
+		snapshots[name] = Snapshot{outcomes, append([]int64{}, o.buckets...), o.count}
+		o.mu.Unlock()
+	}
+	return snapshots
+}
+
+// Returns the counts between a previous snapshot and this one
+func (s Snapshot) Sub(previous Snapshot) Snapshot {
+	d := Snapshot{Outcomes: map[string]int64{}, Buckets: make([]int64, len(s.Buckets)), Count: s.Count - previous.Count}
+	for k, v := range s.Outcomes {
+		d.Outcomes[k] = v - previous.Outcomes[k]
+	}
+	for i := range s.Buckets {
+		d.Buckets[i] = s.Buckets[i]
+		if i < len(previous.Buckets) {
+			d.Buckets[i] -= previous.Buckets[i]
+		}
+	}
+	return d
+}
+
+// Estimates a quantile (seconds) of the response time, interpolating within its bucket (NaN if
+// there are no committed operations; the upper bound of the last bucket is the last one)
+func (s Snapshot) Quantile(q float64) float64 {
+	if s.Count <= 0 {
+		return math.NaN()
+	}
+	rank := q * float64(s.Count)
+	cumulative := int64(0)
+	for i, n := range s.Buckets {
+		if n > 0 && float64(cumulative+n) >= rank {
+			if i >= len(Buckets) {
+				return Buckets[len(Buckets)-1]
+			}
+			lower := 0.0
+			if i > 0 {
+				lower = Buckets[i-1]
+			}
+			return lower + (Buckets[i]-lower)*(rank-float64(cumulative))/float64(n)
+		}
+		cumulative += n
+	}
+	return Buckets[len(Buckets)-1]
+}
+
+// Returns the current samples of a gauge, calling it if it is a gauge function (nil if not set)
+func Gauge(name string) []Sample {
+	lock.Lock()
+	f, isFunc := gaugeFuncs[name]
+	samples := []Sample{}
+	if g := gauges[name]; g != nil {
+		labels := []string{}
+		for l := range g.values {
+			labels = append(labels, l)
+		}
+		sort.Strings(labels)
+		for _, l := range labels {
+			samples = append(samples, g.values[l])
+		}
+	}
+	lock.Unlock()
+	if isFunc {
+		return f.f()
+	}
+	if len(samples) == 0 {
+		return nil
+	}
+	return samples
+}
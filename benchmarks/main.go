@@ -3,23 +3,40 @@ package main
 import (
 	"benchmarks/benchmark"
 	"benchmarks/benchmark/delay"
+	engine "benchmarks/benchmark/engines/abstract"
+	_ "benchmarks/benchmark/engines/crdv"
+	_ "benchmarks/benchmark/engines/electric"
+	_ "benchmarks/benchmark/engines/native"
+	_ "benchmarks/benchmark/engines/pg_crdt"
+	_ "benchmarks/benchmark/engines/riak"
 	"benchmarks/benchmark/micro"
 	"benchmarks/benchmark/nested"
+	"benchmarks/benchmark/replay"
 	timestampencoding "benchmarks/benchmark/timestampEncoding"
+	"benchmarks/histogram"
+	"benchmarks/live"
+	"benchmarks/stats"
+	"benchmarks/store"
+	"benchmarks/trace"
 	"benchmarks/util"
 	"benchmarks/worker"
-	"database/sql"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"math"
+	"math/rand"
 	"os"
-	"reflect"
+	"os/signal"
+	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
-	"github.com/basho/riak-go-client"
-	_ "github.com/lib/pq"
 	"github.com/rs/zerolog"
 	zlog "github.com/rs/zerolog/log"
 	"gopkg.in/yaml.v3"
@@ -37,17 +54,90 @@ type BenchmarkArgs struct {
 	Isolation    string
 	Benchmark    string
 	Engine       string
-	FileData     []byte // config file contents
+	FileData     []byte `yaml:"-"` // config file contents
 	Operations   []worker.Operation
+	Rate         float64 // target throughput (ops/sec); 0 = closed-loop
+	RateScope    string  `yaml:"rateScope"` // whether the rate is 'global' (split among workers) or per 'worker'
+	Arrival      string  // inter-arrival times of the open-loop mode: 'constant' or 'poisson'
+	MaxLag       float64 `yaml:"maxLag"` // (seconds) operations more late than this are dropped (0 = never)
+	Interval     float64 // length (seconds) of each time series interval (0 = disabled)
+	Timeout      float64 // (seconds) operations taking longer than this are cancelled (0 = never)
+	Seed         int64   // random choices of the workers and populate are derived from it (0 = random)
+
+	// error class -> retry policy (classes absent are not retried)
+	Retry map[string]worker.RetryPolicy
+
+	// runs whose total tps or rt deviates from the median by more than this fraction are flagged
+	// as outliers (0 = disabled)
+	OutlierThreshold float64 `yaml:"outlierThreshold"`
+	// keeps adding runs (up to maxRuns) until the 95% confidence interval of the total tps is
+	// narrower than this fraction of the mean (0 = always execute 'runs' runs)
+	CiTarget float64 `yaml:"ciTarget"`
+	MaxRuns  int     `yaml:"maxRuns"`
+
+	Sweep Sweep      // executes the benchmark for multiple values of some config keys
+	Point SweepPoint `yaml:"-"` // values of the swept keys in this execution
+
+	// remote agents that run the workers, instead of this process (see 'benchmarks agent')
+	Agents []AgentArgs
+
+	// phases of each run, in sequence, each with its own operations, rate, and number of workers
+	// (replaces 'time')
+	Phases []PhaseArgs
+
+	// directory where the operations issued by each worker are recorded, to be replayed with the
+	// 'replay' benchmark (empty = disabled)
+	Record string
+
+	// detects the end of the warmup of each run, instead of using a fixed one (nil = disabled)
+	SteadyState *SteadyStateArgs `yaml:"steadyState"`
+
+	// connection pool of each site, and whether each worker has a dedicated connection instead
+	Pool engine.Pool
+
+	// workers and operations of each site, in the order of 'connection' (default: the workers are
+	// split evenly, with the top-level operations)
+	Placement []SiteArgs
 }
 
 type ProcessedResult struct {
-	name  string
-	rt    float64
-	ct    float64
-	tps   float64
-	ar    float64
-	rtP95 float64
+	name    string
+	rt      float64
+	ct      float64
+	tps     float64
+	ar      float64
+	to      float64            // number of operations that timed out
+	errors  map[string]float64 // number of aborted operations of each error class
+	retries float64            // number of retries
+	rtRetry float64            // response time including retries
+	rtP50   float64
+	rtP90   float64
+	rtP95   float64
+	rtP99   float64
+	rtP999  float64
+	rtMax   float64
+	late    float64
+	drop    float64
+	rts     *histogram.Histogram // response times, used to compute the percentiles
+
+	runs    int                      // number of runs combined
+	outlier bool                     // whether the run deviates from the others (single run)
+	stats   map[string]stats.Summary // column -> statistics of the per-run values
+	partial bool                     // whether the execution was interrupted
+
+	warmup   float64 // (seconds) excluded at the start of the run (mean of the runs, in summaries)
+	unsteady bool    // whether no steady state was detected (in some run), so the fixed warmup was used
+}
+
+// Splits a comma-separated list, ignoring empty elements
+func splitList(list string) []string {
+	elements := []string{}
+	for _, e := range strings.Split(list, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			elements = append(elements, e)
+		}
+	}
+	return elements
 }
 
 // Prepare zerolog
@@ -65,7 +155,7 @@ func setupLogging(disableLog bool, level string) {
 }
 
 // Returns a BenchmarkArgs struct with the information in the configFile.
-func buildArgs(configFile string) *BenchmarkArgs {
+func readConfig(configFile string) []byte {
 	if configFile == "" {
 		log.Fatal("Missing config file.")
 	}
@@ -74,17 +164,88 @@ func buildArgs(configFile string) *BenchmarkArgs {
 	if err != nil {
 		log.Fatal(err)
 	}
+	return data
+}
 
+func buildArgs(data []byte) *BenchmarkArgs {
 	args := BenchmarkArgs{}
-	err = yaml.Unmarshal(data, &args)
+	err := yaml.Unmarshal(data, &args)
 	if err != nil {
 		log.Fatal(err)
 	}
 	args.FileData = data
 
+	// with a fixed number of workers in every site, 'workers' can be omitted
+	if fixed, ok := fixedWorkers(&args); ok && len(args.Workers) == 0 {
+		args.Workers = []int{fixed}
+	}
+	if err := checkPhases(&args); err != nil {
+		log.Fatal(err)
+	}
+	if args.SteadyState != nil {
+		args.SteadyState.fillDefaults()
+	}
+	if args.Pool.Mode == "" {
+		args.Pool.Mode = "shared"
+	}
+
 	return &args
 }
 
+// Returns the config file contents with a random seed, if none is set, so every execution can be
+// replayed with the seed recorded in its results. the other keys keep their lines
+func withSeed(data []byte) []byte {
+	if buildArgs(data).Seed != 0 {
+		return data
+	}
+	doc := yaml.Node{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		log.Fatal(err)
+	}
+	seed := int64(0)
+	for seed == 0 {
+		seed = rand.Int63()
+	}
+	value := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.FormatInt(seed, 10)}
+	root := doc.Content[0]
+	i := 0
+	for i < len(root.Content) && root.Content[i].Value != "seed" {
+		i += 2
+	}
+	if i < len(root.Content) {
+		root.Content[i+1] = value
+	} else {
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "seed"}, value)
+	}
+	return util.Try(yaml.Marshal(&doc))
+}
+
+// Expands the sweep of the config file into the arguments of each execution (a single one, if
+// there is no sweep)
+func buildSweepArgs(data []byte) []*BenchmarkArgs {
+	data = withSeed(data)
+	sweep := buildArgs(data).Sweep
+	points, err := sweep.Points()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	allArgs := []*BenchmarkArgs{}
+	for _, point := range points {
+		pointData := data
+		if len(sweep.Params) > 0 {
+			pointData, err = applySweep(data, point)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+		args := buildArgs(pointData)
+		args.Point = point
+		allArgs = append(allArgs, args)
+	}
+	return allArgs
+}
+
 // Returns a benchmark factory based on the benchmarkType. configData is a binary representation of
 // the configuration file, so each benchmark can deserialize its respective parameters.
 func getBenchmarkFactory(benchmarkType string, configData []byte) func(int) benchmark.Benchmark {
@@ -99,6 +260,8 @@ func getBenchmarkFactory(benchmarkType string, configData []byte) func(int) benc
 		factory = func(id int) benchmark.Benchmark { return delay.New(id, configData) }
 	case "nested":
 		factory = func(id int) benchmark.Benchmark { return nested.New(id, configData) }
+	case "replay":
+		factory = func(id int) benchmark.Benchmark { return replay.New(id, configData) }
 	default:
 		log.Fatalf("Benchmark '%s' not found.\n", benchmarkType)
 	}
@@ -106,159 +269,267 @@ func getBenchmarkFactory(benchmarkType string, configData []byte) func(int) benc
 	return factory
 }
 
-// Create the sql.DB or riak.Client connections
-func createConnections(args *BenchmarkArgs) []any {
-	connections := []any{}
-
-	if strings.Contains(args.Engine, "riak") {
-		for _, v := range args.Connection {
-			clientOptions := &riak.NewClientOptions{
-				RemoteAddresses: []string{v},
-			}
-			client := util.Try(riak.NewClient(clientOptions))
-			connections = append(connections, client)
-		}
-	} else {
-		for _, v := range args.Connection {
-			db := util.Try(sql.Open("postgres", v))
-			db.SetMaxOpenConns(100)
-			// the number of idle connections should be the same as the number of actual connections.
-			// otherwise, if the number of workers is smaller than the number of open connections,
-			// the system will enter in a trashing state where connections are constantly being
-			// created and destroyed, because at least one connection will end up being considered
-			// idle. this causes a significant performance decrease, as the majority of the time
-			// will be spent at the "database/sql.(*Stmt).connStmt" function.
-			db.SetMaxIdleConns(100)
-			util.CheckErr(db.Ping())
-			util.Try(db.Exec("alter system set default_transaction_isolation = '" + args.Isolation + "'"))
-			util.Try(db.Exec("select pg_reload_conf()"))
-			connections = append(connections, db)
-		}
+// Returns the engine used by the benchmark (plain postgres, if none is configured)
+func engineRegistration(args *BenchmarkArgs) *engine.Registration {
+	name := args.Engine
+	if name == "" {
+		name = "postgres"
+	}
+	registration, err := engine.LookupEngine(name)
+	if err != nil {
+		log.Fatal(err)
 	}
+	return registration
+}
 
+// Create the connections to each site (e.g., sql.DB or riak.Client, depending on the engine)
+func createConnections(args *BenchmarkArgs, registration *engine.Registration) []any {
+	connections := []any{}
+	for _, v := range args.Connection {
+		connections = append(connections, registration.Connect(v, args.Isolation, args.Pool))
+	}
 	return connections
 }
 
-// Change the transactional isolation to the default value (sql) and close the connections
-func closeConnections(args *BenchmarkArgs, connections []any) {
-	if strings.Contains(args.Engine, "riak") {
-		connections_ := util.CastArray[any, *riak.Client](connections)
-		for _, client := range connections_ {
-			client.Stop()
-		}
-	} else {
-		dbs := util.CastArray[any, *sql.DB](connections)
-		for _, db := range dbs {
-			util.Try(db.Exec("alter system set default_transaction_isolation = default"))
-			util.Try(db.Exec("select pg_reload_conf()"))
-			db.Close()
-		}
+// Close the connections (the engine restores any settings changed, e.g., the isolation)
+func closeConnections(connections []any, registration *engine.Registration) {
+	for _, connection := range connections {
+		registration.Close(connection)
+	}
+}
+
+// Exposes the current size of each site's database in the live metrics, if served
+func serveDbSize(connections []any, registration *engine.Registration) {
+	if !live.Enabled() || registration.Size == nil {
+		return
 	}
+	live.GaugeFunc("benchmark_db_size_bytes", "Current size of the database of each site", func() []live.Sample {
+		samples := []live.Sample{}
+		for i, connection := range connections {
+			if size, err := registration.Size(connection); err == nil {
+				samples = append(samples, live.Sample{Labels: live.Labels{"site": strconv.Itoa(i)}, Value: float64(size)})
+			}
+		}
+		return samples
+	})
 }
 
-// Create nWorkers with the respective arguments, connections, and benchmark.
-func createWorkers(nWorkers int, args *BenchmarkArgs, connections []any, benchmarkFactory func(int) benchmark.Benchmark) []*worker.Worker {
-	connections_ := reflect.ValueOf(connections)
+// Create nWorkers with the respective arguments, connections, sites, and benchmark.
+func createWorkers(nWorkers int, run int, args *BenchmarkArgs, connections []any, sites []int, benchmarkFactory func(int) benchmark.Benchmark) []*worker.Worker {
 	workers := []*worker.Worker{}
 
 	for i := 0; i < nWorkers; i++ {
-		w := worker.NewWorker(i, args.Time, args.Transactions/nWorkers, args.Warmup, args.Cooldown,
-			connections_.Index(i%connections_.Len()).Interface(), args.Operations, benchmarkFactory(i))
-		workers = append(workers, w)
+		workers = append(workers, newWorker(i, nWorkers, run, args, connections[i], sites[i], benchmarkFactory))
 	}
 
 	return workers
 }
 
-// Computes the average value of a list of results
+// Prepares the workers concurrently, returning once all are ready to run, so the origin of the time
+// series taken next does not include their preparation. fails if any worker fails to prepare
+func prepareWorkers(workers []*worker.Worker) error {
+	errs := make([]error, len(workers))
+	var wg sync.WaitGroup
+	for i, w := range workers {
+		wg.Add(1)
+		go func(i int, w *worker.Worker) {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					errs[i] = fmt.Errorf("worker %d: %v", i, r)
+				}
+			}()
+			w.Prepare()
+		}(i, w)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// Create the worker 'id', out of nWorkers (possibly spread among multiple agents), for the given run
+// and site (index of the connection)
+func newWorker(id int, nWorkers int, run int, args *BenchmarkArgs, connection any, site int, benchmarkFactory func(int) benchmark.Benchmark) *worker.Worker {
+	warmup := args.Warmup
+	if args.SteadyState != nil {
+		// the whole run is measured, and the warmup is trimmed once detected
+		warmup = 0
+	}
+	operations := siteOperations(site, args)
+	w := worker.NewWorker(id, args.Time, args.Transactions/nWorkers, warmup, args.Cooldown,
+		connection, operations, benchmarkFactory(id))
+	if args.SteadyState != nil {
+		w.SetSteadyState(args.SteadyState.Window)
+	}
+	if args.Rate > 0 || len(args.Phases) > 0 {
+		w.SetOpenLoop(workerRate(args, nWorkers), args.Arrival == "poisson", args.MaxLag)
+	}
+	if len(args.Phases) > 0 {
+		w.SetPhases(workerPhases(id, nWorkers, args, operations))
+	}
+	w.SetTimeout(args.Timeout)
+	w.SetRetryPolicies(args.Retry)
+	w.SetSeed(args.Seed)
+	if args.Record != "" {
+		w.SetTrace(util.Try(trace.Create(trace.WorkerPath(recordDir(args, nWorkers, run), id))))
+	}
+	return w
+}
+
+// Returns the directory of the traces of a run (one per sweep point, number of workers, and run)
+func recordDir(args *BenchmarkArgs, nWorkers int, run int) string {
+	dir := fmt.Sprintf("w%d-r%d", nWorkers, run)
+	if len(args.Point) > 0 {
+		dir = strings.ReplaceAll(args.Point.String(), " ", ",") + "-" + dir
+	}
+	return filepath.Join(args.Record, dir)
+}
+
+// Returns the target rate (ops/sec) of each worker, in open-loop
+func workerRate(args *BenchmarkArgs, nWorkers int) float64 {
+	if args.RateScope == "worker" {
+		return args.Rate
+	}
+	return args.Rate / float64(nWorkers)
+}
+
+// Returns the value of a result column (see resultColumns)
+func metricValue(r ProcessedResult, metric string) float64 {
+	switch metric {
+	case "rt":
+		return r.rt
+	case "tps":
+		return r.tps
+	case "ct":
+		return r.ct
+	case "ar":
+		return r.ar
+	case "rtP50":
+		return r.rtP50
+	case "rtP90":
+		return r.rtP90
+	case "rtP95":
+		return r.rtP95
+	case "rtP99":
+		return r.rtP99
+	case "rtP999":
+		return r.rtP999
+	case "rtMax":
+		return r.rtMax
+	case "late":
+		return r.late
+	case "dropped":
+		return r.drop
+	case "timeouts":
+		return r.to
+	case "retries":
+		return r.retries
+	case "rtRetry":
+		return r.rtRetry
+	case worker.Serialization, worker.Deadlock, worker.Connection, worker.Semantic, worker.Other:
+		return r.errors[metric]
+	}
+	return math.NaN()
+}
+
 func avgMetric(results []ProcessedResult, metric string) float64 {
 	var total float64
 
 	for _, r := range results {
-		switch metric {
-		case "rt":
-			total += r.rt
-		case "ct":
-			total += r.ct
-		case "tps":
-			total += r.tps
-		case "ar":
-			total += r.ar
-		case "rtP95":
-			total += r.rtP95
-		}
+		total += metricValue(r, metric)
 	}
 
 	return total / float64(len(results))
 }
 
-// Prints a summary of the results.
-// Both the throughput (tps) and response time (rt) consider only the completed operations
-func aggregateResults(allResults [][]*worker.BenchmarkResults) map[string]ProcessedResult {
-	// metric -> values of each run
-	processedResults := map[string][]ProcessedResult{}
+// Fills the response time percentiles of a result based on its histogram
+func withPercentiles(r ProcessedResult) ProcessedResult {
+	r.rtP50 = r.rts.Percentile(50)
+	r.rtP90 = r.rts.Percentile(90)
+	r.rtP95 = r.rts.Percentile(95)
+	r.rtP99 = r.rts.Percentile(99)
+	r.rtP999 = r.rts.Percentile(99.9)
+	r.rtMax = r.rts.MaxValue()
+	return r
+}
 
-	// process the results of all runs
-	for _, results := range allResults {
-		rts := map[string][]float64{}
-		totalRts := map[string]float64{}
-		completeCounts := map[string]int{}
-		abortCounts := map[string]int{}
-		tps := map[string]float64{}
-		totalCompleted := 0
-		totalAborted := 0
-		totalRt := 0.
-		totalTps := 0.
-		allRts := []float64{}
-
-		// combine the results of all workers
-		for _, result := range results {
-			for operation, value := range result.Operations {
-				rts[operation] = append(rts[operation], value.Rts...)
-				totalRts[operation] += value.TotalRt
-				completeCounts[operation] += value.CompleteCount
-				abortCounts[operation] += value.AbortCount
-				tps[operation] += float64(value.CompleteCount) / result.RealDuration
-				totalCompleted += value.CompleteCount
-				totalAborted += value.AbortCount
-				totalRt += value.TotalRt
-				totalTps += float64(value.CompleteCount) / result.RealDuration
-				allRts = append(allRts, value.Rts...)
+// Combines the results of all workers of a run (operation -> result, "total" for all operations)
+func processRun(results []*worker.BenchmarkResults) map[string]ProcessedResult {
+	metrics := map[string]*worker.Metric{}
+	tps := map[string]float64{}
+	total := worker.NewMetric()
+	totalTps := 0.
+
+	for _, result := range results {
+		for operation, value := range result.Operations {
+			if metrics[operation] == nil {
+				metrics[operation] = worker.NewMetric()
 			}
+			metrics[operation].Merge(value)
+			total.Merge(value)
+			tps[operation] += float64(value.CompleteCount) / result.RealDuration
+			totalTps += float64(value.CompleteCount) / result.RealDuration
 		}
+	}
 
-		// add the run averages to all averages
-		for k := range rts {
-			processedResults[k] = append(processedResults[k], ProcessedResult{
-				name:  k,
-				rt:    totalRts[k] / float64(completeCounts[k]),
-				ct:    float64(completeCounts[k]),
-				tps:   float64(tps[k]),
-				ar:    float64(abortCounts[k]) / float64(abortCounts[k]+completeCounts[k]),
-				rtP95: util.Percentile(rts[k], 95),
-			})
-		}
+	processed := map[string]ProcessedResult{}
+	for k, m := range metrics {
+		r := processMetric(k, m, 1)
+		r.tps = tps[k]
+		r.runs = 1
+		processed[k] = r
+	}
 
-		// average of all operations
-		processedResults["total"] = append(processedResults["total"], ProcessedResult{
-			name:  "total",
-			rt:    totalRt / float64(totalCompleted),
-			ct:    float64(totalCompleted),
-			tps:   totalTps,
-			ar:    float64(totalAborted) / (float64(totalAborted + totalCompleted)),
-			rtP95: util.Percentile(allRts, 95),
-		})
+	// average of all operations
+	r := processMetric("total", total, 1)
+	r.tps = totalTps
+	r.runs = 1
+	processed["total"] = r
+
+	return processed
+}
+
+// Averages the results of all runs, along with the statistics of each column across runs
+func aggregateResults(runs []map[string]ProcessedResult) map[string]ProcessedResult {
+	// operation -> results of each run
+	processedResults := map[string][]ProcessedResult{}
+	for _, run := range runs {
+		for k, r := range run {
+			processedResults[k] = append(processedResults[k], r)
+		}
 	}
 
+	// the percentiles are computed over the response times of all runs combined
 	aggregated := map[string]ProcessedResult{}
 	for k, v := range processedResults {
-		aggregated[k] = ProcessedResult{
-			rt:    avgMetric(v, "rt"),
-			tps:   avgMetric(v, "tps"),
-			ar:    avgMetric(v, "ar"),
-			ct:    avgMetric(v, "ct"),
-			rtP95: avgMetric(v, "rtP95"),
+		rts := histogram.New()
+		for _, r := range v {
+			rts.Merge(r.rts)
+		}
+		errors := map[string]float64{}
+		for _, class := range abortClasses {
+			errors[class] = avgMetric(v, class)
 		}
+		aggregated[k] = withPercentiles(ProcessedResult{
+			rt:      avgMetric(v, "rt"),
+			tps:     avgMetric(v, "tps"),
+			ar:      avgMetric(v, "ar"),
+			ct:      avgMetric(v, "ct"),
+			to:      avgMetric(v, "timeouts"),
+			errors:  errors,
+			retries: avgMetric(v, "retries"),
+			rtRetry: avgMetric(v, "rtRetry"),
+			late:    avgMetric(v, "late"),
+			drop:    avgMetric(v, "dropped"),
+			rts:     rts,
+			runs:    len(v),
+			stats:   columnStats(v),
+		})
+		r := aggregated[k]
+		for _, run := range v {
+			r.warmup += run.warmup / float64(len(v))
+			r.unsteady = r.unsteady || run.unsteady
+		}
+		aggregated[k] = r
 	}
 
 	return aggregated
@@ -274,12 +545,33 @@ func shortenIsolation(isolation string) string {
 	}
 }
 
+// Classes of the errors that abort operations, in the order of the result columns (the timeouts
+// are reported on their own)
+var abortClasses = []string{worker.Serialization, worker.Deadlock, worker.Connection, worker.Semantic, worker.Other}
+
+// Names of the columns of each result
+const resultColumns = "rt,tps,ct,ar,rtP95,rtP50,rtP90,rtP99,rtP999,rtMax,late,dropped,timeouts," +
+	"retries,rtRetry,serialization,deadlock,connection,semantic,other"
+
+// Formats a result as comma-separated values, in the same order as resultColumns
+func formatResult(r ProcessedResult) string {
+	s := fmt.Sprintf("%.6f,%.3f,%.0f,%.6f,%.6f,%.6f,%.6f,%.6f,%.6f,%.6f,%.0f,%.0f,%.0f,%.0f,%.6f",
+		r.rt, r.tps, r.ct, r.ar, r.rtP95, r.rtP50, r.rtP90, r.rtP99, r.rtP999, r.rtMax, r.late, r.drop, r.to,
+		r.retries, r.rtRetry)
+	for _, class := range abortClasses {
+		s += fmt.Sprintf(",%.0f", r.errors[class])
+	}
+	return s
+}
+
+// last header printed by printSummary
+var lastCsvHeader string
+
 func printSummary(aggregated map[string]ProcessedResult,
 	args *BenchmarkArgs,
 	nWorkers int,
 	benchmarkConfigs map[string]string,
 	benchmarkMetrics map[string]string,
-	firstLine bool,
 ) {
 	sortedConfigs := []string{}
 	for k := range benchmarkConfigs {
@@ -293,29 +585,46 @@ func printSummary(aggregated map[string]ProcessedResult,
 	}
 	sort.Strings(sortedMetrics)
 
-	// CSV header
-	if firstLine {
-		if len(sortedMetrics) > 0 {
-			fmt.Println("Csv:benchmark,time,runs,noReload,workers,isolation,sites," +
-				strings.Join(sortedConfigs, ",") + "," + strings.Join(sortedMetrics, ",") + ",rt,tps,ct,ar,rtP95")
-		} else {
-			fmt.Println("Csv:benchmark,time,runs,noReload,workers,isolation,sites," +
-				strings.Join(sortedConfigs, ",") + ",rt,tps,ct,ar,rtP95")
-		}
-		fmt.Println("CsvOps:benchmark,time,runs,noReload,workers,isolation,sites," +
-			strings.Join(sortedConfigs, ",") + ",operation,rt,tps,ct,ar,rtP95")
+	// swept keys, right after the run metadata
+	sweepColumns, sweepValues := args.Point.Csv()
+
+	// CSV header (printed again if the columns change, e.g., when sweeping over engines)
+	header := "benchmark,time,runs,noReload,workers,isolation,sites,rate" + sweepColumns + "," +
+		strings.Join(sortedConfigs, ",")
+	csvHeader := header + "," + resultColumns
+	if len(sortedMetrics) > 0 {
+		csvHeader = header + "," + strings.Join(sortedMetrics, ",") + "," + resultColumns
+	}
+	if csvHeader != lastCsvHeader {
+		lastCsvHeader = csvHeader
+		fmt.Println("Csv:" + csvHeader)
+		fmt.Println("CsvOps:" + header + ",operation," + resultColumns)
 	}
 
 	isolation := shortenIsolation(args.Isolation)
 	// string for the benchmark specific metrics ("Csv:" prefix)
-	csv := fmt.Sprintf("Csv:%s,%d,%d,%t,%d,%s,%d",
-		args.Benchmark, args.Time, args.Runs, args.NoReload, nWorkers, isolation, len(args.Connection))
+	nRuns := aggregated["total"].runs
+	csv := fmt.Sprintf("Csv:%s,%d,%d,%t,%d,%s,%d,%g%s",
+		args.Benchmark, args.Time, nRuns, args.NoReload, nWorkers, isolation, len(args.Connection), args.Rate,
+		sweepValues)
 	// string for the operations ("CsvOps:" prefix)
-	csvOps := fmt.Sprintf("CsvOps:%s,%d,%d,%t,%d,%s,%d",
-		args.Benchmark, args.Time, args.Runs, args.NoReload, nWorkers, isolation, len(args.Connection))
+	csvOps := fmt.Sprintf("CsvOps:%s,%d,%d,%t,%d,%s,%d,%g%s",
+		args.Benchmark, args.Time, nRuns, args.NoReload, nWorkers, isolation, len(args.Connection), args.Rate,
+		sweepValues)
 	// string with metrics in a key-value format to ease reading
-	kv := fmt.Sprintf("benchmark: %s\ntime: %d\nruns: %d\nnoReload: %t\nworkers: %d\nisolation: %s\nsites: %d",
-		args.Benchmark, args.Time, args.Runs, args.NoReload, nWorkers, isolation, len(args.Connection))
+	kv := fmt.Sprintf("benchmark: %s\ntime: %d\nruns: %d\nnoReload: %t\nworkers: %d\nisolation: %s\nsites: %d\nrate: %g",
+		args.Benchmark, args.Time, nRuns, args.NoReload, nWorkers, isolation, len(args.Connection), args.Rate)
+	for _, v := range args.Point {
+		kv += fmt.Sprintf("\n%s: %v", v.Key, v.Value)
+	}
+	if aggregated["total"].partial {
+		kv += "\npartial: true"
+	}
+	kv += fmt.Sprintf("\nwarmup: %g", aggregated["total"].warmup)
+	if aggregated["total"].unsteady {
+		kv += "\nunsteady: true"
+	}
+	kv += fmt.Sprintf("\nseed: %d", args.Seed)
 
 	// write benchmark-specific configs
 	for _, config := range sortedConfigs {
@@ -338,44 +647,89 @@ func printSummary(aggregated map[string]ProcessedResult,
 			kv += fmt.Sprintf("\nct: %.6f", result.ct)
 			kv += fmt.Sprintf("\nar: %.6f", result.ar)
 			kv += fmt.Sprintf("\nrtP95: %.6f", result.rtP95)
-			csv += fmt.Sprintf(",%.6f,%.3f,%.0f,%.6f,%.6f", result.rt, result.tps, result.ct, result.ar, result.rtP95)
+			kv += fmt.Sprintf("\nrtP50: %.6f", result.rtP50)
+			kv += fmt.Sprintf("\nrtP90: %.6f", result.rtP90)
+			kv += fmt.Sprintf("\nrtP99: %.6f", result.rtP99)
+			kv += fmt.Sprintf("\nrtP999: %.6f", result.rtP999)
+			kv += fmt.Sprintf("\nrtMax: %.6f", result.rtMax)
+			kv += fmt.Sprintf("\nlate: %.0f", result.late)
+			kv += fmt.Sprintf("\ndropped: %.0f", result.drop)
+			kv += fmt.Sprintf("\ntimeouts: %.0f", result.to)
+			kv += fmt.Sprintf("\nretries: %.0f", result.retries)
+			kv += fmt.Sprintf("\nrtRetry: %.6f", result.rtRetry)
+			for _, metric := range []string{"tps", "rt"} {
+				s := result.stats[metric]
+				kv += fmt.Sprintf("\n%sStddev: %.6f", metric, s.Stddev)
+				kv += fmt.Sprintf("\n%sCv: %.6f", metric, s.Cv)
+				kv += fmt.Sprintf("\n%sCi95: [%.6f, %.6f]", metric, s.CiLow, s.CiHigh)
+			}
+			for _, class := range abortClasses {
+				kv += fmt.Sprintf("\n%s: %.0f", class, result.errors[class])
+			}
+			csv += "," + formatResult(result)
 		}
-		fmt.Println(csvOps + fmt.Sprintf(",%s,%.6f,%.3f,%.0f,%.6f,%.6f", metric, result.rt, result.tps, result.ct, result.ar, result.rtP95))
+		fmt.Println(csvOps + "," + metric + "," + formatResult(result))
 	}
 
 	fmt.Println(csv)
 	fmt.Println(kv)
 }
 
-func main() {
-	disableLog := flag.Bool("no-log", false, "Disables the log")
-	configFile := flag.String("conf", "", "Benchmark config file")
-	logLevel := flag.String("level", "debug", "Log level (info|debug)")
-	flag.Parse()
+// Runs nWorkers in this process, with the connection and site of each. Returns the results of each
+// worker, the origin of the time series intervals, and the configs and metrics of the first worker
+func runWorkers(ctx context.Context, args *BenchmarkArgs, nWorkers int, run int, connections []any, sites []int,
+	benchmarkFactory func(int) benchmark.Benchmark, c chan *worker.BenchmarkResults,
+) ([]*worker.BenchmarkResults, float64, map[string]string, map[string]string) {
+	workers := createWorkers(nWorkers, run, args, connections, sites, benchmarkFactory)
+	util.CheckErr(prepareWorkers(workers))
+	origin := util.EpochSeconds()
+	for _, w := range workers {
+		if args.Interval > 0 {
+			w.SetInterval(args.Interval, origin)
+		}
+		go func(w *worker.Worker) {
+			defer dash.recoverPanic()
+			w.Run(ctx, c)
+		}(w)
+	}
 
-	setupLogging(*disableLog, *logLevel)
-	args := buildArgs(*configFile)
-	benchmarkFactory := getBenchmarkFactory(args.Benchmark, args.FileData)
-	c := make(chan *worker.BenchmarkResults)
+	runResults := []*worker.BenchmarkResults{}
+	for k := 0; k < nWorkers; k++ {
+		runResults = append(runResults, <-c)
+	}
+
+	return runResults, origin, workers[0].GetConfigs(), workers[0].GetMetrics()
+}
 
+// Runs the benchmark for each number of workers. When ctx is done (e.g., Ctrl-C), the current run
+// is stopped and torn down, and the results gathered so far are reported as partial. A run that
+// fails (e.g., an agent error) is discarded and ends the benchmark the same way, through fail
+func run(ctx context.Context, fail context.CancelCauseFunc, args *BenchmarkArgs, registration *engine.Registration,
+	benchmarkFactory func(int) benchmark.Benchmark, writer *store.Writer, c chan *worker.BenchmarkResults,
+	firstExecution bool,
+) {
 	for i, nWorkers := range args.Workers {
-		// workaround for riak's connection limit of 256 per site
-		if args.Engine == "riak" && nWorkers/len(args.Connection) > 256 {
-			nWorkers = len(args.Connection) * 256
-		}
+		nWorkers = limitWorkers(nWorkers, args, registration)
 
-		allResults := [][]*worker.BenchmarkResults{}
+		runs := []map[string]ProcessedResult{}
+		siteRuns := [][]map[string]ProcessedResult{} // results of each site in each run (local runs only)
+		sites := placeWorkers(nWorkers, args)
+		environments := []*store.Environment{}
 		configs := map[string]string{}
 		metrics := map[string]string{}
 
-		zlog.Info().Int("workers", nWorkers).Msg("Run started")
+		zlog.Info().Int("workers", nWorkers).Str("sweep", args.Point.String()).Msg("Run started")
 
-		for j := 0; j < args.Runs; j++ {
+		for j := 0; ctx.Err() == nil && (j < args.Runs || (args.CiTarget > 0 && j < args.MaxRuns && !ciReached(runs, args.CiTarget))); j++ {
 			startTime := util.EpochSeconds()
-			connections := createConnections(args)
-			workers := createWorkers(nWorkers, args, connections, benchmarkFactory)
+			dash.startRun(i, nWorkers, j)
+			connections := createConnections(args, registration)
+			serveDbSize(connections, registration)
+			live.SetGauge("benchmark_workers", "Number of workers of the current run", nil, float64(nWorkers))
+			live.SetGauge("benchmark_run", "Index of the current run", nil, float64(j))
 			benchmark := benchmarkFactory(-1)
 			benchmark.Setup(connections)
+			environment := snapshotEnvironment(connections, registration)
 
 			if j == 0 || !args.NoReload {
 				fmt.Println("Populating")
@@ -383,30 +737,196 @@ func main() {
 			}
 
 			fmt.Println("Running")
-			for _, w := range workers {
-				go w.Run(c)
+			dash.startWorkers()
+			var runResults []*worker.BenchmarkResults
+			var origin float64
+			var runConfigs, runMetrics map[string]string
+			if len(args.Agents) > 0 {
+				var err error
+				runResults, origin, environment.Clients, err = runAgents(ctx, args, nWorkers, j)
+				if err != nil {
+					zlog.Error().Err(err).Int("run", j).Msg("Agents failed")
+					fail(err)
+				}
+				runConfigs, runMetrics = benchmark.GetConfigs(), benchmark.GetMetrics(connections[0])
+			} else {
+				workerConns, release := workerConnections(args, registration, args.Connection, connections, sites)
+				before, known := poolStats(connections, registration)
+				runResults, origin, runConfigs, runMetrics = runWorkers(ctx, args, nWorkers, j, workerConns, sites, benchmarkFactory, c)
+				if after, _ := poolStats(connections, registration); known {
+					if runMetrics == nil {
+						runMetrics = map[string]string{}
+					}
+					addPoolMetrics(runMetrics, after.Sub(before))
+				}
+				release()
 			}
 
-			results := []*worker.BenchmarkResults{}
-			for k := 0; k < nWorkers; k++ {
-				results = append(results, <-c)
+			dash.stopWorkers()
+
+			// an interrupted run is only kept if part of its measurement window was executed
+			if ctx.Err() == nil || measured(runResults) {
+				warmup, unsteady := float64(args.Warmup), false
+				if args.SteadyState != nil {
+					skip, steady := detectWarmup(runResults, args)
+					trimWarmup(runResults, skip, args.SteadyState.Window)
+					warmup, unsteady = float64(skip)*args.SteadyState.Window, !steady
+					zlog.Info().Int("run", j).Float64("warmup", warmup).Bool("steady", steady).Msg("Steady state")
+				} else if args.Time <= 0 {
+					warmup = 0
+				}
+				processed := processRun(runResults)
+				markWarmup(processed, warmup, unsteady)
+				if ctx.Err() != nil {
+					markPartial(processed)
+				}
+				runs = append(runs, processed)
+				if len(args.Agents) == 0 && len(args.Connection) > 1 {
+					bySite := processSites(runResults, sites, len(args.Connection))
+					for _, site := range bySite {
+						markWarmup(site, warmup, unsteady)
+						if ctx.Err() != nil {
+							markPartial(site)
+						}
+					}
+					siteRuns = append(siteRuns, bySite)
+				}
+				environments = append(environments, environment)
+				if len(configs) == 0 {
+					configs = runConfigs
+					metrics = runMetrics
+				}
+				if args.Interval > 0 {
+					intervals := aggregateIntervals(runResults, args, origin)
+					printIntervals(intervals, args, j, nWorkers, firstExecution && i == 0 && j == 0)
+					util.CheckErr(writeIntervals(writer, intervals, args, j, nWorkers, configs))
+				}
 			}
 
-			allResults = append(allResults, results)
-			if len(configs) == 0 {
-				configs = workers[0].GetConfigs()
-				metrics = workers[0].GetMetrics()
+			if len(runResults) > 0 {
+				fmt.Printf("setupTime=%v\n", (util.EpochSeconds() - startTime - runResults[0].RealDuration))
 			}
 
-			fmt.Printf("setupTime=%v\n", (util.EpochSeconds() - startTime - results[0].RealDuration))
-
 			benchmark.Finalize(connections)
-			closeConnections(args, connections)
+			live.RemoveGaugeFunc("benchmark_db_size_bytes")
+			closeConnections(connections, registration)
+		}
+
+		if len(runs) == 0 {
+			zlog.Warn().Int("workers", nWorkers).Msg("Interrupted before any results")
+			return
+		}
+
+		flagOutliers(runs, args.OutlierThreshold)
+		printRuns(runs, args, nWorkers, firstExecution && i == 0)
+		util.CheckErr(writeRuns(writer, runs, environments, args, nWorkers, configs, metrics))
+
+		aggregated := aggregateResults(runs)
+		if ctx.Err() != nil {
+			markPartial(aggregated)
+		}
+		printSummary(aggregated, args, nWorkers, configs, metrics)
+		printStats(aggregated, args, nWorkers, firstExecution && i == 0)
+		if len(siteRuns) > 0 {
+			printSites(siteRuns, args, nWorkers, firstExecution && i == 0)
+			util.CheckErr(writeSites(writer, siteRuns, args, nWorkers, configs))
+		}
+		summary := newRecord(store.KindSummary, args, -1, nWorkers, aggregated, configs, metrics)
+		summary.Run.Runs = len(runs)
+		summary.Environment = environments[0]
+		util.CheckErr(writer.Write(summary))
+
+		zlog.Info().Int("workers", nWorkers).Str("sweep", args.Point.String()).Msg("Run ended")
+		if ctx.Err() != nil {
+			return
 		}
+	}
+}
 
-		aggregated := aggregateResults(allResults)
-		printSummary(aggregated, args, nWorkers, configs, metrics, i == 0)
+func main() {
+	// subcommands
+	if len(os.Args) > 1 && os.Args[1] == "compare" {
+		os.Exit(compare(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "agent" {
+		os.Exit(agent(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		os.Exit(validateConfig(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "report" {
+		os.Exit(report(os.Args[2:]))
+	}
 
-		zlog.Info().Int("workers", nWorkers).Msg("Run ended")
+	disableLog := flag.Bool("no-log", false, "Disables the log")
+	configFile := flag.String("conf", "", "Benchmark config file")
+	logLevel := flag.String("level", "debug", "Log level (info|debug)")
+	resultFiles := flag.String("results", "",
+		"Comma-separated list of files to store the results (.jsonl, .csv, or .db/.sqlite)")
+	metricsAddress := flag.String("metrics", "", "Address to serve live metrics at /metrics, in the Prometheus format (e.g., :9100)")
+	dryRun := flag.Bool("dry-run", false, "Validates the config and prints the resolved config and planned runs, without connecting")
+	showDashboard := flag.Bool("dashboard", false,
+		"Shows the progress and live metrics of the runs in the terminal (or prints them periodically, if stdout is not a terminal)")
+	flag.Parse()
+
+	setupLogging(*disableLog, *logLevel)
+
+	// resolve and validate all executions before running any
+	allArgs, registrations, factories := resolve(readConfig(*configFile))
+	if *dryRun {
+		printPlan(allArgs, registrations, factories)
+		return
+	}
+
+	if *metricsAddress != "" {
+		util.CheckErr(live.Serve(*metricsAddress))
+	}
+
+	writer := util.Try(store.NewWriter(splitList(*resultFiles)))
+	defer writer.Close()
+	if *showDashboard {
+		dash = startDashboard(allArgs)
+		defer dash.close()
+	}
+	c := make(chan *worker.BenchmarkResults)
+
+	// the first interrupt stops the workers at the next operation boundary, reports the partial
+	// results, and tears everything down (restoring the servers); a second one exits immediately,
+	// after restoring the terminal
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	interrupted, interrupt := context.WithCancel(context.Background())
+	defer interrupt()
+	go func() {
+		<-signals
+		interrupt()
+		zlog.Warn().Msg("Interrupted, finishing the current operations (interrupt again to exit immediately)")
+		<-signals
+		dash.close()
+		os.Exit(130)
+	}()
+	// a failed run ends the benchmark as an interrupt does
+	ctx, fail := context.WithCancelCause(interrupted)
+	defer fail(nil)
+
+	for i, args := range allArgs {
+		if ctx.Err() != nil {
+			break
+		}
+		if len(args.Point) > 0 {
+			zlog.Info().Int("point", i).Int("points", len(allArgs)).Str("sweep", args.Point.String()).Msg("Sweep point started")
+		}
+		dash.startPoint(i)
+		run(ctx, fail, args, registrations[i], factories[i], writer, c, i == 0)
+	}
+
+	if ctx.Err() != nil {
+		writer.Close()
+		dash.close()
+		if err := context.Cause(ctx); err != context.Canceled {
+			zlog.Error().Err(err).Msg("Benchmark failed")
+			os.Exit(1)
+		}
+		os.Exit(130)
 	}
 }
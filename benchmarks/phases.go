@@ -0,0 +1,84 @@
+package main
+
+import (
+	"benchmarks/worker"
+	"fmt"
+	"slices"
+)
+
+// Phase of each run, with its own operation mix, rate, and number of workers
+type PhaseArgs struct {
+	Name       string
+	Duration   int                // seconds
+	Operations []worker.Operation // default: the top-level operations
+	Rate       *float64           // target throughput, as the top-level rate (default: the top-level rate)
+	Workers    int                // number of workers that issue operations in the phase (default: all)
+
+	inherited bool // whether the operations are the top-level ones (replaced by those of each site)
+}
+
+// Fills the defaults of the phases and checks them; the run lasts for their total duration
+func checkPhases(args *BenchmarkArgs) error {
+	names := []string{}
+	total := 0
+	for i := range args.Phases {
+		p := &args.Phases[i]
+		if p.Name == "" {
+			p.Name = fmt.Sprintf("phase%d", i)
+		}
+		if slices.Contains(names, p.Name) {
+			return fmt.Errorf("repeated phase '%s'", p.Name)
+		}
+		names = append(names, p.Name)
+		if p.Duration <= 0 {
+			return fmt.Errorf("phase '%s': the duration must be positive", p.Name)
+		}
+		if len(p.Operations) == 0 {
+			p.Operations = args.Operations
+			p.inherited = true
+		}
+		if p.Rate == nil {
+			p.Rate = &args.Rate
+		}
+		for _, nWorkers := range args.Workers {
+			if p.Workers > nWorkers {
+				return fmt.Errorf("phase '%s': %d workers, but some runs have only %d", p.Name, p.Workers, nWorkers)
+			}
+		}
+		total += p.Duration
+	}
+	if len(args.Phases) > 0 {
+		args.Time = total
+	}
+	return nil
+}
+
+// Returns the phases as seen by the worker 'id', out of nWorkers (the first ones are active in
+// phases with fewer workers), given the operations of its site
+func workerPhases(id int, nWorkers int, args *BenchmarkArgs, operations []worker.Operation) []worker.Phase {
+	phases := []worker.Phase{}
+	for _, p := range args.Phases {
+		active := nWorkers
+		if p.Workers > 0 {
+			active = min(p.Workers, nWorkers)
+		}
+		rate := *p.Rate
+		if args.RateScope != "worker" {
+			rate /= float64(active)
+		}
+		phaseOperations := p.Operations
+		if p.inherited {
+			phaseOperations = operations
+		}
+		phases = append(phases, worker.Phase{Duration: float64(p.Duration), Operations: phaseOperations, Rate: rate, Active: id < active})
+	}
+	return phases
+}
+
+func phaseDurations(args *BenchmarkArgs) []float64 {
+	durations := []float64{}
+	for _, p := range args.Phases {
+		durations = append(durations, float64(p.Duration))
+	}
+	return durations
+}
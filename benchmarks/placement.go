@@ -0,0 +1,184 @@
+package main
+
+import (
+	engine "benchmarks/benchmark/engines/abstract"
+	"benchmarks/worker"
+	"fmt"
+	"math"
+)
+
+// Workers and operations of a site (an element of 'placement', in the order of 'connection')
+type SiteArgs struct {
+	Workers    *int               // fixed number of workers of the site, in every run (default: by weight)
+	Weight     *float64           // share of the workers not fixed by any site, relative to the others (default: 1)
+	Operations []worker.Operation // default: the top-level operations
+}
+
+func (s SiteArgs) weight() float64 {
+	if s.Weight == nil {
+		return 1
+	}
+	return *s.Weight
+}
+
+// Returns the number of workers of each site, out of nWorkers: the fixed ones, and the others
+// split by weight (evenly, without placement), by the largest remainder
+func siteWorkers(nWorkers int, args *BenchmarkArgs) []int {
+	counts := make([]int, len(args.Connection))
+	remainders := make([]float64, len(args.Connection))
+	weights := make([]float64, len(args.Connection))
+	remaining := nWorkers
+	total := 0.
+	for i := range counts {
+		weights[i] = 1
+		if len(args.Placement) > 0 {
+			weights[i] = args.Placement[i].weight()
+			if fixed := args.Placement[i].Workers; fixed != nil {
+				weights[i] = 0
+				counts[i] = *fixed
+				remaining -= *fixed
+			}
+		}
+		total += weights[i]
+	}
+	if total == 0 || remaining <= 0 {
+		return counts
+	}
+
+	assigned := 0
+	for i, w := range weights {
+		share := float64(remaining) * w / total
+		counts[i] += int(share)
+		assigned += int(share)
+		remainders[i] = share - math.Floor(share)
+		if w == 0 {
+			remainders[i] = -1
+		}
+	}
+	for ; assigned < remaining; assigned++ {
+		best := 0
+		for i := range remainders {
+			if remainders[i] > remainders[best] {
+				best = i
+			}
+		}
+		counts[best]++
+		remainders[best] = -1
+	}
+	return counts
+}
+
+// Returns the total number of workers fixed in the placement, and whether every site has a fixed
+// number
+func fixedWorkers(args *BenchmarkArgs) (int, bool) {
+	total := 0
+	for _, s := range args.Placement {
+		if s.Workers == nil {
+			return 0, false
+		}
+		total += *s.Workers
+	}
+	return total, len(args.Placement) > 0
+}
+
+// Returns the site (index of the connection) of each worker, out of nWorkers. the sites are
+// interleaved in proportion to their workers, so the first workers (e.g., those active in a phase
+// with fewer workers) are spread as all of them (without placement, worker i uses site i % sites)
+func placeWorkers(nWorkers int, args *BenchmarkArgs) []int {
+	counts := siteWorkers(nWorkers, args)
+	assigned := make([]int, len(counts))
+	left := func(site int) float64 {
+		return float64(counts[site]-assigned[site]) / float64(counts[site])
+	}
+	sites := []int{}
+	for id := 0; id < nWorkers; id++ {
+		best := -1
+		for site := range counts {
+			if assigned[site] < counts[site] && (best < 0 || left(site) > left(best)) {
+				best = site
+			}
+		}
+		sites = append(sites, best)
+		assigned[best]++
+	}
+	return sites
+}
+
+// Returns the operations of the workers of a site
+func siteOperations(site int, args *BenchmarkArgs) []worker.Operation {
+	if site < len(args.Placement) && len(args.Placement[site].Operations) > 0 {
+		return args.Placement[site].Operations
+	}
+	return args.Operations
+}
+
+// Returns the errors in the placement
+func checkPlacement(args *BenchmarkArgs) []error {
+	if len(args.Placement) == 0 {
+		return nil
+	}
+	if len(args.Placement) != len(args.Connection) {
+		return []error{fmt.Errorf("placement must have one element per connection (%d, got %d)",
+			len(args.Connection), len(args.Placement))}
+	}
+	errs := []error{}
+	if len(args.Agents) > 0 {
+		errs = append(errs, fmt.Errorf("placement is not supported with agents"))
+	}
+	fixed := 0
+	weight := 0.
+	for i, s := range args.Placement {
+		if s.Workers != nil {
+			if *s.Workers < 0 {
+				errs = append(errs, fmt.Errorf("placement of site %d: workers must be non-negative", i))
+			}
+			fixed += *s.Workers
+		} else if s.weight() < 0 {
+			errs = append(errs, fmt.Errorf("placement of site %d: weight must be non-negative", i))
+		} else {
+			weight += s.weight()
+		}
+	}
+	for _, nWorkers := range args.Workers {
+		if fixed > nWorkers {
+			errs = append(errs, fmt.Errorf("placement: %d workers are fixed, but some runs have only %d", fixed, nWorkers))
+		} else if fixed < nWorkers && weight == 0 {
+			errs = append(errs, fmt.Errorf("placement: %d workers are fixed, and no site takes the other %d",
+				fixed, nWorkers-fixed))
+		}
+	}
+	return errs
+}
+
+// Checks the workers of each site against the connections of engines that limit them
+func checkPlacementLimit(args *BenchmarkArgs, registration *engine.Registration) error {
+	limit := connectionLimit(args, registration)
+	if len(args.Placement) != len(args.Connection) || limit == 0 {
+		return nil
+	}
+	for _, nWorkers := range args.Workers {
+		for site, count := range siteWorkers(nWorkers, args) {
+			if count > limit {
+				return fmt.Errorf("placement: site %d has %d workers, above the %d connections of engine '%s'",
+					site, count, limit, registration.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// Combines the results of the workers of each site (nil for sites without workers)
+func processSites(results []*worker.BenchmarkResults, sites []int, nSites int) []map[string]ProcessedResult {
+	bySite := make([][]*worker.BenchmarkResults, nSites)
+	for _, r := range results {
+		site := sites[r.Worker]
+		bySite[site] = append(bySite[site], r)
+	}
+	processed := make([]map[string]ProcessedResult, nSites)
+	for site, siteResults := range bySite {
+		if len(siteResults) > 0 {
+			processed[site] = processRun(siteResults)
+		}
+	}
+	return processed
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	engine "benchmarks/benchmark/engines/abstract"
+	"strconv"
+)
+
+// Returns the maximum number of workers per site of engines that fail instead of waiting when all
+// connections of a site are in use (0 = unlimited, as with dedicated connections)
+func connectionLimit(args *BenchmarkArgs, registration *engine.Registration) int {
+	limit := registration.MaxConnections
+	if limit > 0 && args.Pool.MaxOpen > 0 {
+		limit = args.Pool.MaxOpen
+	}
+	if args.Pool.Mode == "worker" {
+		return 0
+	}
+	return limit
+}
+
+// Returns the number of workers of a run, as limited by the connections of the engine (the
+// placement is checked against the limit instead, see checkPlacementLimit)
+func limitWorkers(nWorkers int, args *BenchmarkArgs, registration *engine.Registration) int {
+	limit := connectionLimit(args, registration)
+	if limit > 0 && len(args.Placement) == 0 && nWorkers/len(args.Connection) > limit {
+		return len(args.Connection) * limit
+	}
+	return nWorkers
+}
+
+// Returns the connection of each worker, given the index of its site in 'addresses': the pool of
+// its site, or in pool mode 'worker' a connection dedicated to it (see Registration.Dedicate),
+// along with their release (to be called after the run)
+func workerConnections(args *BenchmarkArgs, registration *engine.Registration, addresses []string,
+	pools []any, sites []int,
+) ([]any, func()) {
+	connections := []any{}
+	releases := []func(){}
+	for _, site := range sites {
+		switch {
+		case args.Pool.Mode != "worker":
+			connections = append(connections, pools[site])
+		case registration.Dedicate != nil:
+			connection, release := registration.Dedicate(pools[site])
+			connections = append(connections, connection)
+			releases = append(releases, release)
+		default:
+			connection := registration.Connect(addresses[site], args.Isolation, engine.Dedicated)
+			connections = append(connections, connection)
+			releases = append(releases, func() { registration.Close(connection) })
+		}
+	}
+	return connections, func() {
+		for _, release := range releases {
+			release()
+		}
+	}
+}
+
+// Returns the total usage of the pools of the connections, if known
+func poolStats(connections []any, registration *engine.Registration) (engine.PoolStats, bool) {
+	total := engine.PoolStats{}
+	if registration.PoolStats == nil {
+		return total, false
+	}
+	for _, connection := range connections {
+		s := registration.PoolStats(connection)
+		total.Waits += s.Waits
+		total.WaitTime += s.WaitTime
+		total.Opened += s.Opened
+		total.Closed += s.Closed
+	}
+	return total, true
+}
+
+// Adds the usage of the pools during a run to its metrics
+func addPoolMetrics(metrics map[string]string, s engine.PoolStats) {
+	metrics["poolWaits"] = strconv.FormatInt(s.Waits, 10)
+	metrics["poolWaitTime"] = strconv.FormatFloat(s.WaitTime.Seconds(), 'f', -1, 64)
+	metrics["poolOpened"] = strconv.FormatInt(s.Opened, 10)
+	metrics["poolClosed"] = strconv.FormatInt(s.Closed, 10)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	engine "benchmarks/benchmark/engines/abstract"
+	"fmt"
+	"testing"
+)
+
+func TestLimitWorkers(t *testing.T) {
+	tests := []struct {
+		name           string
+		maxConnections int
+		pool           engine.Pool
+		workers        int
+		expected       int
+	}{
+		{"unlimited", 0, engine.Pool{}, 64, 64},
+		{"below the limit", 16, engine.Pool{}, 16, 16},
+		{"above the limit", 16, engine.Pool{}, 64, 32},
+		{"maxOpen", 16, engine.Pool{MaxOpen: 4}, 64, 8},
+		{"dedicated connections", 16, engine.Pool{Mode: "worker"}, 64, 64},
+	}
+	for _, test := range tests {
+		args := &BenchmarkArgs{Connection: []string{"site0", "site1"}, Pool: test.pool}
+		registration := &engine.Registration{MaxConnections: test.maxConnections}
+		if n := limitWorkers(test.workers, args, registration); n != test.expected {
+			t.Errorf("%s: %d workers, expected %d", test.name, n, test.expected)
+		}
+	}
+}
+
+func TestWorkerConnections(t *testing.T) {
+	connected, dedicated, released := 0, 0, 0
+	registration := &engine.Registration{
+		Connect: func(address string, isolation string, pool engine.Pool) any {
+			connected++
+			return "connection of " + address
+		},
+		Close: func(connection any) { released++ },
+		Dedicate: func(connection any) (any, func()) {
+			dedicated++
+			return fmt.Sprintf("%v (%d)", connection, dedicated), func() { released++ }
+		},
+	}
+	pools := []any{"pool0", "pool1"}
+	sites := []int{0, 1, 0}
+
+	args := &BenchmarkArgs{Pool: engine.Pool{Mode: "shared"}}
+	connections, release := workerConnections(args, registration, []string{"site0", "site1"}, pools, sites)
+	release()
+	if fmt.Sprint(connections) != "[pool0 pool1 pool0]" || released != 0 {
+		t.Errorf("expected the workers to share the pools, got %v (%d released)", connections, released)
+	}
+
+	// the workers take a connection of their site's pool, instead of connecting to the site
+	args.Pool.Mode = "worker"
+	connections, release = workerConnections(args, registration, []string{"site0", "site1"}, pools, sites)
+	if fmt.Sprint(connections) != "[pool0 (1) pool1 (2) pool0 (3)]" || connected != 0 {
+		t.Errorf("expected a connection of the pools per worker, got %v (%d connected)", connections, connected)
+	}
+	release()
+	if released != 3 {
+		t.Errorf("expected the 3 connections to be released, got %d", released)
+	}
+
+	// engines that cannot dedicate a connection of a pool connect to the site
+	registration.Dedicate = nil
+	released = 0
+	connections, release = workerConnections(args, registration, []string{"site0", "site1"}, pools, sites)
+	release()
+	if connected != 3 || released != 3 || connections[1] != "connection of site1" {
+		t.Errorf("expected a connection per worker, got %v (%d connected, %d released)", connections, connected, released)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"benchmarks/stats"
+	"benchmarks/store"
+	"benchmarks/worker"
+	"sort"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Converts the processed results (operation -> result) to a list sorted by operation
+func toOperations(processed map[string]ProcessedResult) []store.Operation {
+	names := []string{}
+	for k := range processed {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	operations := []store.Operation{}
+	for _, name := range names {
+		r := processed[name]
+		operations = append(operations, store.Operation{
+			Name:          name,
+			Rt:            store.Float(r.rt),
+			Tps:           store.Float(r.tps),
+			Ct:            store.Float(r.ct),
+			Ar:            store.Float(r.ar),
+			RtP50:         store.Float(r.rtP50),
+			RtP90:         store.Float(r.rtP90),
+			RtP95:         store.Float(r.rtP95),
+			RtP99:         store.Float(r.rtP99),
+			RtP999:        store.Float(r.rtP999),
+			RtMax:         store.Float(r.rtMax),
+			Late:          store.Float(r.late),
+			Dropped:       store.Float(r.drop),
+			Timeouts:      store.Float(r.to),
+			Retries:       store.Float(r.retries),
+			RtRetry:       store.Float(r.rtRetry),
+			Serialization: store.Float(r.errors[worker.Serialization]),
+			Deadlock:      store.Float(r.errors[worker.Deadlock]),
+			Connection:    store.Float(r.errors[worker.Connection]),
+			Semantic:      store.Float(r.errors[worker.Semantic]),
+			Other:         store.Float(r.errors[worker.Other]),
+			Stats:         toStats(r.stats),
+		})
+	}
+
+	return operations
+}
+
+// Converts the statistics of each column (nil if there are none, e.g., in single runs)
+func toStats(summaries map[string]stats.Summary) map[string]store.Stat {
+	if summaries == nil {
+		return nil
+	}
+	converted := map[string]store.Stat{}
+	for k, s := range summaries {
+		converted[k] = store.Stat{
+			Runs:   s.N,
+			Mean:   store.Float(s.Mean),
+			Stddev: store.Float(s.Stddev),
+			Cv:     store.Float(s.Cv),
+			CiLow:  store.Float(s.CiLow),
+			CiHigh: store.Float(s.CiHigh),
+		}
+	}
+	return converted
+}
+
+// Builds a results record with the run metadata and configurations. run is the index of the run
+// (-1 for summaries).
+func newRecord(kind string, args *BenchmarkArgs, run int, nWorkers int, processed map[string]ProcessedResult,
+	benchmarkConfigs map[string]string, benchmarkMetrics map[string]string,
+) *store.Record {
+	config := map[string]any{}
+	yaml.Unmarshal(args.FileData, &config)
+
+	return &store.Record{
+		Kind:      kind,
+		Timestamp: time.Now(),
+		Run: store.Run{
+			Benchmark: args.Benchmark,
+			Engine:    args.Engine,
+			Time:      args.Time,
+			Runs:      args.Runs,
+			Run:       run,
+			NoReload:  args.NoReload,
+			Workers:   nWorkers,
+			Isolation: shortenIsolation(args.Isolation),
+			Sites:     len(args.Connection),
+			Rate:      args.Rate,
+			Partial:   processed["total"].partial,
+			Seed:      args.Seed,
+			Warmup:    processed["total"].warmup,
+			Unsteady:  processed["total"].unsteady,
+		},
+		Config:     config,
+		Sweep:      args.Point.Map(),
+		Configs:    benchmarkConfigs,
+		Metrics:    benchmarkMetrics,
+		Operations: toOperations(processed),
+	}
+}
+
+// Writes the time series intervals of a run
+func writeIntervals(writer *store.Writer, intervals []IntervalResult, args *BenchmarkArgs, run int,
+	nWorkers int, benchmarkConfigs map[string]string,
+) error {
+	for _, interval := range intervals {
+		record := newRecord(store.KindInterval, args, run, nWorkers, interval.results, benchmarkConfigs, nil)
+		record.Run.Start = interval.start
+		record.Run.Phase = interval.phase
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,408 @@
+package main
+
+import (
+	"benchmarks/chart"
+	"benchmarks/store"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Response time percentiles of the latency charts
+var reportPercentiles = []struct {
+	name  string
+	value func(store.Operation) store.Float
+}{
+	{"p50", func(o store.Operation) store.Float { return o.RtP50 }},
+	{"p90", func(o store.Operation) store.Float { return o.RtP90 }},
+	{"p95", func(o store.Operation) store.Float { return o.RtP95 }},
+	{"p99", func(o store.Operation) store.Float { return o.RtP99 }},
+	{"p99.9", func(o store.Operation) store.Float { return o.RtP999 }},
+}
+
+// Summary records with the same configuration except for the number of workers (see matchKey)
+type reportSeries struct {
+	label     string
+	fields    map[string]string
+	summaries []*store.Record // sorted by the number of workers
+}
+
+// Returns the summary of the largest number of workers
+func (s *reportSeries) last() *store.Record {
+	return s.summaries[len(s.summaries)-1]
+}
+
+// Reads the summary records of the results files, grouped into series (the last summary of each
+// number of workers wins, if repeated)
+func readSeries(paths []string, ignore []string) []*reportSeries {
+	ignore = append(ignore, "workers")
+	byKey := map[string]*reportSeries{}
+	keys := []string{}
+	for _, path := range paths {
+		records, err := store.Read(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, r := range records {
+			if r.Kind != store.KindSummary {
+				continue
+			}
+			key := matchKey(r, ignore)
+			s, ok := byKey[key]
+			if !ok {
+				s = &reportSeries{fields: matchFields(r, ignore)}
+				byKey[key] = s
+				keys = append(keys, key)
+			}
+			s.summaries = slices.DeleteFunc(s.summaries, func(old *store.Record) bool {
+				return old.Run.Workers == r.Run.Workers
+			})
+			s.summaries = append(s.summaries, r)
+		}
+	}
+
+	series := []*reportSeries{}
+	for _, key := range keys {
+		s := byKey[key]
+		sort.SliceStable(s.summaries, func(i, j int) bool { return s.summaries[i].Run.Workers < s.summaries[j].Run.Workers })
+		series = append(series, s)
+	}
+	labelSeries(series)
+	sort.SliceStable(series, func(i, j int) bool { return series[i].label < series[j].label })
+	return series
+}
+
+// Labels each series with the fields whose values differ among the series (the engine, if none)
+func labelSeries(series []*reportSeries) {
+	differ := []string{}
+	for _, s := range series {
+		for k, v := range s.fields {
+			for _, other := range series {
+				if w, ok := other.fields[k]; (!ok || w != v) && !slices.Contains(differ, k) {
+					differ = append(differ, k)
+				}
+			}
+		}
+	}
+	sort.Strings(differ)
+	for _, s := range series {
+		pairs := []string{}
+		for _, k := range differ {
+			if v, ok := s.fields[k]; ok {
+				pairs = append(pairs, k+"="+v)
+			}
+		}
+		if len(pairs) == 0 {
+			pairs = append(pairs, s.fields["benchmark"]+" on "+s.fields["engine"])
+		}
+		s.label = strings.Join(pairs, " ")
+	}
+}
+
+// Returns an operation of a record, if present
+func findOperation(r *store.Record, name string) (store.Operation, bool) {
+	for _, o := range r.Operations {
+		if o.Name == name {
+			return o, true
+		}
+	}
+	return store.Operation{}, false
+}
+
+// Returns a size metric of a record (bytes), if present
+func sizeMetric(r *store.Record, metric string) (float64, bool) {
+	v, err := strconv.ParseFloat(r.Metrics[metric], 64)
+	return v, err == nil
+}
+
+// Formats a value with the given number of decimals ('-' if it is not a number)
+func formatValue(v float64, decimals int) string {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return "-"
+	}
+	return strconv.FormatFloat(v, 'f', decimals, 64)
+}
+
+// Returns the header and rows of the summary table: the total results of each series and number
+// of workers, with the 95% confidence interval of the throughput across runs, and the storage
+// sizes, if any engine reports them
+func summaryTable(series []*reportSeries) ([]string, [][]string) {
+	sizes := false
+	for _, s := range series {
+		for _, r := range s.summaries {
+			if _, ok := sizeMetric(r, "endSize"); ok {
+				sizes = true
+			}
+		}
+	}
+
+	header := []string{"series", "workers", "runs", "tps", "rt (ms)", "p50 (ms)", "p99 (ms)", "aborts"}
+	if sizes {
+		header = append(header, "start size (MB)", "end size (MB)")
+	}
+	rows := [][]string{}
+	for _, s := range series {
+		for _, r := range s.summaries {
+			total, _ := findOperation(r, "total")
+			tps := formatValue(float64(total.Tps), 1)
+			if stat, ok := total.Stats["tps"]; ok && stat.Runs > 1 && !math.IsNaN(float64(stat.CiHigh)) {
+				tps += " ± " + formatValue(float64(stat.CiHigh-stat.CiLow)/2, 1)
+			}
+			row := []string{s.label, strconv.Itoa(r.Run.Workers), strconv.Itoa(r.Run.Runs), tps,
+				formatValue(float64(total.Rt)*1000, 3), formatValue(float64(total.RtP50)*1000, 3),
+				formatValue(float64(total.RtP99)*1000, 3), formatValue(float64(total.Ar)*100, 2) + "%"}
+			if sizes {
+				for _, metric := range []string{"startSize", "endSize"} {
+					v, ok := sizeMetric(r, metric)
+					if !ok {
+						v = math.NaN()
+					}
+					row = append(row, formatValue(v/1e6, 1))
+				}
+			}
+			rows = append(rows, row)
+		}
+	}
+	return header, rows
+}
+
+// Writes the summary table in Markdown
+func writeMarkdown(w io.Writer, series []*reportSeries) error {
+	header, rows := summaryTable(series)
+	escape := func(cells []string) string {
+		escaped := []string{}
+		for _, c := range cells {
+			escaped = append(escaped, strings.ReplaceAll(c, "|", `\|`))
+		}
+		return "| " + strings.Join(escaped, " | ") + " |\n"
+	}
+	separator := []string{}
+	for i := range header {
+		if i == 0 {
+			separator = append(separator, "---")
+		} else {
+			separator = append(separator, "---:")
+		}
+	}
+	out := escape(header) + "|" + strings.Join(separator, "|") + "|\n"
+	for _, row := range rows {
+		out += escape(row)
+	}
+	_, err := io.WriteString(w, out)
+	return err
+}
+
+// Chart of a report section
+type reportChart struct {
+	Title string
+	SVG   template.HTML
+}
+
+type reportSection struct {
+	Title  string
+	Note   string
+	Charts []reportChart
+}
+
+// Returns the charts of the summaries: throughput vs workers, the latency percentiles of each
+// operation (at the largest number of workers of each series), and the storage sizes
+func summarySections(series []*reportSeries) []reportSection {
+	sections := []reportSection{}
+
+	throughput := &chart.Line{XLabel: "Workers", YLabel: "Throughput (ops/s)"}
+	for _, s := range series {
+		line := chart.Series{Name: s.label}
+		for _, r := range s.summaries {
+			total, _ := findOperation(r, "total")
+			line.X = append(line.X, float64(r.Run.Workers))
+			line.Y = append(line.Y, float64(total.Tps))
+		}
+		throughput.Series = append(throughput.Series, line)
+	}
+	sections = append(sections, reportSection{Title: "Throughput vs workers",
+		Charts: []reportChart{{SVG: template.HTML(throughput.SVG())}}})
+
+	latency := reportSection{Title: "Latency percentiles per operation",
+		Note: "Response times of each operation, at the largest number of workers of each series."}
+	for _, s := range series {
+		r := s.last()
+		bars := &chart.Bars{YLabel: "Response time (ms)"}
+		for _, p := range reportPercentiles {
+			bars.Groups = append(bars.Groups, p.name)
+			bars.Values = append(bars.Values, []float64{})
+		}
+		for _, o := range r.Operations {
+			bars.Categories = append(bars.Categories, o.Name)
+			for i, p := range reportPercentiles {
+				bars.Values[i] = append(bars.Values[i], float64(p.value(o))*1000)
+			}
+		}
+		latency.Charts = append(latency.Charts, reportChart{
+			Title: fmt.Sprintf("%s (%d workers)", s.label, r.Run.Workers),
+			SVG:   template.HTML(bars.SVG()),
+		})
+	}
+	sections = append(sections, latency)
+
+	storage := &chart.Bars{YLabel: "Size (MB)", Groups: []string{"startSize", "endSize"}, Values: [][]float64{{}, {}}}
+	for _, s := range series {
+		for _, r := range s.summaries {
+			start, okStart := sizeMetric(r, "startSize")
+			end, okEnd := sizeMetric(r, "endSize")
+			if !okStart && !okEnd {
+				continue
+			}
+			storage.Categories = append(storage.Categories, fmt.Sprintf("%s, %d workers", s.label, r.Run.Workers))
+			storage.Values[0] = append(storage.Values[0], start/1e6)
+			storage.Values[1] = append(storage.Values[1], end/1e6)
+		}
+	}
+	if len(storage.Categories) > 0 {
+		sections = append(sections, reportSection{Title: "Storage",
+			Note:   "Size of the database before (startSize) and after (endSize) each run, as reported by the engine.",
+			Charts: []reportChart{{SVG: template.HTML(storage.SVG())}}})
+	}
+	return sections
+}
+
+// Returns the delay and throughput curves of the logs of delay benchmark executions
+func delaySection(paths []string, mainWorker int, bucket float64) reportSection {
+	section := reportSection{Title: "Delay", Note: fmt.Sprintf(
+		"Operations not yet applied at the site of worker %d, and the throughput, in intervals of %gs of each run.",
+		mainWorker, bucket)}
+	for _, path := range paths {
+		points, err := readDelayLog(path, mainWorker, bucket)
+		if err != nil {
+			log.Fatal(err)
+		}
+		delay := &chart.Line{XLabel: "Time (s)", YLabel: "Delay (missing operations)", LogY: true}
+		throughput := &chart.Line{XLabel: "Time (s)", YLabel: "Throughput (ops/s)"}
+		for i, p := range points {
+			if i == 0 || points[i-1].workers != p.workers {
+				name := fmt.Sprintf("%d workers", p.workers)
+				delay.Series = append(delay.Series, chart.Series{Name: name})
+				throughput.Series = append(throughput.Series, chart.Series{Name: name})
+			}
+			d, t := &delay.Series[len(delay.Series)-1], &throughput.Series[len(throughput.Series)-1]
+			d.X, d.Y = append(d.X, p.time), append(d.Y, p.delay)
+			t.X, t.Y = append(t.X, p.time), append(t.Y, p.tps)
+		}
+		section.Charts = append(section.Charts,
+			reportChart{Title: filepath.Base(path) + ": delay", SVG: template.HTML(delay.SVG())},
+			reportChart{Title: filepath.Base(path) + ": throughput", SVG: template.HTML(throughput.SVG())})
+	}
+	return section
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em auto; max-width: 1000px; color: #222; }
+table { border-collapse: collapse; font-size: 13px; }
+th, td { padding: 4px 8px; border-bottom: 1px solid #ddd; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+.note, .sources { color: #666; font-size: 13px; }
+figure { margin: 1em 0; }
+figcaption { font-weight: bold; font-size: 13px; margin-bottom: 4px; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="sources">Generated {{.Generated}} from {{range $i, $s := .Sources}}{{if $i}}, {{end}}<code>{{$s}}</code>{{end}}</p>
+{{if .Rows}}
+<h2>Summary</h2>
+<table>
+<tr>{{range .Header}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table>
+{{end}}
+{{range .Sections}}
+<h2>{{.Title}}</h2>
+{{if .Note}}<p class="note">{{.Note}}</p>{{end}}
+{{range .Charts}}<figure>{{if .Title}}<figcaption>{{.Title}}</figcaption>{{end}}
+{{.SVG}}
+</figure>
+{{end}}
+{{end}}
+</body>
+</html>
+`))
+
+// 'report' subcommand: renders results files (and logs of the delay benchmark) as a standalone
+// HTML report with inline SVG charts, and the summary as a Markdown table
+func report(arguments []string) int {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: benchmarks report [options] <results>...")
+		fmt.Fprintln(flags.Output(), "Renders result sets (.jsonl, .csv, or .db/.sqlite files written with -results) as an HTML report.")
+		flags.PrintDefaults()
+	}
+	output := flags.String("o", "report.html", "HTML report file")
+	markdown := flags.String("md", "", "File to write the summary table to, in Markdown ('-' = stdout)")
+	title := flags.String("title", "Benchmark report", "Title of the report")
+	ignore := flags.String("ignore", "", "Comma-separated list of keys ignored when grouping the results into series (e.g., engine)")
+	delayLogs := flags.String("log", "", "Comma-separated list of logs (stderr) of delay benchmark executions, for the delay curves")
+	mainWorker := flags.Int("worker", 0, "Worker that measures the delay, in the delay logs")
+	bucket := flags.Float64("bucket", 1, "Length (seconds) of each interval of the delay curves")
+	flags.Parse(arguments)
+
+	if flags.NArg() == 0 && *delayLogs == "" {
+		flags.Usage()
+		return 2
+	}
+
+	series := readSeries(flags.Args(), splitList(*ignore))
+	header, rows := summaryTable(series)
+	sections := []reportSection{}
+	if len(series) > 0 {
+		sections = summarySections(series)
+	}
+	if *delayLogs != "" {
+		sections = append(sections, delaySection(splitList(*delayLogs), *mainWorker, *bucket))
+	}
+
+	file, err := os.Create(*output)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+	err = reportTemplate.Execute(file, map[string]any{
+		"Title":     *title,
+		"Generated": time.Now().Format(time.DateTime),
+		"Sources":   append(flags.Args(), splitList(*delayLogs)...),
+		"Header":    header,
+		"Rows":      rows,
+		"Sections":  sections,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %s\n", *output)
+
+	if *markdown == "-" {
+		err = writeMarkdown(os.Stdout, series)
+	} else if *markdown != "" {
+		var md *os.File
+		if md, err = os.Create(*markdown); err == nil {
+			err = writeMarkdown(md, series)
+			md.Close()
+		}
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	return 0
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"benchmarks/stats"
+	"benchmarks/store"
+	"benchmarks/worker"
+	"fmt"
+	"sort"
+	"strings"
+
+	zlog "github.com/rs/zerolog/log"
+)
+
+// Columns whose statistics across runs are reported
+var statsColumns = strings.Split(resultColumns, ",")
+
+// Computes the statistics of each column over the results of multiple runs
+func columnStats(results []ProcessedResult) map[string]stats.Summary {
+	summaries := map[string]stats.Summary{}
+	for _, column := range statsColumns {
+		values := []float64{}
+		for _, r := range results {
+			values = append(values, metricValue(r, column))
+		}
+		summaries[column] = stats.Summarize(values)
+	}
+	return summaries
+}
+
+// Flags the runs whose total throughput or response time deviates from the median of all runs
+// by more than 'threshold' (0 = disabled)
+func flagOutliers(runs []map[string]ProcessedResult, threshold float64) {
+	if threshold <= 0 {
+		return
+	}
+
+	for _, metric := range []string{"tps", "rt"} {
+		values := []float64{}
+		for _, run := range runs {
+			values = append(values, metricValue(run["total"], metric))
+		}
+		for j, outlier := range stats.Outliers(values, threshold) {
+			if !outlier {
+				continue
+			}
+			zlog.Warn().Int("run", j).Str("metric", metric).Float64("value", values[j]).
+				Float64("median", stats.Median(values)).Msg("Outlier run")
+			for k, r := range runs[j] {
+				r.outlier = true
+				runs[j][k] = r
+			}
+		}
+	}
+}
+
+// Whether the 95% confidence interval of the total throughput is narrower than 'target' (as a
+// fraction of the mean)
+func ciReached(runs []map[string]ProcessedResult, target float64) bool {
+	values := []float64{}
+	for _, run := range runs {
+		values = append(values, run["total"].tps)
+	}
+	width := stats.Summarize(values).RelativeCiWidth()
+	if len(runs) > 1 {
+		zlog.Info().Int("runs", len(runs)).Float64("ciWidth", width).Float64("target", target).Msg("Confidence interval")
+	}
+	return width <= target
+}
+
+// Whether any worker executed part of the measurement window (e.g., before an interruption)
+func measured(results []*worker.BenchmarkResults) bool {
+	for _, r := range results {
+		if r.RealDuration > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Marks the results of an interrupted execution
+func markPartial(results map[string]ProcessedResult) {
+	for k, r := range results {
+		r.partial = true
+		results[k] = r
+	}
+}
+
+// Returns the operations of a result, sorted
+func sortedOperations(results map[string]ProcessedResult) []string {
+	operations := []string{}
+	for k := range results {
+		operations = append(operations, k)
+	}
+	sort.Strings(operations)
+	return operations
+}
+
+// Prints the results of each run, with the "CsvRuns:" prefix
+func printRuns(runs []map[string]ProcessedResult, args *BenchmarkArgs, nWorkers int, firstLine bool) {
+	sweepColumns, sweepValues := args.Point.Csv()
+
+	if firstLine {
+		fmt.Println("CsvRuns:benchmark" + sweepColumns + ",workers,run,outlier,operation," + resultColumns)
+	}
+
+	for j, run := range runs {
+		for _, operation := range sortedOperations(run) {
+			r := run[operation]
+			fmt.Printf("CsvRuns:%s%s,%d,%d,%t,%s,%s\n", args.Benchmark, sweepValues, nWorkers, j, r.outlier,
+				operation, formatResult(r))
+		}
+	}
+}
+
+// Prints the statistics of each column across runs, with the "CsvStats:" prefix
+func printStats(aggregated map[string]ProcessedResult, args *BenchmarkArgs, nWorkers int, firstLine bool) {
+	sweepColumns, sweepValues := args.Point.Csv()
+
+	if firstLine {
+		fmt.Println("CsvStats:benchmark" + sweepColumns + ",workers,operation,metric,runs,mean,stddev,cv,ciLow,ciHigh")
+	}
+
+	for _, operation := range sortedOperations(aggregated) {
+		for _, column := range statsColumns {
+			s := aggregated[operation].stats[column]
+			fmt.Printf("CsvStats:%s%s,%d,%s,%s,%d,%.6f,%.6f,%.6f,%.6f,%.6f\n", args.Benchmark, sweepValues,
+				nWorkers, operation, column, s.N, s.Mean, s.Stddev, s.Cv, s.CiLow, s.CiHigh)
+		}
+	}
+}
+
+// Prints the results of each site, averaged over all runs, with the "CsvSites:" prefix
+func printSites(siteRuns [][]map[string]ProcessedResult, args *BenchmarkArgs, nWorkers int, firstLine bool) {
+	sweepColumns, sweepValues := args.Point.Csv()
+
+	if firstLine {
+		fmt.Println("CsvSites:benchmark" + sweepColumns + ",workers,site,siteWorkers,operation," + resultColumns)
+	}
+
+	counts := siteWorkers(nWorkers, args)
+	for site, runs := range sitesByRun(siteRuns) {
+		if len(runs) == 0 {
+			continue
+		}
+		aggregated := aggregateResults(runs)
+		for _, operation := range sortedOperations(aggregated) {
+			fmt.Printf("CsvSites:%s%s,%d,%d,%d,%s,%s\n", args.Benchmark, sweepValues, nWorkers, site, counts[site],
+				operation, formatResult(aggregated[operation]))
+		}
+	}
+}
+
+// Transposes the results of each site in each run into the runs of each site
+func sitesByRun(siteRuns [][]map[string]ProcessedResult) [][]map[string]ProcessedResult {
+	runs := [][]map[string]ProcessedResult{}
+	for _, sites := range siteRuns {
+		for site, results := range sites {
+			if site >= len(runs) {
+				runs = append(runs, nil)
+			}
+			if results != nil {
+				runs[site] = append(runs[site], results)
+			}
+		}
+	}
+	return runs
+}
+
+// Writes the results of each site in each run, and averaged over all runs
+func writeSites(writer *store.Writer, siteRuns [][]map[string]ProcessedResult, args *BenchmarkArgs, nWorkers int,
+	benchmarkConfigs map[string]string,
+) error {
+	counts := siteWorkers(nWorkers, args)
+	write := func(run int, site int, results map[string]ProcessedResult) error {
+		record := newRecord(store.KindSite, args, run, nWorkers, results, benchmarkConfigs, nil)
+		record.Run.Runs = len(siteRuns)
+		record.Run.Site = site
+		record.Run.SiteWorkers = counts[site]
+		return writer.Write(record)
+	}
+
+	for j, sites := range siteRuns {
+		for site, results := range sites {
+			if results == nil {
+				continue
+			}
+			if err := write(j, site, results); err != nil {
+				return err
+			}
+		}
+	}
+	for site, runs := range sitesByRun(siteRuns) {
+		if len(runs) == 0 {
+			continue
+		}
+		if err := write(-1, site, aggregateResults(runs)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Writes the results of each run
+func writeRuns(writer *store.Writer, runs []map[string]ProcessedResult, environments []*store.Environment,
+	args *BenchmarkArgs, nWorkers int, benchmarkConfigs map[string]string, benchmarkMetrics map[string]string,
+) error {
+	for j, run := range runs {
+		record := newRecord(store.KindRun, args, j, nWorkers, run, benchmarkConfigs, benchmarkMetrics)
+		record.Run.Runs = len(runs)
+		record.Run.Outlier = run["total"].outlier
+		record.Environment = environments[j]
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
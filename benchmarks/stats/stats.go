@@ -0,0 +1,280 @@
+package stats
+
+import (
+	"math"
+	"sort"
+)
+
+// Descriptive statistics of a sample (e.g., the values of a metric in each run)
+type Summary struct {
+	N      int
+	Mean   float64
+	Stddev float64 // sample standard deviation (NaN with less than 2 values)
+	Cv     float64 // coefficient of variation (stddev / mean)
+	CiLow  float64 // 95% confidence interval of the mean, based on the t-distribution
+	CiHigh float64
+}
+
+func Mean(values []float64) float64 {
+	total := 0.
+	for _, v := range values {
+		total += v
+	}
+	return total / float64(len(values))
+}
+
+// Sample variance (n - 1 degrees of freedom)
+func Variance(values []float64) float64 {
+	if len(values) < 2 {
+		return math.NaN()
+	}
+	mean := Mean(values)
+	total := 0.
+	for _, v := range values {
+		total += (v - mean) * (v - mean)
+	}
+	return total / float64(len(values)-1)
+}
+
+func Median(values []float64) float64 {
+	if len(values) == 0 {
+		return math.NaN()
+	}
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	if len(sorted)%2 == 1 {
+		return sorted[len(sorted)/2]
+	}
+	return (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+}
+
+func Summarize(values []float64) Summary {
+	s := Summary{N: len(values), Mean: Mean(values), Stddev: math.Sqrt(Variance(values))}
+	s.Cv = s.Stddev / s.Mean
+	if s.Stddev == 0 {
+		s.Cv = 0
+	}
+	halfWidth := TQuantile(0.975, float64(s.N-1)) * s.Stddev / math.Sqrt(float64(s.N))
+	s.CiLow = s.Mean - halfWidth
+	s.CiHigh = s.Mean + halfWidth
+	return s
+}
+
+// Width of the confidence interval relative to the mean (e.g., 0.1 = 10% of the mean)
+func (s Summary) RelativeCiWidth() float64 {
+	if s.Mean == 0 {
+		return math.NaN()
+	}
+	return math.Abs((s.CiHigh - s.CiLow) / s.Mean)
+}
+
+// Flags the values that deviate from the median by more than 'threshold' (relative to the
+// median). the median is used instead of the mean/stddev as the number of runs is usually small,
+// where a single outlier drags the mean and inflates the stddev
+func Outliers(values []float64, threshold float64) []bool {
+	median := Median(values)
+	flags := make([]bool, len(values))
+	for i, v := range values {
+		if median != 0 {
+			flags[i] = math.Abs(v-median)/math.Abs(median) > threshold
+		}
+	}
+	return flags
+}
+
+// Length of the warmup of a series by MSER-5: the series is split in batches of 5 values, and the
+// first d batches are discarded, where d minimizes the standard error of the mean of the remaining
+// ones (up to half of the batches). Returns the number of values to discard, and whether the series
+// reaches a steady state (d is not the last candidate, with at least 4 batches, and no value is NaN
+// or infinite)
+func Mser5(values []float64) (int, bool) {
+	if !finite(values) {
+		return 0, false
+	}
+	batches := []float64{}
+	for i := 0; i+5 <= len(values); i += 5 {
+		batches = append(batches, Mean(values[i:i+5]))
+	}
+	if len(batches) < 4 {
+		return 0, false
+	}
+
+	last := len(batches) / 2
+	best := 0
+	bestMser := math.Inf(1)
+	for d := 0; d <= last; d++ {
+		rest := batches[d:]
+		mean := Mean(rest)
+		total := 0.
+		for _, b := range rest {
+			total += (b - mean) * (b - mean)
+		}
+		if mser := total / float64(len(rest)*len(rest)); mser < bestMser {
+			best, bestMser = d, mser
+		}
+	}
+	return best * 5, best < last
+}
+
+// Start of the first 'size' consecutive values whose coefficient of variation is at most maxCv,
+// and whose mean is within maxCv of the mean of all the values from there on (so a plateau during
+// the warmup is not taken for the steady state). Returns false if there are none, or if a value is
+// NaN or infinite
+func StableWindow(values []float64, size int, maxCv float64) (int, bool) {
+	if !finite(values) {
+		return 0, false
+	}
+	for i := 0; i+size <= len(values); i++ {
+		s := Summarize(values[i : i+size])
+		rest := Mean(values[i:])
+		if math.Abs(s.Cv) <= maxCv && (rest == s.Mean || math.Abs(s.Mean-rest) <= maxCv*math.Abs(rest)) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Whether none of the values is NaN or infinite
+func finite(values []float64) bool {
+	for _, v := range values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// Welch's t-test for the difference between the means of two samples (which may have different
+// variances), given their summaries. Returns the two-sided p-value (NaN if either sample has less
+// than 2 values)
+func WelchTest(a Summary, b Summary) float64 {
+	if a.N < 2 || b.N < 2 {
+		return math.NaN()
+	}
+	va := a.Stddev * a.Stddev / float64(a.N)
+	vb := b.Stddev * b.Stddev / float64(b.N)
+	if va+vb == 0 {
+		if a.Mean == b.Mean {
+			return 1
+		}
+		return 0
+	}
+	t, df := welch(a, b)
+	return 2 * (1 - TCdf(math.Abs(t), df))
+}
+
+// Returns the t statistic of the difference between the means of two samples, and its
+// Welch–Satterthwaite degrees of freedom
+func welch(a Summary, b Summary) (float64, float64) {
+	va := a.Stddev * a.Stddev / float64(a.N)
+	vb := b.Stddev * b.Stddev / float64(b.N)
+	t := (b.Mean - a.Mean) / math.Sqrt(va+vb)
+	df := (va + vb) * (va + vb) / (va*va/float64(a.N-1) + vb*vb/float64(b.N-1))
+	return t, df
+}
+
+// Cumulative distribution function of the Student's t-distribution with 'df' degrees of freedom
+func TCdf(t float64, df float64) float64 {
+	if math.IsNaN(t) || math.IsNaN(df) || df <= 0 {
+		return math.NaN()
+	}
+	if math.IsInf(t, 0) {
+		if t > 0 {
+			return 1
+		}
+		return 0
+	}
+	tail := 0.5 * betaInc(df/2, 0.5, df/(df+t*t))
+	if t > 0 {
+		return 1 - tail
+	}
+	return tail
+}
+
+// Quantile function (inverse CDF) of the Student's t-distribution, found by bisection
+func TQuantile(p float64, df float64) float64 {
+	if p <= 0 || p >= 1 || math.IsNaN(df) || df <= 0 {
+		return math.NaN()
+	}
+	if p < 0.5 {
+		return -TQuantile(1-p, df)
+	}
+	low, high := 0., 1.
+	for TCdf(high, df) < p {
+		high *= 2
+	}
+	for i := 0; i < 200 && high-low > 1e-12; i++ {
+		mid := (low + high) / 2
+		if TCdf(mid, df) < p {
+			low = mid
+		} else {
+			high = mid
+		}
+	}
+	return (low + high) / 2
+}
+
+// Regularized incomplete beta function I_x(a, b), evaluated with a continued fraction (Lentz's
+// method), as in Numerical Recipes
+func betaInc(a float64, b float64, x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	lgab, _ := math.Lgamma(a + b)
+	lga, _ := math.Lgamma(a)
+	lgb, _ := math.Lgamma(b)
+	front := math.Exp(lgab - lga - lgb + a*math.Log(x) + b*math.Log(1-x))
+	// the continued fraction converges faster on this side
+	if x < (a+1)/(a+b+2) {
+		return front * betaCf(a, b, x) / a
+	}
+	return 1 - front*betaCf(b, a, 1-x)/b
+}
+
+func betaCf(a float64, b float64, x float64) float64 {
+	const tiny = 1e-300
+	c := 1.
+	d := 1 - (a+b)*x/(a+1)
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	h := d
+
+	for m := 1; m <= 300; m++ {
+		m_ := float64(m)
+		// even step
+		aa := m_ * (b - m_) * x / ((a + 2*m_ - 1) * (a + 2*m_))
+		d = 1 + aa*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + aa/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		h *= d * c
+		// odd step
+		aa = -(a + m_) * (a + b + m_) * x / ((a + 2*m_) * (a + 2*m_ + 1))
+		d = 1 + aa*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + aa/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		delta := d * c
+		h *= delta
+		if math.Abs(delta-1) < 1e-15 {
+			break
+		}
+	}
+
+	return h
+}
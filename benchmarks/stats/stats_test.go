@@ -0,0 +1,241 @@
+package stats
+
+import (
+	"math"
+	"testing"
+)
+
+func near(a float64, b float64, tolerance float64) bool {
+	return math.Abs(a-b) <= tolerance
+}
+
+func TestTQuantile(t *testing.T) {
+	// from the tables of the t-distribution
+	tests := []struct {
+		p        float64
+		df       float64
+		expected float64
+	}{
+		{0.975, 1, 12.706204736},
+		{0.975, 2, 4.302652730},
+		{0.975, 3, 3.182446305},
+		{0.975, 5, 2.570581836},
+		{0.975, 10, 2.228138852},
+		{0.975, 30, 2.042272456},
+		{0.95, 1, 6.313751515},
+		{0.95, 4, 2.131846786},
+		{0.995, 2, 9.924843201},
+		{0.5, 7, 0},
+		{0.025, 5, -2.570581836},
+		{0.975, 1e6, 1.959966},
+	}
+	for _, test := range tests {
+		if q := TQuantile(test.p, test.df); !near(q, test.expected, 1e-6) {
+			t.Errorf("TQuantile(%v, %v) = %v, expected %v", test.p, test.df, q, test.expected)
+		}
+	}
+	for _, invalid := range [][2]float64{{0, 5}, {1, 5}, {0.975, 0}, {0.975, -1}, {0.975, math.NaN()}} {
+		if q := TQuantile(invalid[0], invalid[1]); !math.IsNaN(q) {
+			t.Errorf("TQuantile(%v, %v) = %v, expected NaN", invalid[0], invalid[1], q)
+		}
+	}
+}
+
+func TestTCdf(t *testing.T) {
+	tests := []struct {
+		t        float64
+		df       float64
+		expected float64
+	}{
+		{0, 5, 0.5},
+		{1, 1, 0.75}, // Cauchy: 1/2 + atan(t)/pi
+		{-1, 1, 0.25},
+		{1, 2, 0.5 + 1/(2*math.Sqrt(3))}, // 1/2 + t/(2 sqrt(2 + t^2))
+		{2.228138852, 10, 0.975},
+		{math.Inf(1), 3, 1},
+		{math.Inf(-1), 3, 0},
+	}
+	for _, test := range tests {
+		if p := TCdf(test.t, test.df); !near(p, test.expected, 1e-9) {
+			t.Errorf("TCdf(%v, %v) = %v, expected %v", test.t, test.df, p, test.expected)
+		}
+	}
+	if p := TCdf(math.NaN(), 3); !math.IsNaN(p) {
+		t.Errorf("TCdf(NaN, 3) = %v, expected NaN", p)
+	}
+}
+
+func TestBetaInc(t *testing.T) {
+	tests := []struct {
+		a, b, x  float64
+		expected float64
+	}{
+		{1, 1, 0.3, 0.3},
+		{1, 3, 0.2, 1 - 0.8*0.8*0.8},
+		{2, 3, 0.5, 11. / 16},
+		{0.5, 0.5, 0.5, 0.5},
+		{3, 2, 0, 0},
+		{3, 2, 1, 1},
+	}
+	for _, test := range tests {
+		if v := betaInc(test.a, test.b, test.x); !near(v, test.expected, 1e-12) {
+			t.Errorf("betaInc(%v, %v, %v) = %v, expected %v", test.a, test.b, test.x, v, test.expected)
+		}
+	}
+}
+
+func TestWelch(t *testing.T) {
+	a := Summary{N: 5, Mean: 10, Stddev: 1}
+	b := Summary{N: 7, Mean: 12, Stddev: 2}
+	tValue, df := welch(a, b)
+	if !near(tValue, 2.2771001702, 1e-9) || !near(df, 9.2375923970, 1e-9) {
+		t.Errorf("welch = (%v, %v), expected (2.2771001702, 9.2375923970)", tValue, df)
+	}
+	// the p-value is symmetric, and matches a numerical integration of the t density
+	if p := WelchTest(a, b); !near(p, 0.0480752626, 1e-8) {
+		t.Errorf("WelchTest = %v, expected 0.0480752626", p)
+	}
+	if p := WelchTest(b, a); !near(p, 0.0480752626, 1e-8) {
+		t.Errorf("WelchTest (swapped) = %v, expected 0.0480752626", p)
+	}
+
+	// equal variances and sizes: df = 2n - 2
+	if _, df := welch(Summary{N: 4, Mean: 1, Stddev: 3}, Summary{N: 4, Mean: 2, Stddev: 3}); !near(df, 6, 1e-12) {
+		t.Errorf("welch df = %v, expected 6", df)
+	}
+}
+
+func TestWelchEdgeCases(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     Summary
+		expected float64
+	}{
+		{"single value", Summary{N: 1, Mean: 1, Stddev: math.NaN()}, Summary{N: 5, Mean: 2, Stddev: 1}, math.NaN()},
+		{"zero variance, same mean", Summary{N: 3, Mean: 2}, Summary{N: 3, Mean: 2}, 1},
+		{"zero variance, different mean", Summary{N: 3, Mean: 2}, Summary{N: 3, Mean: 3}, 0},
+		{"NaN", Summarize([]float64{1, math.NaN(), 3}), Summarize([]float64{1, 2, 3}), math.NaN()},
+	}
+	for _, test := range tests {
+		p := WelchTest(test.a, test.b)
+		if math.IsNaN(test.expected) != math.IsNaN(p) || (!math.IsNaN(p) && p != test.expected) {
+			t.Errorf("%s: WelchTest = %v, expected %v", test.name, p, test.expected)
+		}
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	s := Summarize([]float64{2, 4, 4, 4, 5, 5, 7, 9})
+	if s.N != 8 || s.Mean != 5 || !near(s.Stddev, math.Sqrt(32./7), 1e-12) || !near(s.Cv, math.Sqrt(32./7)/5, 1e-12) {
+		t.Errorf("unexpected summary %+v", s)
+	}
+	halfWidth := 2.364624252 * math.Sqrt(32./7) / math.Sqrt(8) // t(0.975, 7)
+	if !near(s.CiLow, 5-halfWidth, 1e-6) || !near(s.CiHigh, 5+halfWidth, 1e-6) {
+		t.Errorf("unexpected confidence interval [%v, %v]", s.CiLow, s.CiHigh)
+	}
+
+	// a single value has no variance nor confidence interval
+	s = Summarize([]float64{3})
+	if s.Mean != 3 || !math.IsNaN(s.Stddev) || !math.IsNaN(s.CiLow) || !math.IsNaN(s.CiHigh) {
+		t.Errorf("unexpected summary of a single value %+v", s)
+	}
+	// equal values have a zero-width interval
+	s = Summarize([]float64{3, 3, 3})
+	if s.Stddev != 0 || s.Cv != 0 || s.CiLow != 3 || s.CiHigh != 3 || s.RelativeCiWidth() != 0 {
+		t.Errorf("unexpected summary of equal values %+v", s)
+	}
+	if s := Summarize([]float64{1, math.NaN()}); !math.IsNaN(s.Mean) || !math.IsNaN(s.CiHigh) {
+		t.Errorf("unexpected summary with NaN %+v", s)
+	}
+	if w := Summarize([]float64{0, 0}).RelativeCiWidth(); !math.IsNaN(w) {
+		t.Errorf("expected a NaN relative width with a zero mean, got %v", w)
+	}
+}
+
+func TestMedianAndOutliers(t *testing.T) {
+	if m := Median([]float64{5, 1, 3}); m != 3 {
+		t.Errorf("Median = %v, expected 3", m)
+	}
+	if m := Median([]float64{4, 1, 3, 2}); m != 2.5 {
+		t.Errorf("Median = %v, expected 2.5", m)
+	}
+	if m := Median(nil); !math.IsNaN(m) {
+		t.Errorf("Median of nothing = %v, expected NaN", m)
+	}
+	flags := Outliers([]float64{100, 104, 96, 150, 101}, 0.1)
+	expected := []bool{false, false, false, true, false}
+	for i := range flags {
+		if flags[i] != expected[i] {
+			t.Errorf("Outliers = %v, expected %v", flags, expected)
+			break
+		}
+	}
+}
+
+// a warmup ramp of 20 values followed by a noisy plateau
+func warmupSeries() []float64 {
+	values := []float64{}
+	for i := 0; i < 20; i++ {
+		values = append(values, float64(i*2))
+	}
+	for i := 0; i < 80; i++ {
+		values = append(values, 100+float64(i%3-1))
+	}
+	return values
+}
+
+func TestMser5(t *testing.T) {
+	if d, steady := Mser5(warmupSeries()); !steady || d != 20 {
+		t.Errorf("Mser5 = (%v, %v), expected (20, true)", d, steady)
+	}
+	// still growing at the end
+	ramp := []float64{}
+	for i := 0; i < 100; i++ {
+		ramp = append(ramp, float64(i))
+	}
+	if _, steady := Mser5(ramp); steady {
+		t.Errorf("expected no steady state in a growing series")
+	}
+
+	nan := math.NaN()
+	tests := map[string][]float64{
+		"too short": {1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+		"all NaN":   {nan, nan, nan, nan, nan, nan, nan, nan, nan, nan, nan, nan, nan, nan, nan, nan, nan, nan, nan, nan},
+		"some NaN":  append(warmupSeries(), nan),
+		"some Inf":  append(warmupSeries(), math.Inf(1)),
+		"no values": nil,
+	}
+	for name, values := range tests {
+		if d, steady := Mser5(values); steady {
+			t.Errorf("%s: Mser5 = (%v, %v), expected not steady", name, d, steady)
+		}
+	}
+}
+
+func TestStableWindow(t *testing.T) {
+	if i, steady := StableWindow(warmupSeries(), 10, 0.05); !steady || i != 20 {
+		t.Errorf("StableWindow = (%v, %v), expected (20, true)", i, steady)
+	}
+	// a plateau during the warmup, well below the final level
+	values := append([]float64{10, 10, 10, 10, 10, 10, 10, 10, 10, 10}, warmupSeries()...)
+	if i, steady := StableWindow(values, 10, 0.05); !steady || i != 30 {
+		t.Errorf("StableWindow with an early plateau = (%v, %v), expected (30, true)", i, steady)
+	}
+	// equal values, including zeros
+	if i, steady := StableWindow([]float64{0, 0, 0, 0}, 3, 0.05); !steady || i != 0 {
+		t.Errorf("StableWindow of zeros = (%v, %v), expected (0, true)", i, steady)
+	}
+
+	nan := math.NaN()
+	tests := map[string][]float64{
+		"too short": {1, 1},
+		"unstable":  {1, 10, 1, 10, 1, 10, 1, 10},
+		"all NaN":   {nan, nan, nan, nan, nan},
+		"some NaN":  append(warmupSeries(), nan),
+	}
+	for name, values := range tests {
+		if i, steady := StableWindow(values, 3, 0.05); steady {
+			t.Errorf("%s: StableWindow = (%v, %v), expected not steady", name, i, steady)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"benchmarks/stats"
+	"benchmarks/worker"
+	"math"
+)
+
+// Detection of the end of the warmup of each run, from the throughput and response time of all
+// workers, instead of a fixed 'warmup' (used when no steady state is found)
+type SteadyStateArgs struct {
+	Method  string  // 'mser' (MSER-5) or 'cv' (sliding window)
+	Window  float64 // length (seconds) of each value of the series (default: 1)
+	Windows int     // (cv) number of consecutive values of the sliding window (default: 5)
+	MaxCv   float64 `yaml:"maxCv"` // (cv) maximum coefficient of variation of a stable sliding window (default: 0.05)
+}
+
+func (s *SteadyStateArgs) fillDefaults() {
+	if s.Method == "" {
+		s.Method = "mser"
+	}
+	if s.Window == 0 {
+		s.Window = 1
+	}
+	if s.Windows == 0 {
+		s.Windows = 5
+	}
+	if s.MaxCv == 0 {
+		s.MaxCv = 0.05
+	}
+}
+
+// Returns the total throughput and the mean response time of each window of a run, up to the
+// last window completed by every worker. windows without completed operations keep the response
+// time of the previous one
+func steadySeries(results []*worker.BenchmarkResults, window float64) ([]float64, []float64) {
+	duration := math.Inf(1)
+	for _, r := range results {
+		duration = min(duration, r.RealDuration)
+	}
+	n := int(duration / window)
+
+	tps := make([]float64, n)
+	rts := make([]float64, n)
+	for i := 0; i < n; i++ {
+		total := worker.NewMetric()
+		for _, r := range results {
+			if i < len(r.Windows) {
+				for _, m := range r.Windows[i] {
+					total.Merge(m)
+				}
+			}
+		}
+		tps[i] = float64(total.CompleteCount) / window
+		rts[i] = total.TotalRt / float64(total.CompleteCount)
+	}
+
+	first := -1
+	for i := range rts {
+		if !math.IsNaN(rts[i]) {
+			if first < 0 {
+				first = i
+			}
+		} else if first >= 0 {
+			rts[i] = rts[i-1]
+		}
+	}
+	for i := 0; i < first; i++ {
+		rts[i] = rts[first]
+	}
+	return tps, rts
+}
+
+// Returns the number of windows of warmup of a run, and whether a steady state was detected
+// (otherwise, the fixed warmup is used)
+func detectWarmup(results []*worker.BenchmarkResults, args *BenchmarkArgs) (int, bool) {
+	s := args.SteadyState
+	tps, rts := steadySeries(results, s.Window)
+
+	detect := func(values []float64) (int, bool) {
+		if s.Method == "cv" {
+			return stats.StableWindow(values, s.Windows, s.MaxCv)
+		}
+		return stats.Mser5(values)
+	}
+	tpsWarmup, tpsSteady := detect(tps)
+	rtWarmup, rtSteady := detect(rts)
+	if !tpsSteady || !rtSteady {
+		return int(math.Round(float64(args.Warmup) / s.Window)), false
+	}
+	return max(tpsWarmup, rtWarmup), true
+}
+
+// Replaces the measured metrics of each worker by those after the first 'skip' windows
+func trimWarmup(results []*worker.BenchmarkResults, skip int, window float64) {
+	for _, r := range results {
+		operations := map[string]*worker.Metric{}
+		for operation := range r.Operations {
+			operations[operation] = worker.NewMetric()
+		}
+		for i := skip; i < len(r.Windows); i++ {
+			for operation, m := range r.Windows[i] {
+				if operations[operation] == nil {
+					operations[operation] = worker.NewMetric()
+				}
+				operations[operation].Merge(m)
+			}
+		}
+		r.Operations = operations
+		r.RealDuration = max(0, r.RealDuration-float64(skip)*window)
+	}
+}
+
+// Sets the warmup excluded from the results of a run, and whether it was detected
+func markWarmup(results map[string]ProcessedResult, warmup float64, unsteady bool) {
+	for k, r := range results {
+		r.warmup = warmup
+		r.unsteady = unsteady
+		results[k] = r
+	}
+}
@@ -0,0 +1,237 @@
+package store
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Header of the CSV files. Each row contains the results of one operation of a record; the
+// configs, metrics, swept values, statistics, and environment are stored as JSON objects so the header does
+// not depend on the benchmark.
+var csvHeader = []string{
+	"kind", "timestamp", "benchmark", "engine", "time", "runs", "run", "noReload", "workers",
+	"isolation", "sites", "rate", "start", "operation", "rt", "tps", "ct", "ar", "rtP50", "rtP90",
+	"rtP95", "rtP99", "rtP999", "rtMax", "late", "dropped", "timeouts", "retries", "rtRetry",
+	"serialization", "deadlock", "connection", "semantic", "other", "configs", "metrics", "config",
+	"sweep", "outlier", "stats", "partial", "seed", "phase", "environment", "warmup", "unsteady",
+	"site", "siteWorkers",
+}
+
+// Writes one row per operation, with a fixed header
+type csvSink struct {
+	file   *os.File
+	writer *csv.Writer
+}
+
+func newCsvSink(path string) (*csvSink, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	writer := csv.NewWriter(file)
+	if err := writer.Write(csvHeader); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &csvSink{file: file, writer: writer}, nil
+}
+
+func formatFloat(f Float) string {
+	if math.IsNaN(float64(f)) {
+		return ""
+	}
+	return strconv.FormatFloat(float64(f), 'g', -1, 64)
+}
+
+func (s *csvSink) Write(record *Record) error {
+	r := record.Run
+	environment := ""
+	if record.Environment != nil {
+		data, err := json.Marshal(record.Environment)
+		if err != nil {
+			return err
+		}
+		environment = string(data)
+	}
+	for _, o := range record.Operations {
+		row := []string{
+			record.Kind, record.Timestamp.Format(time.RFC3339Nano), r.Benchmark, r.Engine,
+			strconv.Itoa(r.Time), strconv.Itoa(r.Runs), strconv.Itoa(r.Run), strconv.FormatBool(r.NoReload),
+			strconv.Itoa(r.Workers), r.Isolation, strconv.Itoa(r.Sites),
+			strconv.FormatFloat(r.Rate, 'g', -1, 64), strconv.FormatFloat(r.Start, 'g', -1, 64),
+			o.Name, formatFloat(o.Rt), formatFloat(o.Tps), formatFloat(o.Ct), formatFloat(o.Ar),
+			formatFloat(o.RtP50), formatFloat(o.RtP90), formatFloat(o.RtP95), formatFloat(o.RtP99),
+			formatFloat(o.RtP999), formatFloat(o.RtMax), formatFloat(o.Late), formatFloat(o.Dropped),
+			formatFloat(o.Timeouts), formatFloat(o.Retries), formatFloat(o.RtRetry), formatFloat(o.Serialization),
+			formatFloat(o.Deadlock), formatFloat(o.Connection), formatFloat(o.Semantic), formatFloat(o.Other),
+			encodeMap(record.Configs), encodeMap(record.Metrics), encodeMap(record.Config),
+			encodeMap(record.Sweep), strconv.FormatBool(r.Outlier), encodeMap(o.Stats), strconv.FormatBool(r.Partial),
+			strconv.FormatInt(r.Seed, 10), r.Phase, environment, strconv.FormatFloat(r.Warmup, 'g', -1, 64),
+			strconv.FormatBool(r.Unsteady), strconv.Itoa(r.Site), strconv.Itoa(r.SiteWorkers),
+		}
+		if err := s.writer.Write(row); err != nil {
+			return err
+		}
+	}
+	s.writer.Flush()
+	return s.writer.Error()
+}
+
+func (s *csvSink) Close() error {
+	s.writer.Flush()
+	if err := s.writer.Error(); err != nil {
+		return err
+	}
+	return s.file.Close()
+}
+
+// Row of a CSV file being read; parsing errors are kept until the row is complete
+type csvRow struct {
+	values []string
+	index  map[string]int // column -> position
+	err    error
+}
+
+// Returns the value of a column ("" if the column is missing, e.g., in files of older versions)
+func (r *csvRow) str(column string) string {
+	if i, ok := r.index[column]; ok && i < len(r.values) {
+		return r.values[i]
+	}
+	return ""
+}
+
+func (r *csvRow) int(column string) int {
+	v, err := strconv.Atoi(r.str(column))
+	if err != nil && r.str(column) != "" {
+		r.err = err
+	}
+	return v
+}
+
+func (r *csvRow) float(column string) Float {
+	if r.str(column) == "" {
+		return Float(math.NaN())
+	}
+	v, err := strconv.ParseFloat(r.str(column), 64)
+	if err != nil {
+		r.err = err
+	}
+	return Float(v)
+}
+
+func (r *csvRow) bool(column string) bool {
+	return r.str(column) == "true"
+}
+
+func (r *csvRow) json(column string, v any) {
+	if r.str(column) == "" {
+		return
+	}
+	if err := json.Unmarshal([]byte(r.str(column)), v); err != nil {
+		r.err = err
+	}
+}
+
+// Reads a CSV file, grouping the consecutive rows (operations) of each record
+func readCsv(path string) ([]*Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(rows) == 0 {
+		return []*Record{}, nil
+	}
+	index := map[string]int{}
+	for i, column := range rows[0] {
+		index[column] = i
+	}
+
+	records := []*Record{}
+	lastKey := ""
+	for i, values := range rows[1:] {
+		r := &csvRow{values: values, index: index}
+		key := strings.Join([]string{r.str("kind"), r.str("timestamp"), r.str("run"), r.str("workers"), r.str("start"), r.str("site")}, ",")
+		if len(records) == 0 || key != lastKey {
+			timestamp, err := time.Parse(time.RFC3339Nano, r.str("timestamp"))
+			if err != nil {
+				return nil, fmt.Errorf("%s (row %d): %w", path, i+2, err)
+			}
+			record := &Record{
+				Kind:      r.str("kind"),
+				Timestamp: timestamp,
+				Run: Run{
+					Benchmark: r.str("benchmark"),
+					Engine:    r.str("engine"),
+					Time:      r.int("time"),
+					Runs:      r.int("runs"),
+					Run:       r.int("run"),
+					NoReload:  r.bool("noReload"),
+					Workers:   r.int("workers"),
+					Isolation: r.str("isolation"),
+					Sites:     r.int("sites"),
+					Rate:      float64(r.float("rate")),
+					Start:     float64(r.float("start")),
+					Outlier:   r.bool("outlier"),
+					Partial:   r.bool("partial"),
+					Seed:      int64(r.int("seed")),
+					Phase:     r.str("phase"),
+					Warmup:    float64(r.float("warmup")),
+					Unsteady:  r.bool("unsteady"),
+
+					Site:        r.int("site"),
+					SiteWorkers: r.int("siteWorkers"),
+				},
+			}
+			r.json("configs", &record.Configs)
+			r.json("metrics", &record.Metrics)
+			r.json("config", &record.Config)
+			r.json("sweep", &record.Sweep)
+			r.json("environment", &record.Environment)
+			records = append(records, record)
+			lastKey = key
+		}
+
+		o := Operation{
+			Name:          r.str("operation"),
+			Rt:            r.float("rt"),
+			Tps:           r.float("tps"),
+			Ct:            r.float("ct"),
+			Ar:            r.float("ar"),
+			RtP50:         r.float("rtP50"),
+			RtP90:         r.float("rtP90"),
+			RtP95:         r.float("rtP95"),
+			RtP99:         r.float("rtP99"),
+			RtP999:        r.float("rtP999"),
+			RtMax:         r.float("rtMax"),
+			Late:          r.float("late"),
+			Dropped:       r.float("dropped"),
+			Timeouts:      r.float("timeouts"),
+			Retries:       r.float("retries"),
+			RtRetry:       r.float("rtRetry"),
+			Serialization: r.float("serialization"),
+			Deadlock:      r.float("deadlock"),
+			Connection:    r.float("connection"),
+			Semantic:      r.float("semantic"),
+			Other:         r.float("other"),
+		}
+		r.json("stats", &o.Stats)
+		if r.err != nil {
+			return nil, fmt.Errorf("%s (row %d): %w", path, i+2, r.err)
+		}
+		records[len(records)-1].Operations = append(records[len(records)-1].Operations, o)
+	}
+
+	return records, nil
+}
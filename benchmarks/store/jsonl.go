@@ -0,0 +1,57 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Writes each record as a JSON object in its own line
+type jsonlSink struct {
+	file    *os.File
+	buffer  *bufio.Writer
+	encoder *json.Encoder
+}
+
+func newJsonlSink(path string) (*jsonlSink, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	buffer := bufio.NewWriter(file)
+	return &jsonlSink{file: file, buffer: buffer, encoder: json.NewEncoder(buffer)}, nil
+}
+
+func (s *jsonlSink) Write(record *Record) error {
+	if err := s.encoder.Encode(record); err != nil {
+		return err
+	}
+	return s.buffer.Flush()
+}
+
+func (s *jsonlSink) Close() error {
+	if err := s.buffer.Flush(); err != nil {
+		return err
+	}
+	return s.file.Close()
+}
+
+func readJsonl(path string) ([]*Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	records := []*Record{}
+	decoder := json.NewDecoder(file)
+	for decoder.More() {
+		record := &Record{}
+		if err := decoder.Decode(record); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
@@ -0,0 +1,511 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Stores the records in a SQLite database. Unlike the other sinks, existing databases are not
+// truncated, so the results of multiple executions can be accumulated and queried together.
+type sqliteSink struct {
+	db *sql.DB
+}
+
+var sqliteSchema = []string{
+	`create table if not exists records (
+		id integer primary key autoincrement,
+		kind text,
+		timestamp text,
+		benchmark text,
+		engine text,
+		time integer,
+		runs integer,
+		run integer,
+		noReload integer,
+		workers integer,
+		isolation text,
+		sites integer,
+		rate real,
+		start real,
+		outlier integer,
+		partial integer,
+		seed integer,
+		phase text,
+		config text,
+		warmup real,
+		unsteady integer,
+		site integer,
+		siteWorkers integer
+	)`,
+	`create table if not exists configs (
+		record_id integer references records(id),
+		key text,
+		value text
+	)`,
+	`create table if not exists stats (
+		record_id integer references records(id),
+		operation text,
+		metric text,
+		runs integer,
+		mean real,
+		stddev real,
+		cv real,
+		ciLow real,
+		ciHigh real
+	)`,
+	`create table if not exists sweep (
+		record_id integer references records(id),
+		key text,
+		value text
+	)`,
+	`create table if not exists metrics (
+		record_id integer references records(id),
+		key text,
+		value text
+	)`,
+	// host is 'client' or 'site', and idx the index of the client or site
+	`create table if not exists environment (
+		record_id integer references records(id),
+		host text,
+		idx integer,
+		key text,
+		value text
+	)`,
+	`create table if not exists operations (
+		record_id integer references records(id),
+		name text,
+		rt real,
+		tps real,
+		ct real,
+		ar real,
+		rtP50 real,
+		rtP90 real,
+		rtP95 real,
+		rtP99 real,
+		rtP999 real,
+		rtMax real,
+		late real,
+		dropped real,
+		timeouts real,
+		retries real,
+		rtRetry real,
+		serialization real,
+		deadlock real,
+		connection real,
+		semantic real,
+		other real
+	)`,
+}
+
+func newSqliteSink(path string) (*sqliteSink, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := createSchema(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &sqliteSink{db: db}, nil
+}
+
+// Columns added to each table after its first version, and their types
+var addedColumns = []struct {
+	table   string
+	columns [][2]string
+}{
+	{"records", [][2]string{
+		{"outlier", "integer"}, {"partial", "integer"}, {"seed", "integer"}, {"phase", "text"}, {"warmup", "real"},
+		{"unsteady", "integer"}, {"site", "integer"}, {"siteWorkers", "integer"},
+	}},
+	{"operations", [][2]string{
+		{"timeouts", "real"}, {"retries", "real"}, {"rtRetry", "real"}, {"serialization", "real"}, {"deadlock", "real"},
+		{"connection", "real"}, {"semantic", "real"}, {"other", "real"},
+	}},
+}
+
+// Columns of the operations table after record_id and name, which are inserted and read by name, as
+// their order in older databases differs
+var operationColumns = []string{"rt", "tps", "ct", "ar", "rtP50", "rtP90", "rtP95", "rtP99", "rtP999", "rtMax", "late",
+	"dropped", "timeouts", "retries", "rtRetry", "serialization", "deadlock", "connection", "semantic", "other"}
+
+// Creates the tables, if they do not exist yet. databases created by older versions lack some
+// tables and columns, which are added
+func createSchema(db *sql.DB) error {
+	for _, stmt := range sqliteSchema {
+		if _, err := db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	for _, added := range addedColumns {
+		for _, column := range added.columns {
+			if err := addColumn(db, added.table, column[0], column[1]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the columns of a table (none if it does not exist)
+func tableColumns(db *sql.DB, table string) (map[string]bool, error) {
+	columns := map[string]bool{}
+	err := queryRows(db, "select name from pragma_table_info('"+table+"')", func(rows *sql.Rows) error {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		columns[name] = true
+		return nil
+	})
+	return columns, err
+}
+
+// Adds a column to a table, if it does not exist yet
+func addColumn(db *sql.DB, table string, column string, columnType string) error {
+	columns, err := tableColumns(db, table)
+	if err != nil || columns[column] {
+		return err
+	}
+	_, err = db.Exec("alter table " + table + " add column " + column + " " + columnType)
+	return err
+}
+
+// Returns the expression that reads a column, or 'missing' if the table lacks it (e.g., older databases)
+func readColumn(columns map[string]bool, column string, missing string) string {
+	if !columns[column] {
+		return missing
+	}
+	return column
+}
+
+// Converts a float to a value that can be stored (NaN -> null)
+func nullable(f Float) any {
+	if math.IsNaN(float64(f)) {
+		return nil
+	}
+	return float64(f)
+}
+
+// Converts a stored value back to a float (null -> NaN)
+func fromNullable(v sql.NullFloat64) Float {
+	if !v.Valid {
+		return Float(math.NaN())
+	}
+	return Float(v.Float64)
+}
+
+func (s *sqliteSink) Write(record *Record) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	r := record.Run
+	result, err := tx.Exec(`
+		insert into records (kind, timestamp, benchmark, engine, time, runs, run, noReload, workers,
+			isolation, sites, rate, start, outlier, partial, seed, phase, config, warmup, unsteady, site, siteWorkers)
+		values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, record.Kind, record.Timestamp, r.Benchmark, r.Engine, r.Time, r.Runs, r.Run, r.NoReload,
+		r.Workers, r.Isolation, r.Sites, r.Rate, r.Start, r.Outlier, r.Partial, r.Seed, r.Phase, encodeMap(record.Config),
+		r.Warmup, r.Unsteady, r.Site, r.SiteWorkers)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	for k, v := range record.Configs {
+		if _, err := tx.Exec("insert into configs values (?, ?, ?)", id, k, v); err != nil {
+			return err
+		}
+	}
+
+	for k, v := range record.Sweep {
+		if _, err := tx.Exec("insert into sweep values (?, ?, ?)", id, k, v); err != nil {
+			return err
+		}
+	}
+
+	for k, v := range record.Metrics {
+		if _, err := tx.Exec("insert into metrics values (?, ?, ?)", id, k, v); err != nil {
+			return err
+		}
+	}
+
+	if e := record.Environment; e != nil {
+		for host, entries := range map[string][]map[string]string{"client": e.Clients, "site": e.Sites} {
+			for i, entry := range entries {
+				for k, v := range entry {
+					if _, err := tx.Exec("insert into environment values (?, ?, ?, ?, ?)", id, host, i, k, v); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+
+	for _, o := range record.Operations {
+		_, err := tx.Exec("insert into operations (record_id, name, "+strings.Join(operationColumns, ", ")+
+			") values (?, ?"+strings.Repeat(", ?", len(operationColumns))+")",
+			id, o.Name, nullable(o.Rt), nullable(o.Tps), nullable(o.Ct), nullable(o.Ar), nullable(o.RtP50),
+			nullable(o.RtP90), nullable(o.RtP95), nullable(o.RtP99), nullable(o.RtP999), nullable(o.RtMax),
+			nullable(o.Late), nullable(o.Dropped), nullable(o.Timeouts), nullable(o.Retries), nullable(o.RtRetry),
+			nullable(o.Serialization), nullable(o.Deadlock), nullable(o.Connection), nullable(o.Semantic),
+			nullable(o.Other))
+		if err != nil {
+			return err
+		}
+
+		for metric, st := range o.Stats {
+			_, err := tx.Exec(`
+				insert into stats (record_id, operation, metric, runs, mean, stddev, cv, ciLow, ciHigh)
+				values (?, ?, ?, ?, ?, ?, ?, ?, ?)
+			`, id, o.Name, metric, st.Runs,
+				nullable(st.Mean), nullable(st.Stddev), nullable(st.Cv), nullable(st.CiLow), nullable(st.CiHigh))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *sqliteSink) Close() error {
+	return s.db.Close()
+}
+
+// Parses a timestamp stored by the driver (its format depends on the driver's version)
+func parseTimestamp(value string) (time.Time, error) {
+	for _, format := range sqlite3.SQLiteTimestampFormats {
+		if t, err := time.Parse(format, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+// Reads all records of a database, along with their configs, metrics, environment, operations, and
+// statistics. the database is opened read-only, so databases of older versions are read as they are:
+// missing columns are read as null (or their zero value) and missing tables as empty
+func readSqlite(path string) ([]*Record, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	columns := map[string]map[string]bool{}
+	for _, table := range []string{"records", "configs", "metrics", "sweep", "environment", "operations", "stats"} {
+		if columns[table], err = tableColumns(db, table); err != nil {
+			return nil, err
+		}
+	}
+
+	records := []*Record{}
+	byId := map[int64]*Record{}
+	optional := func(column string, zero string) string {
+		return "coalesce(" + readColumn(columns["records"], column, "null") + ", " + zero + ")"
+	}
+	rows, err := db.Query(`
+		select id, kind, timestamp, benchmark, engine, time, runs, run, noReload, workers, isolation,
+			sites, rate, start, ` + optional("outlier", "0") + `, ` + optional("partial", "0") + `, ` +
+		optional("seed", "0") + `, ` + optional("phase", "''") + `, config, ` + optional("warmup", "0") + `, ` +
+		optional("unsteady", "0") + `, ` + optional("site", "0") + `, ` + optional("siteWorkers", "0") + `
+		from records
+		order by id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var timestamp, config string
+		record := &Record{}
+		r := &record.Run
+		err := rows.Scan(&id, &record.Kind, &timestamp, &r.Benchmark, &r.Engine, &r.Time, &r.Runs, &r.Run,
+			&r.NoReload, &r.Workers, &r.Isolation, &r.Sites, &r.Rate, &r.Start, &r.Outlier, &r.Partial, &r.Seed, &r.Phase, &config,
+			&r.Warmup, &r.Unsteady, &r.Site, &r.SiteWorkers)
+		if err != nil {
+			return nil, err
+		}
+		if record.Timestamp, err = parseTimestamp(timestamp); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(config), &record.Config); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+		byId[id] = record
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// key-value tables
+	for _, table := range []string{"configs", "metrics", "sweep"} {
+		if len(columns[table]) == 0 {
+			continue
+		}
+		err := queryRows(db, "select record_id, key, value from "+table, func(rows *sql.Rows) error {
+			var id int64
+			var k, v string
+			if err := rows.Scan(&id, &k, &v); err != nil {
+				return err
+			}
+			record := byId[id]
+			if record == nil {
+				return nil
+			}
+			var m *map[string]string
+			switch table {
+			case "configs":
+				m = &record.Configs
+			case "metrics":
+				m = &record.Metrics
+			case "sweep":
+				m = &record.Sweep
+			}
+			if *m == nil {
+				*m = map[string]string{}
+			}
+			(*m)[k] = v
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(columns["environment"]) > 0 {
+		err := queryRows(db, "select record_id, host, idx, key, value from environment", func(rows *sql.Rows) error {
+			var id int64
+			var idx int
+			var host, k, v string
+			if err := rows.Scan(&id, &host, &idx, &k, &v); err != nil {
+				return err
+			}
+			record := byId[id]
+			if record == nil {
+				return nil
+			}
+			if record.Environment == nil {
+				record.Environment = &Environment{Clients: []map[string]string{}, Sites: []map[string]string{}}
+			}
+			entries := &record.Environment.Clients
+			if host == "site" {
+				entries = &record.Environment.Sites
+			}
+			for len(*entries) <= idx {
+				*entries = append(*entries, map[string]string{})
+			}
+			(*entries)[idx][k] = v
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// operations, in the order they were inserted
+	selected := []string{}
+	for _, column := range operationColumns {
+		selected = append(selected, readColumn(columns["operations"], column, "null"))
+	}
+	query := "select record_id, name, " + strings.Join(selected, ", ") + " from operations order by rowid"
+	err = queryRows(db, query, func(rows *sql.Rows) error {
+		var id int64
+		var name string
+		values := make([]sql.NullFloat64, len(operationColumns))
+		dest := []any{&id, &name}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		f := func(i int) Float { return fromNullable(values[i]) }
+		record := byId[id]
+		if record == nil {
+			return nil
+		}
+		record.Operations = append(record.Operations, Operation{
+			Name: name, Rt: f(0), Tps: f(1), Ct: f(2), Ar: f(3), RtP50: f(4), RtP90: f(5), RtP95: f(6),
+			RtP99: f(7), RtP999: f(8), RtMax: f(9), Late: f(10), Dropped: f(11), Timeouts: f(12),
+			Retries: f(13), RtRetry: f(14), Serialization: f(15), Deadlock: f(16), Connection: f(17),
+			Semantic: f(18), Other: f(19),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	operations := map[int64]map[string]*Operation{}
+	for id, record := range byId {
+		operations[id] = map[string]*Operation{}
+		for i := range record.Operations {
+			operations[id][record.Operations[i].Name] = &record.Operations[i]
+		}
+	}
+
+	if len(columns["stats"]) > 0 {
+		query := "select record_id, operation, metric, runs, mean, stddev, cv, ciLow, ciHigh from stats"
+		err := queryRows(db, query, func(rows *sql.Rows) error {
+			var id, runs int64
+			var operation, metric string
+			var mean, stddev, cv, ciLow, ciHigh sql.NullFloat64
+			if err := rows.Scan(&id, &operation, &metric, &runs, &mean, &stddev, &cv, &ciLow, &ciHigh); err != nil {
+				return err
+			}
+			o := operations[id][operation]
+			if o == nil {
+				return nil
+			}
+			if o.Stats == nil {
+				o.Stats = map[string]Stat{}
+			}
+			o.Stats[metric] = Stat{
+				Runs:   int(runs),
+				Mean:   fromNullable(mean),
+				Stddev: fromNullable(stddev),
+				Cv:     fromNullable(cv),
+				CiLow:  fromNullable(ciLow),
+				CiHigh: fromNullable(ciHigh),
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return records, nil
+}
+
+// Runs a query, calling 'scan' for each row
+func queryRows(db *sql.DB, query string, scan func(rows *sql.Rows) error) error {
+	rows, err := db.Query(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,213 @@
+package store
+
+import (
+	"database/sql"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Schema of the first version of the results databases
+var firstSchema = []string{
+	`create table records (id integer primary key autoincrement, kind text, timestamp text, benchmark text,
+		engine text, time integer, runs integer, run integer, noReload integer, workers integer, isolation text,
+		sites integer, rate real, start real, config text)`,
+	`create table configs (record_id integer references records(id), key text, value text)`,
+	`create table metrics (record_id integer references records(id), key text, value text)`,
+	`create table operations (record_id integer references records(id), name text, rt real, tps real, ct real,
+		ar real, rtP50 real, rtP90 real, rtP95 real, rtP99 real, rtP999 real, rtMax real, late real, dropped real)`,
+}
+
+func TestSqliteRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.db")
+	record := &Record{
+		Kind:      KindSummary,
+		Timestamp: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Run:       Run{Benchmark: "micro", Engine: "crdv", Workers: 4, Run: -1},
+		Config:    map[string]any{"time": 60.0},
+		Configs:   map[string]string{"mode": "sync"},
+		Operations: []Operation{{Name: "total", Rt: 0.002, Tps: 1000, Ar: Float(math.NaN()), Late: 3, Timeouts: 2,
+			Retries: 5, RtRetry: 0.003, Serialization: 2, Other: 1}},
+	}
+	// the records are accumulated across executions
+	for i := 0; i < 2; i++ {
+		w, err := NewWriter([]string{path})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Write(record); err != nil {
+			t.Fatal(err)
+		}
+		w.Close()
+	}
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var records int
+	var engine, config, mode string
+	err = db.QueryRow(`select count(*), max(engine), max(config), max(value) from records join configs on id = record_id`).
+		Scan(&records, &engine, &config, &mode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if records != 2 || engine != "crdv" || config != `{"time":60}` || mode != "sync" {
+		t.Errorf("unexpected records: %d, %s, %s, %s", records, engine, config, mode)
+	}
+	var tps, late, timeouts, retries, serialization float64
+	var ar, deadlock sql.NullFloat64
+	err = db.QueryRow("select tps, ar, late, timeouts, retries, serialization, deadlock from operations where name = 'total'").
+		Scan(&tps, &ar, &late, &timeouts, &retries, &serialization, &deadlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tps != 1000 || ar.Valid || late != 3 || timeouts != 2 || retries != 5 || serialization != 2 || deadlock.Float64 != 0 {
+		t.Errorf("unexpected operation: tps %v, ar %v, late %v, timeouts %v, retries %v, serialization %v, deadlock %v",
+			tps, ar, late, timeouts, retries, serialization, deadlock)
+	}
+}
+
+func TestSqliteMigratesOldDatabases(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "old.db")
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, stmt := range firstSchema {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = db.Exec(`insert into records (kind, timestamp, benchmark, engine, time, runs, run, noReload, workers,
+		isolation, sites, rate, start, config) values ('summary', '2023-01-01 00:00:00+00:00', 'micro', 'crdv', 60, 1,
+		-1, 0, 2, 'READ COMMITTED', 1, 0, 0, '{}')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`insert into operations values (1, 'total', 0.001, 500, 30000, 0.1, 0.001, 0.002, 0.003,
+		0.004, 0.005, 0.006, 0, 0)`); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := NewWriter([]string{path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(&Record{Kind: KindSummary, Operations: []Operation{{Name: "total", Tps: 800, Timeouts: 2, Retries: 3}}}); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	rows, err := db.Query("select tps, retries from operations order by rowid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	values := [][2]sql.NullFloat64{}
+	for rows.Next() {
+		var v [2]sql.NullFloat64
+		if err := rows.Scan(&v[0], &v[1]); err != nil {
+			t.Fatal(err)
+		}
+		values = append(values, v)
+	}
+	if len(values) != 2 {
+		t.Fatalf("expected 2 operations, got %d", len(values))
+	}
+	if old := values[0]; old[0].Float64 != 500 || old[1].Valid {
+		t.Errorf("unexpected operation of the old record %+v", old)
+	}
+	if current := values[1]; current[0].Float64 != 800 || current[1].Float64 != 3 {
+		t.Errorf("unexpected operation of the new record %+v", current)
+	}
+}
+
+func TestReadSqlite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.db")
+	record := &Record{
+		Kind:      KindSummary,
+		Timestamp: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Run:       Run{Benchmark: "micro", Engine: "crdv", Workers: 4, Run: 2, Outlier: true},
+		Config:    map[string]any{"time": 60.0},
+		Configs:   map[string]string{"mode": "sync"},
+		Sweep:     map[string]string{"workers": "4"},
+		Operations: []Operation{{Name: "total", Rt: 0.002, Tps: 1000, Ar: Float(math.NaN()), Retries: 5,
+			Stats: map[string]Stat{"tps": {Runs: 3, Mean: 1000, Stddev: 10, Cv: 0.01, CiLow: 975, CiHigh: 1025}}}},
+	}
+	w, err := NewWriter([]string{path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(record); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	records, err := readSqlite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || len(records[0].Operations) != 1 {
+		t.Fatalf("expected a record with an operation, got %+v", records)
+	}
+	r := records[0]
+	if !r.Timestamp.Equal(record.Timestamp) || r.Run != record.Run || r.Config["time"] != 60.0 ||
+		r.Configs["mode"] != "sync" || r.Sweep["workers"] != "4" {
+		t.Errorf("unexpected record %+v", r)
+	}
+	o := r.Operations[0]
+	if o.Tps != 1000 || !math.IsNaN(float64(o.Ar)) || o.Retries != 5 || o.Stats["tps"] != record.Operations[0].Stats["tps"] {
+		t.Errorf("unexpected operation %+v", o)
+	}
+}
+
+func TestReadSqliteOldDatabases(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "old.db")
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, stmt := range firstSchema {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = db.Exec(`insert into records (kind, timestamp, benchmark, engine, time, runs, run, noReload, workers,
+		isolation, sites, rate, start, config) values ('summary', '2023-01-01 00:00:00+00:00', 'micro', 'crdv', 60, 1,
+		-1, 0, 2, 'READ COMMITTED', 1, 0, 0, '{}')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`insert into operations values (1, 'total', 0.001, 500, 30000, 0.1, 0.001, 0.002, 0.003,
+		0.004, 0.005, 0.006, 0, 0)`); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := readSqlite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || len(records[0].Operations) != 1 || records[0].Run.Workers != 2 || records[0].Run.Phase != "" {
+		t.Fatalf("expected the old record, got %+v", records)
+	}
+	if o := records[0].Operations[0]; o.Tps != 500 || !math.IsNaN(float64(o.Retries)) || o.Stats != nil {
+		t.Errorf("unexpected operation %+v", o)
+	}
+	// the database is read as it is
+	columns, err := tableColumns(db, "operations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err := tableColumns(db, "stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if columns["retries"] || len(stats) > 0 {
+		t.Errorf("expected the database not to be migrated, got the columns %v and stats %v", columns, stats)
+	}
+}
@@ -0,0 +1,210 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	KindSummary  = "summary"  // results of a number of workers, averaged over all runs
+	KindInterval = "interval" // results of a time series interval of a single run
+	KindRun      = "run"      // results of a single run
+	KindSite     = "site"     // results of the workers of a site, in a single run or averaged over all runs
+)
+
+// Float that is encoded as null in JSON when it is not a number (e.g., the response time of an
+// operation without completions)
+type Float float64
+
+func (f Float) MarshalJSON() ([]byte, error) {
+	if math.IsNaN(float64(f)) || math.IsInf(float64(f), 0) {
+		return []byte("null"), nil
+	}
+	return []byte(strconv.FormatFloat(float64(f), 'g', -1, 64)), nil
+}
+
+func (f *Float) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*f = Float(math.NaN())
+		return nil
+	}
+	v, err := strconv.ParseFloat(string(data), 64)
+	*f = Float(v)
+	return err
+}
+
+// Run metadata
+type Run struct {
+	Benchmark string  `json:"benchmark"`
+	Engine    string  `json:"engine"`
+	Time      int     `json:"time"`
+	Runs      int     `json:"runs"`
+	Run       int     `json:"run"` // index of the run (-1 in summaries, which average all runs)
+	NoReload  bool    `json:"noReload"`
+	Workers   int     `json:"workers"`
+	Isolation string  `json:"isolation"`
+	Sites     int     `json:"sites"`
+	Rate      float64 `json:"rate"`
+	Start     float64 `json:"start"`    // start of the interval (seconds), in time series records
+	Outlier   bool    `json:"outlier"`  // whether the run deviates from the others, in run records
+	Partial   bool    `json:"partial"`  // whether the execution was interrupted (e.g., Ctrl-C)
+	Seed      int64   `json:"seed"`     // seed of the random choices (replays the same operations)
+	Phase     string  `json:"phase"`    // phase of the interval, in time series records of runs with phases
+	Warmup    float64 `json:"warmup"`   // (seconds) excluded at the start of the run (mean, in summaries)
+	Unsteady  bool    `json:"unsteady"` // whether the warmup is the fixed one, as no steady state was detected
+
+	// index of the connection, and its number of workers, in site records
+	Site        int `json:"site"`
+	SiteWorkers int `json:"siteWorkers"`
+}
+
+// Results of an operation ("total" for all operations combined)
+type Operation struct {
+	Name     string `json:"name"`
+	Rt       Float  `json:"rt"`
+	Tps      Float  `json:"tps"`
+	Ct       Float  `json:"ct"`
+	Ar       Float  `json:"ar"`
+	RtP50    Float  `json:"rtP50"`
+	RtP90    Float  `json:"rtP90"`
+	RtP95    Float  `json:"rtP95"`
+	RtP99    Float  `json:"rtP99"`
+	RtP999   Float  `json:"rtP999"`
+	RtMax    Float  `json:"rtMax"`
+	Late     Float  `json:"late"`
+	Dropped  Float  `json:"dropped"`
+	Timeouts Float  `json:"timeouts"`
+	Retries  Float  `json:"retries"`
+	RtRetry  Float  `json:"rtRetry"` // response time including retries
+	// aborted operations of each error class
+	Serialization Float `json:"serialization"`
+	Deadlock      Float `json:"deadlock"`
+	Connection    Float `json:"connection"`
+	Semantic      Float `json:"semantic"`
+	Other         Float `json:"other"`
+
+	Stats map[string]Stat `json:"stats,omitempty"` // column -> statistics across runs, in summaries
+}
+
+// Statistics of a column across runs
+type Stat struct {
+	Runs   int   `json:"runs"`
+	Mean   Float `json:"mean"`
+	Stddev Float `json:"stddev"`
+	Cv     Float `json:"cv"`
+	CiLow  Float `json:"ciLow"` // 95% confidence interval of the mean
+	CiHigh Float `json:"ciHigh"`
+}
+
+// Environment of a run, captured when it starts, to interpret the results later
+type Environment struct {
+	// hosts issuing the operations (this process, or each agent): hostname, cpus, Go version, etc.
+	Clients []map[string]string `json:"clients"`
+	// server of each site, in the order of the connections, as described by the engine (e.g.,
+	// version, settings that differ from the default, and extensions)
+	Sites []map[string]string `json:"sites"`
+}
+
+type Record struct {
+	Kind       string            `json:"kind"`
+	Timestamp  time.Time         `json:"timestamp"`
+	Run        Run               `json:"run"`
+	Config     map[string]any    `json:"config"`  // full contents of the config file
+	Configs    map[string]string `json:"configs"` // benchmark and engine configurations
+	Metrics    map[string]string `json:"metrics"` // benchmark and engine metrics
+	Operations []Operation       `json:"operations"`
+	Sweep      map[string]string `json:"sweep,omitempty"` // values of the swept keys
+
+	Environment *Environment `json:"environment,omitempty"` // in run and summary records
+}
+
+// Destination of the results
+type Sink interface {
+	Write(record *Record) error
+	Close() error
+}
+
+// Writes the results to multiple sinks
+type Writer struct {
+	sinks []Sink
+}
+
+// Opens a sink based on the extension of the path: .jsonl/.json (JSON Lines), .csv, or
+// .db/.sqlite/.sqlite3 (SQLite)
+func openSink(path string) (Sink, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl", ".json":
+		return newJsonlSink(path)
+	case ".csv":
+		return newCsvSink(path)
+	case ".db", ".sqlite", ".sqlite3":
+		return newSqliteSink(path)
+	default:
+		return nil, fmt.Errorf("unknown results format: '%s'", path)
+	}
+}
+
+// Reads all records of a file written by one of the sinks, based on the extension of the path
+func Read(path string) ([]*Record, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl", ".json":
+		return readJsonl(path)
+	case ".csv":
+		return readCsv(path)
+	case ".db", ".sqlite", ".sqlite3":
+		return readSqlite(path)
+	default:
+		return nil, fmt.Errorf("unknown results format: '%s'", path)
+	}
+}
+
+// Creates a writer for a list of paths (an empty list results in a writer that discards the
+// results)
+func NewWriter(paths []string) (*Writer, error) {
+	w := &Writer{}
+	for _, path := range paths {
+		sink, err := openSink(path)
+		if err != nil {
+			w.Close()
+			return nil, err
+		}
+		w.sinks = append(w.sinks, sink)
+	}
+	return w, nil
+}
+
+func (w *Writer) Write(record *Record) error {
+	for _, sink := range w.sinks {
+		if err := sink.Write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *Writer) Close() error {
+	var err error
+	for _, sink := range w.sinks {
+		if err_ := sink.Close(); err_ != nil {
+			err = err_
+		}
+	}
+	return err
+}
+
+// Encodes a map as JSON, to be stored in a single column
+func encodeMap[T any](m map[string]T) string {
+	if m == nil {
+		return "{}"
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
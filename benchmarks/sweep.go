@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Parameter sweep over config keys, executed in-process. Each combination of values (point)
+// results in a separate execution, with the config file updated accordingly.
+type Sweep struct {
+	Mode   string // 'cartesian' (all combinations) or 'zip' (i-th value of each key)
+	Params []SweepParam
+}
+
+type SweepParam struct {
+	// dotted path to the key (e.g., 'modes.readMode'). list elements are selected by index
+	// (e.g., 'operations.0.weight') or name (e.g., 'operations.mapAdd.weight')
+	Key    string
+	Values []any
+}
+
+type SweepValue struct {
+	Key   string
+	Value any
+}
+
+// Values of the swept keys in an execution (empty if there is no sweep)
+type SweepPoint []SweepValue
+
+// Expands the sweep into the list of points to execute; without parameters, there is a single
+// (empty) point
+func (s *Sweep) Points() ([]SweepPoint, error) {
+	for _, p := range s.Params {
+		if p.Key == "" || len(p.Values) == 0 {
+			return nil, fmt.Errorf("sweep parameter '%s' needs a key and at least one value", p.Key)
+		}
+	}
+
+	switch s.Mode {
+	case "", "cartesian":
+		points := []SweepPoint{{}}
+		for _, p := range s.Params {
+			expanded := []SweepPoint{}
+			for _, point := range points {
+				for _, v := range p.Values {
+					expanded = append(expanded, append(point[:len(point):len(point)], SweepValue{p.Key, v}))
+				}
+			}
+			points = expanded
+		}
+		return points, nil
+	case "zip":
+		if len(s.Params) == 0 {
+			return []SweepPoint{{}}, nil
+		}
+		first := s.Params[0]
+		for _, p := range s.Params {
+			if len(p.Values) != len(first.Values) {
+				return nil, fmt.Errorf("zipped sweep parameters must have the same number of values ('%s' has %d, '%s' has %d)",
+					first.Key, len(first.Values), p.Key, len(p.Values))
+			}
+		}
+		points := []SweepPoint{}
+		for i := range first.Values {
+			point := SweepPoint{}
+			for _, p := range s.Params {
+				point = append(point, SweepValue{p.Key, p.Values[i]})
+			}
+			points = append(points, point)
+		}
+		return points, nil
+	default:
+		return nil, fmt.Errorf("unknown sweep mode '%s' (expected 'cartesian' or 'zip')", s.Mode)
+	}
+}
+
+// Returns the swept values as a key -> value map
+func (p SweepPoint) Map() map[string]string {
+	m := map[string]string{}
+	for _, v := range p {
+		m[v.Key] = fmt.Sprint(v.Value)
+	}
+	return m
+}
+
+// Returns the swept keys and values as CSV columns (each preceded by a comma)
+func (p SweepPoint) Csv() (string, string) {
+	columns := ""
+	values := ""
+	for _, v := range p {
+		columns += "," + v.Key
+		values += fmt.Sprintf(",%v", v.Value)
+	}
+	return columns, values
+}
+
+func (p SweepPoint) String() string {
+	pairs := []string{}
+	for _, v := range p {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", v.Key, v.Value))
+	}
+	return strings.Join(pairs, " ")
+}
+
+// Returns the config file contents with the values of a point applied (and the sweep removed)
+func applySweep(data []byte, point SweepPoint) ([]byte, error) {
+	config := map[string]any{}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+	delete(config, "sweep")
+
+	for _, v := range point {
+		if err := setKey(config, strings.Split(v.Key, "."), v.Value); err != nil {
+			return nil, fmt.Errorf("sweep key '%s': %w", v.Key, err)
+		}
+	}
+
+	return yaml.Marshal(config)
+}
+
+// Sets the value at a path of a decoded YAML document, creating any missing maps
+func setKey(node any, path []string, value any) error {
+	key := path[0]
+
+	switch n := node.(type) {
+	case map[string]any:
+		if len(path) == 1 {
+			n[key] = value
+			return nil
+		}
+		if _, ok := n[key]; !ok {
+			n[key] = map[string]any{}
+		}
+		return setKey(n[key], path[1:], value)
+	case []any:
+		i, err := listIndex(n, key)
+		if err != nil {
+			return err
+		}
+		if len(path) == 1 {
+			n[i] = value
+			return nil
+		}
+		return setKey(n[i], path[1:], value)
+	default:
+		return fmt.Errorf("'%s' is not inside a map or list", key)
+	}
+}
+
+// Finds a list element by index or, for lists of maps, by the 'name' field
+func listIndex(list []any, key string) (int, error) {
+	if i, err := strconv.Atoi(key); err == nil {
+		if i < 0 || i >= len(list) {
+			return 0, fmt.Errorf("index %d out of range (%d elements)", i, len(list))
+		}
+		return i, nil
+	}
+
+	for i, e := range list {
+		if m, ok := e.(map[string]any); ok && m["name"] == key {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("no element named '%s'", key)
+}
@@ -0,0 +1,30 @@
+package testutil
+
+import "context"
+
+// Benchmark whose operations return right away
+type Benchmark struct {
+	Operations []string // names of the operations
+}
+
+func (b Benchmark) Validate(operations []string) error { return nil }
+func (b Benchmark) ConfigStructs() []any               { return nil }
+func (b Benchmark) Setup(connections []any)            {}
+func (b Benchmark) Populate(connections []any)         {}
+func (b Benchmark) GetConfigs() map[string]string      { return nil }
+func (b Benchmark) GetMetrics(any) map[string]string   { return nil }
+func (b Benchmark) Finalize(connections []any)         {}
+
+func (b Benchmark) Prepare(connection any) map[string]func(context.Context) error {
+	operations := map[string]func(context.Context) error{}
+	for _, name := range b.Operations {
+		operations[name] = func(context.Context) error { return nil }
+	}
+	return operations
+}
+
+// Error with a SQLSTATE, classified as a serialization failure
+type SerializationError struct{}
+
+func (SerializationError) Error() string    { return "could not serialize access" }
+func (SerializationError) SQLState() string { return "40001" }
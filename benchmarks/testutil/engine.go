@@ -0,0 +1,131 @@
+package testutil
+
+import (
+	engine "benchmarks/benchmark/engines/abstract"
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// Engine without a server, registered as "test", with counters and maps that record the updates
+// (and lists that do not) and run in transactions. Preparing a connection to the site "broken" panics
+type Engine struct {
+	lock     sync.Mutex
+	calls    []string // updates, in order (e.g., "inc c-1 2")
+	failNext bool     // whether the next transaction fails
+}
+
+var Instance = &Engine{} // engine of every worker
+var Open atomic.Int64    // connections open
+
+func init() {
+	engine.RegisterEngine(&engine.Registration{
+		Name: "test",
+		Connect: func(address string, isolation string, pool engine.Pool) any {
+			Open.Add(1)
+			return address
+		},
+		Close: func(connection any) { Open.Add(-1) },
+		New:   func(id int, configData []byte) engine.Engine { return Instance },
+		Types: map[string][]string{"counter": engine.Methods["counter"], "map": engine.Methods["map"], "list": engine.Methods["list"]},
+	})
+}
+
+func (e *Engine) Setup(connections []any)                             {}
+func (e *Engine) Cleanup(connections []any)                           {}
+func (e *Engine) Populate(connections []any, t []string, i, o, v int) {}
+func (e *Engine) GetRegister() engine.Register                        { return nil }
+func (e *Engine) GetCounter() engine.Counter                          { return e }
+func (e *Engine) GetSet() engine.Set                                  { return nil }
+func (e *Engine) GetMap() engine.Map                                  { return testMap{e} }
+func (e *Engine) GetList() engine.List                                { return testList{} }
+func (e *Engine) GetConfigs() map[string]string                       { return map[string]string{} }
+func (e *Engine) GetMetrics(connection any) map[string]string         { return nil }
+func (e *Engine) Finalize(connections []any)                          {}
+
+// Forgets the updates recorded, and the transaction set to fail
+func (e *Engine) Reset() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.calls = nil
+	e.failNext = false
+}
+
+// Returns the updates recorded since the last Reset
+func (e *Engine) Calls() []string {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return append([]string{}, e.calls...)
+}
+
+// Makes the next transaction fail with a serialization failure, after running its operations
+func (e *Engine) FailNextTx() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.failNext = true
+}
+
+func (e *Engine) record(format string, args ...any) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.calls = append(e.calls, fmt.Sprintf(format, args...))
+}
+
+func (e *Engine) Prepare(connection any) {
+	if connection == "broken" {
+		panic("cannot prepare the connection")
+	}
+}
+
+func (e *Engine) Get(ctx context.Context, id string) (int64, error) { return 0, nil }
+func (e *Engine) Inc(ctx context.Context, id string, delta int) error {
+	e.record("inc %s %d", id, delta)
+	return nil
+}
+func (e *Engine) Dec(ctx context.Context, id string, delta int) error  { return nil }
+func (e *Engine) GetAll(ctx context.Context) (map[string]int64, error) { return nil, nil }
+func (e *Engine) GetMultiple(ctx context.Context, ids []string) (map[string]int64, error) {
+	return nil, nil
+}
+
+func (e *Engine) InTx(ctx context.Context, f func(ctx context.Context) error) error {
+	if err := f(ctx); err != nil {
+		return err
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.failNext {
+		e.failNext = false
+		return SerializationError{}
+	}
+	return nil
+}
+
+type testMap struct {
+	e *Engine
+}
+
+func (m testMap) Get(ctx context.Context, id string) (map[string]string, error)    { return nil, nil }
+func (m testMap) Value(ctx context.Context, id string, key string) (string, error) { return "", nil }
+func (m testMap) Contains(ctx context.Context, id string, key string) (bool, error) {
+	return false, nil
+}
+func (m testMap) Add(ctx context.Context, id string, key string, value string) error {
+	m.e.record("add %s %s %s", id, key, value)
+	return nil
+}
+func (m testMap) Rmv(ctx context.Context, id string, key string) error { return nil }
+func (m testMap) Clear(ctx context.Context, id string) error           { return nil }
+
+type testList struct{}
+
+func (l testList) Get(ctx context.Context, id string) ([]string, error)            { return nil, nil }
+func (l testList) GetAt(ctx context.Context, id string, index int) (string, error) { return "", nil }
+func (l testList) Add(ctx context.Context, id string, index int, value string) error {
+	return nil
+}
+func (l testList) Append(ctx context.Context, id string, value string) error  { return nil }
+func (l testList) Prepend(ctx context.Context, id string, value string) error { return nil }
+func (l testList) Rmv(ctx context.Context, id string, index int) error        { return nil }
+func (l testList) Clear(ctx context.Context, id string) error                 { return nil }
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"benchmarks/worker"
+	"fmt"
+	"sort"
+)
+
+type IntervalResult struct {
+	start   float64                    // seconds since the workers were launched
+	phase   string                     // name of the phase (see PhaseArgs), if any
+	results map[string]ProcessedResult // operation -> result ("total" for all operations)
+}
+
+// Converts a metric, collected during 'duration' seconds, to a processed result
+func processMetric(name string, m *worker.Metric, duration float64) ProcessedResult {
+	errors := map[string]float64{}
+	for _, class := range abortClasses {
+		errors[class] = float64(m.Errors[class])
+	}
+	return withPercentiles(ProcessedResult{
+		name:    name,
+		rt:      m.TotalRt / float64(m.CompleteCount),
+		ct:      float64(m.CompleteCount),
+		tps:     float64(m.CompleteCount) / duration,
+		ar:      float64(m.AbortCount) / float64(m.AbortCount+m.CompleteCount),
+		to:      float64(m.TimeoutCount),
+		errors:  errors,
+		retries: float64(m.RetryCount),
+		rtRetry: m.TotalRtRetry / float64(m.CompleteCount),
+		late:    float64(m.LateCount),
+		drop:    float64(m.DropCount),
+		rts:     m.Rts,
+	})
+}
+
+// Combines the time series intervals of all workers of a run. origin is the epoch time (seconds)
+// at which the first interval starts.
+func aggregateIntervals(results []*worker.BenchmarkResults, args *BenchmarkArgs, origin float64) []IntervalResult {
+	end := 0.
+	nIntervals := 0
+	for _, r := range results {
+		end = max(end, r.End-origin)
+		nIntervals = max(nIntervals, len(r.Intervals))
+	}
+
+	intervals := []IntervalResult{}
+	for i := 0; i < nIntervals; i++ {
+		start, intervalEnd, phase := worker.IntervalBounds(i, args.Interval, phaseDurations(args))
+		// the last interval (of the run or of a phase) might be shorter
+		length := min(intervalEnd, end) - start
+		metrics := map[string]*worker.Metric{"total": worker.NewMetric()}
+
+		for _, r := range results {
+			if i >= len(r.Intervals) {
+				continue
+			}
+			for operation, m := range r.Intervals[i] {
+				if metrics[operation] == nil {
+					metrics[operation] = worker.NewMetric()
+				}
+				metrics[operation].Merge(m)
+				metrics["total"].Merge(m)
+			}
+		}
+
+		processed := map[string]ProcessedResult{}
+		for operation, m := range metrics {
+			processed[operation] = processMetric(operation, m, length)
+		}
+		result := IntervalResult{start: start, results: processed}
+		if phase >= 0 {
+			result.phase = args.Phases[phase].Name
+		}
+		intervals = append(intervals, result)
+	}
+
+	return intervals
+}
+
+// Prints the time series of a run ("CsvTs:" prefix), one line per interval and operation
+func printIntervals(intervals []IntervalResult, args *BenchmarkArgs, run int, nWorkers int, firstLine bool) {
+	sweepColumns, sweepValues := args.Point.Csv()
+
+	phaseColumn := ""
+	if len(args.Phases) > 0 {
+		phaseColumn = ",phase"
+	}
+	if firstLine {
+		fmt.Println("CsvTs:benchmark" + sweepColumns + ",run,workers,start" + phaseColumn + ",operation," + resultColumns)
+	}
+
+	for _, interval := range intervals {
+		operations := []string{}
+		for k := range interval.results {
+			operations = append(operations, k)
+		}
+		sort.Strings(operations)
+
+		phase := ""
+		if len(args.Phases) > 0 {
+			phase = "," + interval.phase
+		}
+		for _, operation := range operations {
+			fmt.Printf("CsvTs:%s%s,%d,%d,%g%s,%s,%s\n", args.Benchmark, sweepValues, run, nWorkers,
+				interval.start, phase, operation, formatResult(interval.results[operation]))
+		}
+	}
+}
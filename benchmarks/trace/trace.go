@@ -0,0 +1,212 @@
+package trace
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+// Compact binary trace of the operations issued by a worker. The file starts with a header (magic
+// and version), followed by one entry per operation: the time, the operation and outcome (as
+// indexes of a per-file dictionary, where an index equal to the dictionary size introduces a new
+// name), and the arguments, with varints for the numbers and length-prefixed strings.
+const (
+	magic     = "BTRC"
+	version   = 1
+	maxString = 16 << 20 // length of the strings, so a corrupt length is not allocated
+)
+
+// Arguments of an operation (the unused ones are empty)
+type Args struct {
+	Id    string // structure id
+	Key   string // element key (sets and maps)
+	Index int    // position (lists) or delta (counters)
+	Value string // value written
+}
+
+type Entry struct {
+	At        float64 // intended start (seconds) since the worker started
+	Operation string
+	Args
+	Outcome string // "committed" or the error class of the last attempt
+}
+
+// Returns the path of the trace of a worker, in a trace directory
+func WorkerPath(dir string, id int) string {
+	return filepath.Join(dir, fmt.Sprintf("worker-%d.trace", id))
+}
+
+// Returns the traces of a trace directory, one per worker
+func Files(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "worker-*.trace"))
+	if err == nil && len(files) == 0 {
+		err = fmt.Errorf("no traces in '%s'", dir)
+	}
+	return files, err
+}
+
+type Writer struct {
+	file  *os.File
+	w     *bufio.Writer
+	names map[string]uint64
+	buf   []byte
+}
+
+// Creates a trace file (and its directory), replacing any existing one
+func Create(path string) (*Writer, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	t := &Writer{file: file, w: bufio.NewWriter(file), names: map[string]uint64{}}
+	t.w.WriteString(magic)
+	t.w.WriteByte(version)
+	return t, nil
+}
+
+func (t *Writer) appendName(name string) {
+	i, ok := t.names[name]
+	if !ok {
+		i = uint64(len(t.names))
+		t.names[name] = i
+	}
+	t.buf = binary.AppendUvarint(t.buf, i)
+	if !ok {
+		t.appendString(name)
+	}
+}
+
+func (t *Writer) appendString(s string) {
+	t.buf = binary.AppendUvarint(t.buf, uint64(len(s)))
+	t.buf = append(t.buf, s...)
+}
+
+func (t *Writer) Write(e Entry) error {
+	for _, s := range []string{e.Operation, e.Outcome, e.Id, e.Key, e.Value} {
+		if len(s) > maxString {
+			return fmt.Errorf("trace string of %d bytes (at most %d)", len(s), maxString)
+		}
+	}
+	t.buf = t.buf[:0]
+	t.buf = binary.LittleEndian.AppendUint64(t.buf, math.Float64bits(e.At))
+	t.appendName(e.Operation)
+	t.appendName(e.Outcome)
+	t.appendString(e.Id)
+	t.appendString(e.Key)
+	t.buf = binary.AppendVarint(t.buf, int64(e.Index))
+	t.appendString(e.Value)
+	_, err := t.w.Write(t.buf)
+	return err
+}
+
+func (t *Writer) Close() error {
+	err := t.w.Flush()
+	return errors.Join(err, t.file.Close())
+}
+
+type Reader struct {
+	file  *os.File
+	r     *bufio.Reader
+	names []string
+}
+
+func Open(path string) (*Reader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	t := &Reader{file: file, r: bufio.NewReader(file)}
+	header := make([]byte, len(magic)+1)
+	if _, err := io.ReadFull(t.r, header); err != nil || string(header[:len(magic)]) != magic {
+		file.Close()
+		return nil, fmt.Errorf("%s: not a trace file", path)
+	}
+	if header[len(magic)] != version {
+		file.Close()
+		return nil, fmt.Errorf("%s: unsupported trace version %d", path, header[len(magic)])
+	}
+	return t, nil
+}
+
+func (t *Reader) readString() (string, error) {
+	n, err := binary.ReadUvarint(t.r)
+	if err != nil {
+		return "", err
+	}
+	if n > maxString {
+		return "", fmt.Errorf("invalid trace entry (string of %d bytes)", n)
+	}
+	s := make([]byte, n)
+	_, err = io.ReadFull(t.r, s)
+	return string(s), err
+}
+
+func (t *Reader) readName() (string, error) {
+	i, err := binary.ReadUvarint(t.r)
+	if err != nil {
+		return "", err
+	}
+	if i == uint64(len(t.names)) {
+		name, err := t.readString()
+		if err != nil {
+			return "", err
+		}
+		t.names = append(t.names, name)
+	} else if i > uint64(len(t.names)) {
+		return "", fmt.Errorf("invalid trace entry (name %d of %d)", i, len(t.names))
+	}
+	return t.names[i], nil
+}
+
+// Returns the next entry, or io.EOF at the end of the trace
+func (t *Reader) Read() (Entry, error) {
+	e := Entry{}
+	var at [8]byte
+	if _, err := io.ReadFull(t.r, at[:]); err != nil {
+		return e, err
+	}
+	e.At = math.Float64frombits(binary.LittleEndian.Uint64(at[:]))
+
+	var err error
+	if e.Operation, err = t.readName(); err != nil {
+		return e, unexpected(err)
+	}
+	if e.Outcome, err = t.readName(); err != nil {
+		return e, unexpected(err)
+	}
+	if e.Id, err = t.readString(); err != nil {
+		return e, unexpected(err)
+	}
+	if e.Key, err = t.readString(); err != nil {
+		return e, unexpected(err)
+	}
+	index, err := binary.ReadVarint(t.r)
+	if err != nil {
+		return e, unexpected(err)
+	}
+	e.Index = int(index)
+	if e.Value, err = t.readString(); err != nil {
+		return e, unexpected(err)
+	}
+	return e, nil
+}
+
+// A trace that ends in the middle of an entry is truncated, not complete
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func (t *Reader) Close() error {
+	return t.file.Close()
+}
@@ -0,0 +1,90 @@
+package trace
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	path := WorkerPath(t.TempDir(), 3)
+	entries := []Entry{
+		{At: 0.5, Operation: "mapAdd", Args: Args{Id: "m-1", Key: "7", Value: "abcd"}, Outcome: "committed"},
+		{At: 1.25, Operation: "counterInc", Args: Args{Id: "c-2", Index: -4}, Outcome: "serialization"},
+		{At: 2, Operation: "mapAdd", Args: Args{Id: "m-3", Key: "1", Value: ""}, Outcome: "committed"},
+	}
+	w, err := Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if err := w.Write(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	for i, expected := range entries {
+		e, err := r.Read()
+		if err != nil {
+			t.Fatalf("entry %d: %v", i, err)
+		}
+		if e != expected {
+			t.Errorf("entry %d: expected %+v, got %+v", i, expected, e)
+		}
+	}
+	if _, err := r.Read(); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestCorruptStringLength(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "worker-0.trace")
+	data := append([]byte(magic), version)
+	data = binary.LittleEndian.AppendUint64(data, 0)
+	data = binary.AppendUvarint(data, 0)
+	data = binary.AppendUvarint(data, 1<<40) // length of the operation name
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if _, err := r.Read(); err == nil || !strings.Contains(err.Error(), "invalid trace entry") {
+		t.Errorf("expected an invalid entry, got %v", err)
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "worker-0.trace")
+	w, err := Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(Entry{Operation: "setAdd", Args: Args{Id: "s-1", Key: "2"}, Outcome: "committed"})
+	w.Close()
+	data, _ := os.ReadFile(path)
+	os.WriteFile(path, data[:len(data)-2], 0644)
+
+	r, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if _, err := r.Read(); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected io.ErrUnexpectedEOF, got %v", err)
+	}
+}
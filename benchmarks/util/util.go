@@ -1,9 +1,8 @@
 package util
 
 import (
-	"math"
+	"hash/fnv"
 	"math/rand"
-	"sort"
 	"time"
 )
 
@@ -25,27 +24,6 @@ func EpochSeconds() float64 {
 	return float64(time.Now().UnixNano()) / float64(1e9)
 }
 
-// Computes a percentile (0-100) from an array
-func Percentile(a []float64, p int) float64 {
-	if len(a) <= 1 {
-		return math.NaN()
-	}
-
-	sort.Slice(a, func(i, j int) bool {
-		return a[i] < a[j]
-	})
-
-	r := (float64(p)/100)*float64(len(a)) - 1
-
-	if r == float64(int(r)) {
-		return a[int(r)]
-	} else {
-		ri := int(r)
-		rf := r - float64(ri)
-		return a[ri] + rf*(a[ri+1]-a[ri])
-	}
-}
-
 // Returns the first argument
 func First[T1, T2 any](r1 T1, _ T2) T1 {
 	return r1
@@ -69,10 +47,19 @@ func CastArray[T1, T2 any](array []T1) []T2 {
 const alphanumerics = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 
 // Returns a random alphanumeric string with 'length' bytes
-func RandomString(length int) string {
+func RandomString(r *rand.Rand, length int) string {
 	var s = make([]byte, length)
 	for i := 0; i < length; i++ {
-		s[i] = alphanumerics[rand.Intn(len(alphanumerics))]
+		s[i] = alphanumerics[r.Intn(len(alphanumerics))]
 	}
 	return string(s)
 }
+
+// Returns a source of random numbers derived from the seed and the name of a stream (e.g.,
+// "worker-1"), so each stream is independent, but the same seed replays the same sequence.
+// Not safe for concurrent use
+func NewRand(seed int64, stream string) *rand.Rand {
+	h := fnv.New64a()
+	h.Write([]byte(stream))
+	return rand.New(rand.NewSource(seed ^ int64(h.Sum64())))
+}
@@ -0,0 +1,86 @@
+package worker
+
+import (
+	engine "benchmarks/benchmark/engines/abstract"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Classes of errors, reported separately
+const (
+	Serialization = "serialization" // serialization failures (e.g., concurrent updates in repeatable read)
+	Deadlock      = "deadlock"      // deadlocks detected by the database
+	Connection    = "connection"    // lost or refused connections
+	Timeout       = "timeout"       // operations cancelled for exceeding the timeout
+	Semantic      = "semantic"      // operations not supported by the engine
+	Other         = "other"         // everything else
+)
+
+// All error classes
+var ErrorClasses = []string{Serialization, Deadlock, Connection, Timeout, Semantic, Other}
+
+// Retry policy of some error class. Each retry waits for an exponentially increasing backoff (with
+// jitter), starting at Backoff seconds and capped at MaxBackoff seconds.
+type RetryPolicy struct {
+	Attempts   int     // maximum number of retries (0 = never retry)
+	Backoff    float64 // (seconds) wait before the first retry
+	MaxBackoff float64 `yaml:"maxBackoff"` // (seconds) maximum wait between retries (0 = no maximum)
+}
+
+// Errors that carry a SQLSTATE code (e.g., *pq.Error)
+type sqlStateError interface {
+	SQLState() string
+}
+
+// Returns the class of a (non-nil) error
+func Classify(err error) string {
+	var stateErr sqlStateError
+	if errors.As(err, &stateErr) {
+		state := stateErr.SQLState()
+		switch {
+		case state == "40001":
+			return Serialization
+		case state == "40P01":
+			return Deadlock
+		// connection exception, and the server shutting down
+		case strings.HasPrefix(state, "08"), state == "57P01", state == "57P02", state == "57P03":
+			return Connection
+		case state == "0A000":
+			return Semantic
+		}
+	}
+
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return Timeout
+	case errors.Is(err, engine.ErrNotImplemented):
+		return Semantic
+	case errors.Is(err, engine.ErrConnection), errors.Is(err, driver.ErrBadConn), errors.Is(err, sql.ErrConnDone),
+		errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE), errors.As(err, &netErr):
+		return Connection
+	}
+
+	return Other
+}
+
+// Returns the time to wait before some retry (starting at 0), with the jitter drawn from r
+func (p RetryPolicy) backoff(retry int, r *rand.Rand) time.Duration {
+	backoff := p.Backoff * math.Pow(2, float64(retry))
+	if p.MaxBackoff > 0 {
+		backoff = min(backoff, p.MaxBackoff)
+	}
+	// half fixed, half random, so retries of concurrent operations do not collide again
+	backoff = backoff/2 + r.Float64()*backoff/2
+	return time.Duration(backoff * float64(time.Second))
+}
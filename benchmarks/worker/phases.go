@@ -0,0 +1,104 @@
+package worker
+
+import "math"
+
+// Phase of a run, with its own operation mix and rate (e.g., a write burst followed by a read-only
+// phase while the replicas catch up)
+type Phase struct {
+	Duration   float64     // seconds
+	Operations []Operation // operations (and weights) issued in the phase
+	Rate       float64     // target rate of the worker (ops/sec) in the phase; 0 = closed-loop
+	Active     bool        // whether the worker issues operations in the phase, otherwise it waits
+}
+
+// Runs the phases in sequence, instead of a single mix; the duration is their total
+func (w *Worker) SetPhases(phases []Phase) {
+	w.phases = phases
+	w.durations = []float64{}
+	total := 0.
+	for _, p := range phases {
+		w.durations = append(w.durations, p.Duration)
+		total += p.Duration
+	}
+	w.duration = int(math.Ceil(total))
+}
+
+// Returns the operations of the worker and of all its phases, each once
+func (w *Worker) allOperations() []Operation {
+	operations := append([]Operation{}, w.operations...)
+	for _, p := range w.phases {
+		operations = append(operations, p.Operations...)
+	}
+	all := []Operation{}
+	seen := map[string]bool{}
+	for _, o := range operations {
+		if !seen[o.Name] {
+			seen[o.Name] = true
+			all = append(all, o)
+		}
+	}
+	return all
+}
+
+// Returns the index of the phase at 't' seconds since the start, and the time at which it ends
+// (the last phase extends until the worker stops)
+func (w *Worker) phaseAt(t float64) (int, float64) {
+	end := 0.
+	for i, p := range w.phases {
+		end += p.Duration
+		if i == len(w.phases)-1 {
+			return i, max(end, float64(w.duration))
+		}
+		if t < end {
+			return i, end
+		}
+	}
+	return -1, 0
+}
+
+// Switches the operation mix and rate to a phase
+func (w *Worker) enterPhase(i int) {
+	p := w.phases[i]
+	w.operations = p.Operations
+	w.totalWeight = 0
+	for _, o := range w.operations {
+		w.totalWeight += o.Weight
+	}
+	w.rate = p.Rate
+}
+
+// Returns the index of the time series interval at 't' seconds since the origin. the intervals
+// restart at the beginning of each phase (of the given durations), so none spans two phases
+func IntervalAt(t float64, interval float64, durations []float64) int {
+	phaseStart := 0.
+	first := 0
+	for i, d := range durations {
+		if t < phaseStart+d || i == len(durations)-1 {
+			return first + int(math.Max(0, t-phaseStart)/interval)
+		}
+		phaseStart += d
+		first += int(math.Ceil(d / interval))
+	}
+	return int(t / interval)
+}
+
+// Returns the start and end (seconds since the origin) of the i-th time series interval, and the
+// index of its phase (see IntervalAt; -1 without phases)
+func IntervalBounds(i int, interval float64, durations []float64) (float64, float64, int) {
+	phaseStart := 0.
+	first := 0
+	for p, d := range durations {
+		n := int(math.Ceil(d / interval))
+		if i < first+n || p == len(durations)-1 {
+			start := phaseStart + float64(i-first)*interval
+			end := start + interval
+			if i < first+n {
+				end = min(end, phaseStart+d)
+			}
+			return start, end, p
+		}
+		phaseStart += d
+		first += n
+	}
+	return float64(i) * interval, float64(i+1) * interval, -1
+}
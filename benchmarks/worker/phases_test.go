@@ -0,0 +1,78 @@
+package worker
+
+import (
+	"benchmarks/testutil"
+	"context"
+	"testing"
+)
+
+func TestIntervalsRestartInEachPhase(t *testing.T) {
+	durations := []float64{2.5, 2}
+	tests := []struct {
+		t          float64
+		interval   int
+		start, end float64
+		phase      int
+	}{
+		{0, 0, 0, 1, 0},
+		{2.2, 2, 2, 2.5, 0},
+		{2.5, 3, 2.5, 3.5, 1},
+		{4.4, 4, 3.5, 4.5, 1},
+		// the last phase extends until the worker stops
+		{5, 5, 4.5, 5.5, 1},
+	}
+	for _, test := range tests {
+		if i := IntervalAt(test.t, 1, durations); i != test.interval {
+			t.Errorf("IntervalAt(%v) = %d, expected %d", test.t, i, test.interval)
+		}
+		if start, end, phase := IntervalBounds(test.interval, 1, durations); start != test.start || end != test.end || phase != test.phase {
+			t.Errorf("IntervalBounds(%d) = (%v, %v, %d), expected (%v, %v, %d)", test.interval, start, end, phase,
+				test.start, test.end, test.phase)
+		}
+	}
+}
+
+func TestPhases(t *testing.T) {
+	operations := []Operation{{Name: "mapAdd", Weight: 1}, {Name: "mapValue", Weight: 1}}
+	w := NewWorker(0, 0, 0, 0, 0, nil, operations, testutil.Benchmark{Operations: []string{"mapAdd", "mapValue"}})
+	w.SetPhases([]Phase{
+		{Duration: 0.2, Operations: []Operation{{Name: "mapAdd", Weight: 1}}, Rate: 100, Active: true},
+		{Duration: 0.2, Operations: []Operation{{Name: "mapValue", Weight: 1}}, Active: false},
+	})
+	c := make(chan *BenchmarkResults, 1)
+	w.Run(context.Background(), c)
+	results := <-c
+
+	// 20 operations at 100 ops/sec in the first phase, none while the worker waits in the second
+	if n := results.Operations["mapAdd"].CompleteCount; n < 15 || n > 21 {
+		t.Errorf("expected about 20 mapAdd in the first phase, got %d", n)
+	}
+	if n := results.Operations["mapValue"].CompleteCount; n != 0 {
+		t.Errorf("expected no mapValue in the inactive phase, got %d", n)
+	}
+}
+
+func TestPhasesWithDisjointOperations(t *testing.T) {
+	w := NewWorker(0, 0, 0, 0, 0, nil, []Operation{{Name: "counterGet", Weight: 1}},
+		testutil.Benchmark{Operations: []string{"mapAdd", "mapValue", "counterGet"}})
+	w.SetPhases([]Phase{
+		{Duration: 0.2, Operations: []Operation{{Name: "mapAdd", Weight: 1}}, Rate: 100, Active: true},
+		{Duration: 0.2, Operations: []Operation{{Name: "mapValue", Weight: 1}}, Rate: 100, Active: true},
+	})
+	c := make(chan *BenchmarkResults, 1)
+	w.Run(context.Background(), c)
+	results := <-c
+
+	for _, name := range []string{"counterGet", "mapAdd", "mapValue"} {
+		if results.Operations[name] == nil {
+			t.Fatalf("missing the metric of %s", name)
+		}
+	}
+	if results.Operations["mapAdd"].CompleteCount == 0 || results.Operations["mapValue"].CompleteCount == 0 {
+		t.Errorf("expected operations in both phases, got mapAdd=%d mapValue=%d",
+			results.Operations["mapAdd"].CompleteCount, results.Operations["mapValue"].CompleteCount)
+	}
+	if results.Operations["counterGet"].CompleteCount != 0 {
+		t.Errorf("expected no counterGet outside the phases, got %d", results.Operations["counterGet"].CompleteCount)
+	}
+}
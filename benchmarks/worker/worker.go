@@ -2,8 +2,14 @@ package worker
 
 import (
 	"benchmarks/benchmark"
+	"benchmarks/histogram"
+	"benchmarks/live"
+	"benchmarks/trace"
 	"benchmarks/util"
+	"context"
+	"fmt"
 	"log"
+	"math"
 	"math/rand"
 	"sync"
 	"time"
@@ -23,30 +29,126 @@ type Worker struct {
 	totalWeight     int
 	operationsToLog chan *OperationLogEntry
 	operationLogWg  *sync.WaitGroup
+	rate            float64                // target operations per second (open-loop); 0 = closed-loop
+	poisson         bool                   // whether the inter-arrival times follow a poisson process
+	maxLag          float64                // operations more than maxLag seconds late are dropped (0 = never drop)
+	interval        float64                // length (seconds) of each time series interval (0 = disabled)
+	origin          float64                // epoch time (seconds) at which the first interval starts
+	timeout         float64                // maximum duration (seconds) of each operation (0 = no timeout)
+	retry           map[string]RetryPolicy // error class -> retry policy (classes absent are not retried)
+
+	rand     *rand.Rand // choice of the operations
+	arrivals *rand.Rand // inter-arrival times (open-loop), apart so the choices do not depend on the arrival process
+	backoffs *rand.Rand // jitter of the retry backoffs
+
+	phases    []Phase   // phases of the run, in sequence (empty = a single mix for the whole duration)
+	durations []float64 // duration of each phase
+
+	trace *trace.Writer // records the operations issued, with their arguments (nil = disabled)
+
+	steadyWindow float64 // length (seconds) of the windows of the measured metrics (0 = disabled)
+
+	functions map[string]func(context.Context) error // operations of the benchmark, once prepared
 }
 
+// (seconds) delay after its intended start within which an operation is on time, as the worker is
+// woken up slightly after it (open-loop)
+const lateTolerance = 0.001
+
 type Operation struct {
 	Name   string
 	Weight int
 }
 
 type OperationLogEntry struct {
-	op  string
-	rt  float64
-	err error
-	t   time.Time
+	op      string
+	outcome outcome
+	t       time.Time
+}
+
+// Outcome of the execution of an operation
+type outcome struct {
+	rt      float64 // response time of the last attempt
+	totalRt float64 // response time including all attempts and backoffs
+	retries int     // number of retries
+	err     error   // error of the last attempt
+	class   string  // class of err
+	late    bool    // whether the operation started lateTolerance after its intended start (open-loop)
+	dropped bool    // whether the operation was dropped for being too late (open-loop)
 }
 
 type Metric struct {
-	Rts           []float64 // list of the response times (seconds) of committed transactions
-	TotalRt       float64   // sum of the response time of all committed transactions
-	CompleteCount int       // number of committed operations
-	AbortCount    int       // number of aborted operations
+	Rts           *histogram.Histogram // response times (seconds) of committed transactions
+	TotalRt       float64              // sum of the response time of all committed transactions
+	TotalRtRetry  float64              // sum of the response time of all committed transactions, including retries
+	CompleteCount int                  // number of committed operations
+	AbortCount    int                  // number of aborted operations
+	Errors        map[string]int       // number of aborted operations of each error class
+	RetryCount    int                  // number of retries
+	TimeoutCount  int                  // number of operations cancelled for exceeding the timeout
+	LateCount     int                  // number of operations that started lateTolerance after their intended start (open-loop)
+	DropCount     int                  // number of operations dropped for being too late (open-loop)
 }
 
 type BenchmarkResults struct {
+	Worker       int // id of the worker
 	RealDuration float64
-	Operations   map[string]*Metric // metric name -> Metric
+	End          float64              // epoch time (seconds) at which the worker stopped
+	Operations   map[string]*Metric   // metric name -> Metric
+	Intervals    []map[string]*Metric // metrics of each time series interval (metric name -> Metric)
+	Windows      []map[string]*Metric // measured metrics of each window, since the worker started (see SetSteadyState)
+}
+
+func NewMetric() *Metric {
+	return &Metric{Rts: histogram.New(), Errors: map[string]int{}}
+}
+
+// Name of the outcome in the live metrics
+func (o outcome) name() string {
+	if o.dropped {
+		return "dropped"
+	} else if o.err == nil {
+		return "committed"
+	}
+	return o.class
+}
+
+// Adds the outcome of an operation to the metric
+func (m *Metric) add(o outcome) {
+	if o.late {
+		m.LateCount++
+	}
+	m.RetryCount += o.retries
+
+	if o.dropped {
+		m.DropCount++
+	} else if o.err == nil {
+		m.CompleteCount++
+		m.Rts.Record(o.rt)
+		m.TotalRt += o.rt
+		m.TotalRtRetry += o.totalRt
+	} else if o.class == Timeout {
+		m.TimeoutCount++
+	} else {
+		m.AbortCount++
+		m.Errors[o.class]++
+	}
+}
+
+// Adds the values of another metric to this one
+func (m *Metric) Merge(other *Metric) {
+	m.Rts.Merge(other.Rts)
+	m.TotalRt += other.TotalRt
+	m.TotalRtRetry += other.TotalRtRetry
+	m.CompleteCount += other.CompleteCount
+	m.AbortCount += other.AbortCount
+	for class, count := range other.Errors {
+		m.Errors[class] += count
+	}
+	m.RetryCount += other.RetryCount
+	m.TimeoutCount += other.TimeoutCount
+	m.LateCount += other.LateCount
+	m.DropCount += other.DropCount
 }
 
 func NewWorker(id int, duration int, transactions int, warmup int, cooldown int, connection any, operations []Operation, benchmark benchmark.Benchmark) *Worker {
@@ -61,6 +163,7 @@ func NewWorker(id int, duration int, transactions int, warmup int, cooldown int,
 	worker.operations = operations
 	worker.operationsToLog = make(chan *OperationLogEntry, 1024)
 	worker.operationLogWg = &sync.WaitGroup{}
+	worker.SetSeed(time.Now().UnixNano())
 
 	for _, o := range worker.operations {
 		worker.totalWeight += o.Weight
@@ -69,6 +172,52 @@ func NewWorker(id int, duration int, transactions int, warmup int, cooldown int,
 	return worker
 }
 
+// Switches the worker to open-loop, where operations are issued at a target rate (ops/sec) instead
+// of as soon as the previous one returns. maxLag is the maximum delay (seconds) tolerated before
+// an operation is dropped (0 to never drop).
+func (w *Worker) SetOpenLoop(rate float64, poisson bool, maxLag float64) {
+	w.rate = rate
+	w.poisson = poisson
+	w.maxLag = maxLag
+}
+
+// Splits the results in time series intervals of 'interval' seconds, starting at 'origin' (epoch
+// seconds). All operations are considered, including the ones during the warmup and cooldown.
+func (w *Worker) SetInterval(interval float64, origin float64) {
+	w.interval = interval
+	w.origin = origin
+}
+
+// Records the operations issued (with their arguments, if the benchmark is benchmark.Traceable) in
+// a trace, closed at the end of the run
+func (w *Worker) SetTrace(t *trace.Writer) {
+	w.trace = t
+}
+
+// Also splits the measured metrics in windows of 'window' seconds since the worker started, so the
+// end of the warmup can be detected after the run (the warmup should be 0, so they cover it)
+func (w *Worker) SetSteadyState(window float64) {
+	w.steadyWindow = window
+}
+
+// Makes the choice of operations, the inter-arrival times, and the backoffs reproducible: a worker
+// with the same seed and id replays the same sequence
+func (w *Worker) SetSeed(seed int64) {
+	w.rand = util.NewRand(seed, fmt.Sprintf("worker-%d", w.id))
+	w.arrivals = util.NewRand(seed, fmt.Sprintf("arrivals-%d", w.id))
+	w.backoffs = util.NewRand(seed, fmt.Sprintf("backoffs-%d", w.id))
+}
+
+// Cancels operations that take longer than 'timeout' seconds (0 to never cancel)
+func (w *Worker) SetTimeout(timeout float64) {
+	w.timeout = timeout
+}
+
+// Sets the retry policy of each error class (classes absent are not retried)
+func (w *Worker) SetRetryPolicies(retry map[string]RetryPolicy) {
+	w.retry = retry
+}
+
 func (w *Worker) log(msg string) {
 	zlog.Info().Int("worker", w.id).Msg(msg)
 }
@@ -79,22 +228,29 @@ func (w *Worker) logOperationsWorker() {
 			break
 		}
 
+		o := operation.outcome
 		var msg string
-		if operation.err == nil {
+		if o.err == nil {
 			msg = "completed"
+		} else if o.class == Timeout {
+			msg = "timed out"
 		} else {
 			msg = "aborted"
 		}
 
-		zlog.Debug().Int("worker", w.id).Str("operation", operation.op).
-			Float64("rt", operation.rt).Time("real_time", operation.t).Msg(msg)
+		event := zlog.Debug().Int("worker", w.id).Str("operation", operation.op).
+			Float64("rt", o.rt).Int("retries", o.retries).Time("real_time", operation.t)
+		if o.err != nil {
+			event = event.Str("class", o.class).Err(o.err)
+		}
+		event.Msg(msg)
 	}
 
 	w.operationLogWg.Done()
 }
 
 func (w *Worker) getRandomOperation() *string {
-	r := rand.Intn(w.totalWeight)
+	r := w.rand.Intn(w.totalWeight)
 	curr := 0
 
 	for _, o := range w.operations {
@@ -107,16 +263,100 @@ func (w *Worker) getRandomOperation() *string {
 	panic("Random operation bigger than the cumulative sum.")
 }
 
-func (w *Worker) Run(c chan *BenchmarkResults) {
+// Returns the time (seconds) until the next operation should be issued, in open-loop
+func (w *Worker) interArrival() float64 {
+	if w.poisson {
+		return w.arrivals.ExpFloat64() / w.rate
+	}
+	return 1 / w.rate
+}
+
+// Executes one attempt of an operation, cancelling it if it exceeds the timeout. Panics are
+// recovered and returned as errors, so a failing operation does not stop the benchmark. Returns
+// whether the attempt timed out and its error.
+func (w *Worker) attempt(ctx context.Context, function func(context.Context) error) (timedOut bool, err error) {
+	opCtx := ctx
+	if w.timeout > 0 {
+		var cancel context.CancelFunc
+		opCtx, cancel = context.WithTimeout(ctx, time.Duration(w.timeout*float64(time.Second)))
+		defer cancel()
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(error); ok {
+				err = fmt.Errorf("panic: %w", e)
+			} else {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}
+		timedOut = err != nil && opCtx.Err() == context.DeadlineExceeded
+	}()
+
+	return false, function(opCtx)
+}
+
+// Executes an operation that was supposed to start at 'start' (epoch seconds), retrying it
+// according to the policy of the class of its error
+func (w *Worker) execute(ctx context.Context, function func(context.Context) error, start float64) outcome {
+	o := outcome{}
+	attemptStart := start
+
+	for {
+		// stopping the run does not cancel the attempt, so the operation ends at its boundary
+		timedOut, err := w.attempt(context.WithoutCancel(ctx), function)
+		end := util.EpochSeconds()
+		o.rt = end - attemptStart
+		o.totalRt = end - start
+		o.err = err
+		if err == nil {
+			o.class = ""
+			return o
+		} else if timedOut {
+			o.class = Timeout
+		} else {
+			o.class = Classify(err)
+		}
+
+		policy, ok := w.retry[o.class]
+		if !ok || o.retries >= policy.Attempts {
+			return o
+		}
+
+		select {
+		case <-time.After(policy.backoff(o.retries, w.backoffs)):
+		case <-ctx.Done():
+			return o
+		}
+		o.retries++
+		attemptStart = util.EpochSeconds()
+	}
+}
+
+// Prepares the operations of the benchmark on the worker's connection (e.g., its statements). Run
+// prepares them if they were not, but preparing all workers first keeps it out of their intervals
+func (w *Worker) Prepare() {
+	w.log("Preparing")
+	w.functions = w.benchmark.Prepare(w.connection)
+}
+
+// Runs the operations until the duration/number of transactions is reached or the context is done
+// (letting the current operation finish), and sends the results to c.
+func (w *Worker) Run(ctx context.Context, c chan *BenchmarkResults) {
+	if w.functions == nil {
+		w.Prepare()
+	}
 	w.operationLogWg.Add(1)
 	go w.logOperationsWorker()
 
-	w.log("Preparing")
-	functions := w.benchmark.Prepare(w.connection)
-	results := BenchmarkResults{}
+	functions := w.functions
+	results := BenchmarkResults{Worker: w.id}
 	results.Operations = map[string]*Metric{}
-	for _, o := range w.operations {
-		results.Operations[o.Name] = &Metric{}
+	// live metrics (no-op if not served), which include the warmup and cooldown
+	liveOps := map[string]*live.Operation{}
+	for _, o := range w.allOperations() {
+		results.Operations[o.Name] = NewMetric()
+		liveOps[o.Name] = live.ForOperation(o.Name)
 	}
 
 	w.log("Running")
@@ -124,39 +364,155 @@ func (w *Worker) Run(c chan *BenchmarkResults) {
 	start := util.EpochSeconds()
 	elapsed := 0.
 
-	for (w.duration > 0 && elapsed < float64(w.duration)) || (w.duration <= 0 && completedTransactions < w.transactions) {
-		op := w.getRandomOperation()
+	// intended start of the next operation (open-loop)
+	next := start
+	phase := -1
+	// benchmarks that dictate the operations (e.g., replaying a trace) may run out of them
+	scripted, _ := w.benchmark.(benchmark.Scripted)
+	exhausted := false
+
+	for ctx.Err() == nil && ((w.duration > 0 && elapsed < float64(w.duration)) || (w.duration <= 0 && completedTransactions < w.transactions)) {
+		if len(w.phases) > 0 {
+			p, end := w.phaseAt(util.EpochSeconds() - start)
+			if p != phase {
+				phase = p
+				w.enterPhase(p)
+				// the open-loop schedule restarts in each phase, without catching up
+				next = max(next, util.EpochSeconds())
+			}
+			if !w.phases[p].Active {
+				select {
+				case <-time.After(time.Duration((start + end - util.EpochSeconds()) * float64(time.Second))):
+				case <-ctx.Done():
+				}
+				elapsed = util.EpochSeconds() - start
+				continue
+			}
+		}
+
+		var op *string
+		at := -1.
+		if scripted != nil {
+			name, nextAt, ok := scripted.NextOperation()
+			if !ok {
+				exhausted = true
+				break
+			}
+			op = &name
+			at = nextAt
+			if results.Operations[name] == nil {
+				results.Operations[name] = NewMetric()
+				liveOps[name] = live.ForOperation(name)
+			}
+		} else {
+			op = w.getRandomOperation()
+		}
 		function, ok := functions[*op]
 		if !ok {
 			log.Fatalf("Function '%s' not found.\n", *op)
 		}
+		// also for the operations dropped below, so the seeded sequence does not depend on the timing
+		if randomized, ok := w.benchmark.(benchmark.Randomized); ok {
+			randomized.Draw(*op)
+		}
 
+		// in open-loop (or replaying the original times), the response time is measured from the
+		// intended start, so the time spent waiting behind a previous (slow) operation is also
+		// accounted for
 		txStart := util.EpochSeconds()
-		err := function()
-		rt := util.EpochSeconds() - txStart
-		w.operationsToLog <- &OperationLogEntry{*op, rt, err, time.Now()}
+		late := false
+		dropped := false
+		intended := -1.
+		if at >= 0 {
+			intended = start + at
+		} else if w.rate > 0 {
+			intended = next
+			next += w.interArrival()
+		}
+		if intended >= 0 {
+			if w.duration > 0 && intended-start >= float64(w.duration) {
+				break
+			}
 
-		if w.duration <= 0 || (elapsed > float64(w.warmup) && elapsed < float64(w.duration-w.cooldown)) {
-			metric := results.Operations[*op]
+			if wait := intended - txStart; wait > 0 {
+				select {
+				case <-time.After(time.Duration(wait * float64(time.Second))):
+				case <-ctx.Done():
+					continue
+				}
+			} else {
+				dropped = w.maxLag > 0 && -wait > w.maxLag
+				late = dropped || -wait > lateTolerance
+			}
 
-			if err == nil {
-				metric.CompleteCount++
-				metric.Rts = append(metric.Rts, rt)
-				metric.TotalRt += rt
+			txStart = intended
+			elapsed = intended - start
+		}
+
+		o := outcome{}
+		if !dropped {
+			o = w.execute(ctx, function, txStart)
+			w.operationsToLog <- &OperationLogEntry{*op, o, time.Now()}
+		}
+		o.late = late
+		o.dropped = dropped
+		liveOps[*op].Record(o.name(), o.rt, o.retries, o.late)
+
+		if w.trace != nil && !dropped {
+			e := trace.Entry{At: txStart - start, Operation: *op, Outcome: o.name()}
+			if intended >= 0 {
+				e.At = intended - start
+			}
+			if t, ok := w.benchmark.(benchmark.Traceable); ok {
+				e.Args = t.LastArgs()
+			}
+			util.CheckErr(w.trace.Write(e))
+		}
+
+		if w.duration <= 0 || (elapsed > float64(w.warmup) && elapsed < float64(w.duration-w.cooldown)) {
+			results.Operations[*op].add(o)
+			if !dropped && o.err == nil {
 				completedTransactions++
-			} else {
-				metric.AbortCount++
+			}
+			if w.steadyWindow > 0 {
+				i := int(elapsed / w.steadyWindow)
+				for len(results.Windows) <= i {
+					results.Windows = append(results.Windows, map[string]*Metric{})
+				}
+				if results.Windows[i][*op] == nil {
+					results.Windows[i][*op] = NewMetric()
+				}
+				results.Windows[i][*op].add(o)
 			}
 		}
 
+		if w.interval > 0 {
+			i := IntervalAt(util.EpochSeconds()-w.origin, w.interval, w.durations)
+			for len(results.Intervals) <= i {
+				results.Intervals = append(results.Intervals, map[string]*Metric{})
+			}
+			if results.Intervals[i][*op] == nil {
+				results.Intervals[i][*op] = NewMetric()
+			}
+			results.Intervals[i][*op].add(o)
+		}
+
 		elapsed = util.EpochSeconds() - start
 	}
 
-	results.RealDuration = util.EpochSeconds() - start
-	if w.duration > 0 {
+	results.End = util.EpochSeconds()
+	results.RealDuration = results.End - start
+	if w.duration > 0 && (ctx.Err() != nil || exhausted) {
+		// stopped early, so only the part of the measurement window actually executed counts
+		results.RealDuration = math.Max(0, math.Min(results.RealDuration, float64(w.duration-w.cooldown))-float64(w.warmup))
+	} else if w.duration > 0 {
 		results.RealDuration -= float64(w.warmup) + float64(w.cooldown)
 	}
 
+	if w.trace != nil {
+		util.CheckErr(w.trace.Close())
+	}
+
 	w.operationsToLog <- nil
 	w.operationLogWg.Wait()
 	w.log("Done")
@@ -0,0 +1,174 @@
+package worker
+
+import (
+	engine "benchmarks/benchmark/engines/abstract"
+	"benchmarks/testutil"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"syscall"
+	"testing"
+)
+
+// Benchmark whose operations fail with a serialization failure on every other attempt
+type flakyBenchmark struct {
+	testutil.Benchmark
+	attempts int
+}
+
+func (b *flakyBenchmark) Prepare(connection any) map[string]func(context.Context) error {
+	return map[string]func(context.Context) error{"counterInc": func(context.Context) error {
+		b.attempts++
+		if b.attempts%2 == 1 {
+			return testutil.SerializationError{}
+		}
+		return nil
+	}}
+}
+
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		policies map[string]RetryPolicy
+		complete int
+		aborted  int
+		retries  int
+	}{
+		{"no policy", nil, 10, 10, 0},
+		{"serialization", map[string]RetryPolicy{Serialization: {Attempts: 1}}, 10, 0, 10},
+		{"other class", map[string]RetryPolicy{Deadlock: {Attempts: 1}}, 10, 10, 0},
+	}
+	for _, test := range tests {
+		w := NewWorker(0, 0, 10, 0, 0, nil, []Operation{{Name: "counterInc", Weight: 1}}, &flakyBenchmark{})
+		w.SetRetryPolicies(test.policies)
+		c := make(chan *BenchmarkResults, 1)
+		w.Run(context.Background(), c)
+		m := (<-c).Operations["counterInc"]
+		if m.CompleteCount != test.complete || m.AbortCount != test.aborted || m.RetryCount != test.retries {
+			t.Errorf("%s: expected %d committed, %d aborted, and %d retries, got %d, %d, and %d", test.name,
+				test.complete, test.aborted, test.retries, m.CompleteCount, m.AbortCount, m.RetryCount)
+		}
+		if m.Errors[Serialization] != test.aborted {
+			t.Errorf("%s: expected %d serialization failures, got %v", test.name, test.aborted, m.Errors)
+		}
+	}
+}
+
+// Benchmark whose operations fail on the first attempt of every other draw of arguments, recording
+// the draw seen by each attempt
+type drawingBenchmark struct {
+	testutil.Benchmark
+	draws    int
+	failed   map[int]bool
+	attempts []int
+}
+
+func (b *drawingBenchmark) Draw(operation string) {
+	b.draws++
+}
+
+func (b *drawingBenchmark) Prepare(connection any) map[string]func(context.Context) error {
+	return map[string]func(context.Context) error{"counterInc": func(context.Context) error {
+		b.attempts = append(b.attempts, b.draws)
+		if b.draws%2 == 0 && !b.failed[b.draws] {
+			b.failed[b.draws] = true
+			return testutil.SerializationError{}
+		}
+		return nil
+	}}
+}
+
+func TestRetriesReuseTheArguments(t *testing.T) {
+	b := &drawingBenchmark{failed: map[int]bool{}}
+	w := NewWorker(0, 0, 10, 0, 0, nil, []Operation{{Name: "counterInc", Weight: 1}}, b)
+	w.SetRetryPolicies(map[string]RetryPolicy{Serialization: {Attempts: 1}})
+	c := make(chan *BenchmarkResults, 1)
+	w.Run(context.Background(), c)
+	results := <-c
+
+	m := results.Operations["counterInc"]
+	if m.CompleteCount != 10 || m.RetryCount == 0 {
+		t.Fatalf("expected 10 committed operations with retries, got %d with %d retries", m.CompleteCount, m.RetryCount)
+	}
+	if b.draws != m.CompleteCount {
+		t.Errorf("expected a draw per operation (%d), got %d", m.CompleteCount, b.draws)
+	}
+	// each failed attempt is followed by the retry, with the same draw
+	expected := []int{}
+	for draw := 1; draw <= 10; draw++ {
+		expected = append(expected, draw)
+		if draw%2 == 0 {
+			expected = append(expected, draw)
+		}
+	}
+	if !slices.Equal(b.attempts, expected) {
+		t.Errorf("expected the attempts %v, got %v", expected, b.attempts)
+	}
+}
+
+// Error with a SQLSTATE
+type stateError string
+
+func (e stateError) Error() string    { return "error " + string(e) }
+func (e stateError) SQLState() string { return string(e) }
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected string
+	}{
+		{testutil.SerializationError{}, Serialization},
+		{fmt.Errorf("wrapped: %w", stateError("40001")), Serialization},
+		{stateError("40P01"), Deadlock},
+		{stateError("08006"), Connection},
+		{stateError("57P01"), Connection},
+		{stateError("0A000"), Semantic},
+		{stateError("23505"), Other},
+		{context.DeadlineExceeded, Timeout},
+		{engine.ErrNotImplemented, Semantic},
+		{io.EOF, Connection},
+		{syscall.ECONNREFUSED, Connection},
+		{errors.New("boom"), Other},
+	}
+	for _, test := range tests {
+		if class := Classify(test.err); class != test.expected {
+			t.Errorf("Classify(%v) = %s, expected %s", test.err, class, test.expected)
+		}
+	}
+}
+
+// Benchmark that counts how many times it is prepared
+type preparedBenchmark struct {
+	testutil.Benchmark
+	prepared int
+}
+
+func (b *preparedBenchmark) Prepare(connection any) map[string]func(context.Context) error {
+	b.prepared++
+	return b.Benchmark.Prepare(connection)
+}
+
+func TestRunKeepsThePreparedOperations(t *testing.T) {
+	b := &preparedBenchmark{Benchmark: testutil.Benchmark{Operations: []string{"mapAdd"}}}
+	w := NewWorker(0, 0, 10, 0, 0, nil, []Operation{{Name: "mapAdd", Weight: 1}}, b)
+	w.Prepare()
+	c := make(chan *BenchmarkResults, 1)
+	w.Run(context.Background(), c)
+	if n := (<-c).Operations["mapAdd"].CompleteCount; n != 10 || b.prepared != 1 {
+		t.Errorf("expected 10 operations prepared once, got %d prepared %d times", n, b.prepared)
+	}
+}
+
+func TestOnTimeOperationsAreNotLate(t *testing.T) {
+	w := NewWorker(0, 1, 0, 0, 0, nil, []Operation{{Name: "mapAdd", Weight: 1}}, testutil.Benchmark{Operations: []string{"mapAdd"}})
+	// the worker wakes up slightly after each intended start, and keeps up with the rate
+	w.SetOpenLoop(2000, false, 0)
+	c := make(chan *BenchmarkResults, 1)
+	w.Run(context.Background(), c)
+	m := (<-c).Operations["mapAdd"]
+	if m.CompleteCount < 1000 || m.LateCount > m.CompleteCount/10 {
+		t.Errorf("expected few of the %d operations to be late, got %d", m.CompleteCount, m.LateCount)
+	}
+}